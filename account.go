@@ -78,6 +78,40 @@ func (a *Account) calculateFreeMargin() {
 	a.marginFree = a.equity - a.marginUsed
 }
 
+// applyOrderFill updates the trades and the balance with a successful order fill, returns the opened trade.
+// A close of part of the units of a trade reduces it.
+func (a *Account) applyOrderFill(orderFill *OrderFill) *Trade {
+
+	inst, exist := a.instruments[orderFill.Instrument.Name]
+	if !exist {
+		return nil
+	}
+
+	if orderFill.TradeClose {
+
+		if trade := inst.Trade(orderFill.TradeID); trade != nil && orderFill.Units > 0 && orderFill.Units < trade.units {
+			inst.reduceTrade(orderFill.TradeID, orderFill.Units)
+		} else {
+			inst.closeTrade(orderFill.TradeID)
+		}
+
+		a.balance.Add(orderFill.Profit)
+		return nil
+	}
+
+	trade := inst.openTrade(
+		orderFill.TradeID,
+		orderFill.Side,
+		orderFill.Time,
+		orderFill.Units,
+		orderFill.Price,
+	)
+	trade.tag = orderFill.Tag
+	trade.chargedFees.Add(orderFill.ChargedFees)
+
+	return trade
+}
+
 /**************************
 *
 *	Accessible Methods
@@ -131,3 +165,16 @@ func (a *Account) MarginFree() float64 {
 func (a *Account) Time() time.Time {
 	return a.time
 }
+
+// Exposure returns the net and gross exposure to each currency, in the home currency. Every open position
+// is split into its base and quote currency legs, converted with the current conversion rates.
+func (a *Account) Exposure() map[string]CurrencyExposure {
+
+	exposures := make(map[string]CurrencyExposure)
+
+	for currency, exposure := range accountExposure(a) {
+		exposures[currency] = *exposure
+	}
+
+	return exposures
+}
@@ -0,0 +1,214 @@
+package gotrader
+
+import (
+	"strconv"
+	"time"
+)
+
+// Timeframe represents the period aggregated by a bar.
+type Timeframe int
+
+const (
+	S5 Timeframe = iota
+	S10
+	S15
+	S30
+	M1
+	M2
+	M4
+	M5
+	M10
+	M15
+	M30
+	H1
+	H2
+	H3
+	H4
+	H6
+	H8
+	H12
+	D1
+)
+
+var timeframeDurations = [...]time.Duration{
+	5 * time.Second, 10 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute,
+	10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 2 * time.Hour, 3 * time.Hour, 4 * time.Hour,
+	6 * time.Hour, 8 * time.Hour, 12 * time.Hour,
+	24 * time.Hour,
+}
+
+func (tf Timeframe) String() string {
+
+	names := [...]string{
+		"S5", "S10", "S15", "S30",
+		"M1", "M2", "M4", "M5", "M10", "M15", "M30",
+		"H1", "H2", "H3", "H4", "H6", "H8", "H12",
+		"D1",
+	}
+
+	if tf < 0 || int(tf) >= len(names) {
+		return "Timeframe(" + strconv.Itoa(int(tf)) + ")"
+	}
+
+	return names[tf]
+}
+
+// Duration returns the period of the timeframe.
+func (tf Timeframe) Duration() time.Duration {
+	return timeframeDurations[tf]
+}
+
+// OHLC represents the open, high, low and close prices of a bar.
+type OHLC struct {
+	Open  float64
+	High  float64
+	Low   float64
+	Close float64
+}
+
+func newOHLC(price float64) OHLC {
+	return OHLC{Open: price, High: price, Low: price, Close: price}
+}
+
+func (o *OHLC) update(price float64) {
+
+	if price > o.High {
+		o.High = price
+	}
+
+	if price < o.Low {
+		o.Low = price
+	}
+
+	o.Close = price
+}
+
+// Bar represents the aggregation of the ticks of an instrument in a timeframe.
+// Time is the open time of the bar and Volume the number of ticks received.
+type Bar struct {
+	Instrument string
+	Timeframe  Timeframe
+	Time       time.Time
+	Bid        OHLC
+	Ask        OHLC
+	Mid        OHLC
+	Volume     int64
+	Complete   bool
+	IsWarmUp   bool // historical data replayed before the session starts trading
+}
+
+/**************************
+*
+*	Bar Builder
+*
+***************************/
+
+type barBuilder struct {
+	timeframes     []Timeframe
+	alignHour      int
+	alignLocation  *time.Location
+	instrumentBars map[string]map[Timeframe]*Bar // current bar of each instrument/timeframe
+}
+
+func newBarBuilder(timeframes []Timeframe, alignHour int, alignLocation *time.Location) *barBuilder {
+
+	if alignLocation == nil {
+		alignLocation = time.UTC
+	}
+
+	return &barBuilder{
+		timeframes:     timeframes,
+		alignHour:      alignHour,
+		alignLocation:  alignLocation,
+		instrumentBars: make(map[string]map[Timeframe]*Bar),
+	}
+}
+
+// update aggregates the tick and returns the bars completed by it, ordered by timeframe.
+// Bars are completed by the first tick that belongs to the next period, so no wall clock is used.
+func (b *barBuilder) update(tick *Tick) []*Bar {
+
+	var completed []*Bar
+
+	bars, exist := b.instrumentBars[tick.Instrument]
+	if !exist {
+		bars = make(map[Timeframe]*Bar, len(b.timeframes))
+		b.instrumentBars[tick.Instrument] = bars
+	}
+
+	mid := (tick.Bid + tick.Ask) / 2
+
+	for _, tf := range b.timeframes {
+
+		start := b.barStart(tick.Time, tf)
+		bar := bars[tf]
+
+		if bar != nil && !start.After(bar.Time) {
+			bar.Bid.update(tick.Bid)
+			bar.Ask.update(tick.Ask)
+			bar.Mid.update(mid)
+			bar.Volume++
+			continue
+		}
+
+		if bar != nil {
+			bar.Complete = true
+			completed = append(completed, bar)
+		}
+
+		bars[tf] = &Bar{
+			Instrument: tick.Instrument,
+			Timeframe:  tf,
+			Time:       start,
+			Bid:        newOHLC(tick.Bid),
+			Ask:        newOHLC(tick.Ask),
+			Mid:        newOHLC(mid),
+			Volume:     1,
+		}
+	}
+
+	return completed
+}
+
+// seed sets the current bar of its instrument and timeframe, so an incomplete historical bar
+// is completed with the ticks that follow.
+func (b *barBuilder) seed(bar *Bar) {
+
+	for _, tf := range b.timeframes {
+
+		if tf != bar.Timeframe {
+			continue
+		}
+
+		bars, exist := b.instrumentBars[bar.Instrument]
+		if !exist {
+			bars = make(map[Timeframe]*Bar, len(b.timeframes))
+			b.instrumentBars[bar.Instrument] = bars
+		}
+
+		seed := *bar
+		bars[tf] = &seed
+	}
+}
+
+// barStart returns the open time of the bar of timeframe tf that contains t,
+// with periods aligned to the configured daily close.
+func (b *barBuilder) barStart(t time.Time, tf Timeframe) time.Time {
+
+	local := t.In(b.alignLocation)
+	dayStart := time.Date(local.Year(), local.Month(), local.Day(), b.alignHour, 0, 0, 0, b.alignLocation)
+
+	if dayStart.After(t) {
+		dayStart = dayStart.AddDate(0, 0, -1)
+	}
+
+	if tf == D1 {
+		return dayStart
+	}
+
+	elapsed := t.Sub(dayStart)
+
+	return dayStart.Add(elapsed - elapsed%tf.Duration())
+}
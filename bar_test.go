@@ -0,0 +1,90 @@
+package gotrader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBarStart(t *testing.T) {
+
+	builder := newBarBuilder([]Timeframe{M15, H1, H4, D1}, 22, time.UTC)
+
+	tests := []struct {
+		time  time.Time
+		tf    Timeframe
+		start time.Time
+	}{
+		{time.Date(2020, 7, 6, 10, 7, 30, 0, time.UTC), M15, time.Date(2020, 7, 6, 10, 0, 0, 0, time.UTC)},
+		{time.Date(2020, 7, 6, 1, 30, 0, 0, time.UTC), H1, time.Date(2020, 7, 6, 1, 0, 0, 0, time.UTC)},
+		{time.Date(2020, 7, 6, 1, 30, 0, 0, time.UTC), H4, time.Date(2020, 7, 5, 22, 0, 0, 0, time.UTC)},
+		{time.Date(2020, 7, 6, 3, 0, 0, 0, time.UTC), H4, time.Date(2020, 7, 6, 2, 0, 0, 0, time.UTC)},
+		{time.Date(2020, 7, 6, 1, 30, 0, 0, time.UTC), D1, time.Date(2020, 7, 5, 22, 0, 0, 0, time.UTC)},
+		{time.Date(2020, 7, 6, 22, 0, 0, 0, time.UTC), D1, time.Date(2020, 7, 6, 22, 0, 0, 0, time.UTC)},
+		{time.Date(2020, 7, 6, 23, 10, 0, 0, time.UTC), H4, time.Date(2020, 7, 6, 22, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		if start := builder.barStart(test.time, test.tf); !start.Equal(test.start) {
+			t.Errorf("%v bar of %v starts at %v, expected %v", test.tf, test.time, start, test.start)
+		}
+	}
+}
+
+func TestBarBuilderUpdate(t *testing.T) {
+
+	builder := newBarBuilder([]Timeframe{M1, M5}, 0, nil)
+	start := time.Date(2020, 7, 6, 10, 0, 0, 0, time.UTC)
+
+	tick := func(seconds int, bid float64) *Tick {
+		return &Tick{Instrument: "EUR_USD", Bid: bid, Ask: bid + 0.0002, Time: start.Add(time.Duration(seconds) * time.Second)}
+	}
+
+	for _, tk := range []*Tick{tick(5, 1.1), tick(30, 1.2), tick(59, 1.0)} {
+		if completed := builder.update(tk); len(completed) != 0 {
+			t.Fatalf("unexpected bars completed at %v", tk.Time)
+		}
+	}
+
+	// The first tick of the next minute completes the M1 bar
+	completed := builder.update(tick(60, 1.15))
+	if len(completed) != 1 {
+		t.Fatalf("expected one completed bar, got %d", len(completed))
+	}
+
+	bar := completed[0]
+	if bar.Timeframe != M1 || !bar.Time.Equal(start) || !bar.Complete || bar.Volume != 3 {
+		t.Errorf("unexpected completed bar %+v", bar)
+	}
+	if bar.Bid != (OHLC{Open: 1.1, High: 1.2, Low: 1.0, Close: 1.0}) {
+		t.Errorf("unexpected bid prices %+v", bar.Bid)
+	}
+
+	// A gap completes the pending bars of every timeframe without creating empty bars
+	completed = builder.update(tick(7*60+10, 1.3))
+	if len(completed) != 2 {
+		t.Fatalf("expected two completed bars, got %d", len(completed))
+	}
+
+	if completed[0].Timeframe != M1 || !completed[0].Time.Equal(start.Add(time.Minute)) || completed[0].Volume != 1 {
+		t.Errorf("unexpected M1 bar %+v", completed[0])
+	}
+	if completed[1].Timeframe != M5 || !completed[1].Time.Equal(start) || completed[1].Volume != 4 || completed[1].Bid.High != 1.2 {
+		t.Errorf("unexpected M5 bar %+v", completed[1])
+	}
+
+	current := builder.instrumentBars["EUR_USD"]
+	if !current[M1].Time.Equal(start.Add(7*time.Minute)) || !current[M5].Time.Equal(start.Add(5*time.Minute)) || current[M1].Complete {
+		t.Errorf("unexpected current bars %+v %+v", current[M1], current[M5])
+	}
+}
+
+func TestTimeframeString(t *testing.T) {
+
+	if H4.String() != "H4" {
+		t.Errorf("unexpected name %s", H4.String())
+	}
+
+	if Timeframe(100).String() != "Timeframe(100)" {
+		t.Errorf("unexpected name %s", Timeframe(100).String())
+	}
+}
@@ -0,0 +1,186 @@
+package gotrader
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limits of the circuit breaker
+const (
+	DailyLossLimit   = "DAILY_LOSS"
+	DrawdownLimit    = "DRAWDOWN"
+	EquityFloorLimit = "EQUITY_FLOOR"
+)
+
+// CircuitBreakerRule is the rule of the orders rejected while the circuit breaker is tripped.
+const CircuitBreakerRule = "CIRCUIT_BREAKER"
+
+// Breach represents a limit of the circuit breaker breached by the equity.
+// Reference is the start of day equity, the session peak or the floor, depending on the limit.
+type Breach struct {
+	Limit     string
+	Equity    float64
+	Reference float64
+	Time      time.Time
+}
+
+// BreakerOption represents a circuit breaker functional option
+type BreakerOption func(b *circuitBreaker)
+
+// MaxDailyLoss trips the breaker when the equity falls the given fraction (e.g. 0.03 for 3%) below
+// the equity at the start of the day, which starts at hour in location (UTC if nil).
+func MaxDailyLoss(fraction float64, hour int, location *time.Location) BreakerOption {
+	return func(b *circuitBreaker) {
+		b.dailyLoss = fraction
+		b.resetHour = hour
+		if location != nil {
+			b.resetLocation = location
+		}
+	}
+}
+
+// MaxDrawdown trips the breaker when the equity falls the given fraction below the session peak.
+func MaxDrawdown(fraction float64) BreakerOption {
+	return func(b *circuitBreaker) {
+		b.drawdown = fraction
+	}
+}
+
+// EquityFloor trips the breaker when the equity falls below the given amount.
+func EquityFloor(amount float64) BreakerOption {
+	return func(b *circuitBreaker) {
+		b.floor = amount
+	}
+}
+
+// CloseOnBreach closes all trades when the breaker is tripped.
+func CloseOnBreach() BreakerOption {
+	return func(b *circuitBreaker) {
+		b.closeAll = true
+	}
+}
+
+// circuitBreaker tracks the equity and blocks new entries, as a risk rule, from the moment a limit is breached
+// until the start of the next day. The session peak is kept when it re-arms, so the drawdown limit is breached
+// again while the equity doesn't recover.
+type circuitBreaker struct {
+	dailyLoss     float64
+	drawdown      float64
+	floor         float64
+	resetHour     int
+	resetLocation *time.Location
+	closeAll      bool
+
+	mutex      sync.Mutex
+	dayStart   time.Time
+	dayEquity  float64
+	peakEquity float64
+	breach     *Breach
+}
+
+func newCircuitBreaker(opts []BreakerOption) *circuitBreaker {
+
+	breaker := &circuitBreaker{resetLocation: time.UTC}
+
+	for _, o := range opts {
+		o(breaker)
+	}
+
+	return breaker
+}
+
+// update tracks the equity at the given time and returns the breach if a limit has just been breached.
+func (b *circuitBreaker) update(now time.Time, equity float64) *Breach {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if start := b.startOfDay(now); start.After(b.dayStart) { // re-arm on a new day
+		b.dayStart = start
+		b.dayEquity = equity
+		b.breach = nil
+	}
+
+	if equity > b.peakEquity {
+		b.peakEquity = equity
+	}
+
+	if b.breach != nil {
+		return nil
+	}
+
+	breach := &Breach{Equity: equity, Time: now}
+
+	switch {
+	case b.dailyLoss > 0 && equity <= b.dayEquity*(1-b.dailyLoss):
+		breach.Limit, breach.Reference = DailyLossLimit, b.dayEquity
+	case b.drawdown > 0 && equity <= b.peakEquity*(1-b.drawdown):
+		breach.Limit, breach.Reference = DrawdownLimit, b.peakEquity
+	case b.floor > 0 && equity <= b.floor:
+		breach.Limit, breach.Reference = EquityFloorLimit, b.floor
+	default:
+		return nil
+	}
+
+	b.breach = breach
+
+	return breach
+}
+
+// state returns the equity references and the breach, to be persisted.
+func (b *circuitBreaker) state() *breakerState {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return &breakerState{DayStart: b.dayStart, DayEquity: b.dayEquity, PeakEquity: b.peakEquity, Breach: b.breach}
+}
+
+// restore continues the session of a persisted state, the day references and the breach are discarded
+// by the next update if the day changed meanwhile.
+func (b *circuitBreaker) restore(state *breakerState) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.dayStart = state.DayStart
+	b.dayEquity = state.DayEquity
+	b.peakEquity = state.PeakEquity
+	b.breach = state.Breach
+}
+
+func (b *circuitBreaker) startOfDay(t time.Time) time.Time {
+
+	local := t.In(b.resetLocation)
+	start := time.Date(local.Year(), local.Month(), local.Day(), b.resetHour, 0, 0, 0, b.resetLocation)
+
+	if start.After(t) {
+		start = start.AddDate(0, 0, -1)
+	}
+
+	return start
+}
+
+// Check rejects the orders while the breaker is tripped, except the orders only reducing the position.
+func (b *circuitBreaker) Check(account *Account, order *OrderRequest) *OrderRejection {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.breach != nil {
+
+		if account != nil {
+			if inst, exist := account.instruments[order.Instrument]; exist && inst.reduces(order.Side, order.Units) {
+				return nil
+			}
+		}
+
+		return &OrderRejection{
+			Rule:   CircuitBreakerRule,
+			Reason: b.breach.Limit + " limit breached with equity " + strconv.FormatFloat(b.breach.Equity, 'f', 2, 64),
+		}
+	}
+
+	return nil
+}
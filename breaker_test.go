@@ -0,0 +1,63 @@
+package gotrader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+
+	breaker := newCircuitBreaker([]BreakerOption{
+		MaxDailyLoss(0.05, 17, nil),
+		MaxDrawdown(0.1),
+		EquityFloor(8000),
+	})
+
+	start := time.Date(2020, 7, 6, 17, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		hours  int
+		equity float64
+		limit  string
+	}{
+		{0, 10000, ""},
+		{1, 10500, ""},
+		{2, 9490, DailyLossLimit},
+		{3, 9000, ""},             // already tripped
+		{24, 9460, ""},            // re-armed on the next day
+		{25, 9440, DrawdownLimit}, // below the session peak of 10500
+		{48, 9500, ""},
+		{49, 7900, DailyLossLimit},
+	}
+
+	for _, test := range tests {
+
+		limit := ""
+		if breach := breaker.update(start.Add(time.Duration(test.hours)*time.Hour), test.equity); breach != nil {
+			limit = breach.Limit
+		}
+
+		if limit != test.limit {
+			t.Errorf("hour %d: breached %q, expected %q", test.hours, limit, test.limit)
+		}
+	}
+
+	if rejection := breaker.Check(nil, &OrderRequest{}); rejection == nil || rejection.Rule != CircuitBreakerRule {
+		t.Errorf("orders allowed with the breaker tripped")
+	}
+
+	account := newAccount("test")
+	inst := newInstrument("EUR_USD", "EUR", "USD", 20, -4, nil)
+	inst.ccyConversion = newInstrumentConversion("EUR_USD", "EUR", "USD")
+	inst.hedgeType = NoHedge
+	account.instruments["EUR_USD"] = inst
+	inst.openTrade("1", Long, time.Time{}, 1000, 1.1)
+
+	if rejection := breaker.Check(account, &OrderRequest{Instrument: "EUR_USD", Side: Short, Units: 1000}); rejection != nil {
+		t.Errorf("order closing the position rejected with the breaker tripped")
+	}
+
+	if rejection := breaker.Check(account, &OrderRequest{Instrument: "EUR_USD", Side: Short, Units: 1001}); rejection == nil {
+		t.Errorf("order reversing the position allowed with the breaker tripped")
+	}
+}
@@ -0,0 +1,248 @@
+package gotrader
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewYorkRolloverHour is the hour of the FX daily rollover and weekly open/close, in New York time.
+const NewYorkRolloverHour = 17
+
+const dateLayout = "2006-01-02"
+
+// MarketClosedRule is the rule of the orders rejected while new entries on the instrument are not allowed.
+const MarketClosedRule = "MARKET_CLOSED"
+
+// TradingWindow is a daily period in which new entries are allowed, defined by offsets from
+// midnight in Location (UTC if nil). A window with End before Start crosses midnight.
+type TradingWindow struct {
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+func (w TradingWindow) contains(t time.Time) bool {
+
+	location := w.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	local := t.In(location)
+	offset := time.Duration(local.Hour())*time.Hour +
+		time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second +
+		time.Duration(local.Nanosecond())
+
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+
+	return offset >= w.Start || offset < w.End
+}
+
+// tradingCalendar knows the FX trading week, which opens on Sunday and closes on Friday at 5pm New York,
+// the daily rollover, the holidays and the trading windows of each instrument.
+type tradingCalendar struct {
+	holidays         map[string]bool // trading days without trading, by the New York date of their close
+	windows          map[string][]TradingWindow
+	rolloverBlackout time.Duration
+	flattenBefore    time.Duration
+}
+
+func newTradingCalendar(parameters *sessionParameters) (*tradingCalendar, error) {
+
+	calendar := &tradingCalendar{
+		holidays:         make(map[string]bool),
+		windows:          parameters.tradingWindows,
+		rolloverBlackout: parameters.rolloverBlackout,
+		flattenBefore:    parameters.flattenBefore,
+	}
+
+	if parameters.holidaysFile != "" {
+		if err := calendar.loadHolidays(parameters.holidaysFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return calendar, nil
+}
+
+// loadHolidays reads a file with a date (YYYY-MM-DD) per line, optionally followed by a description.
+// Empty lines and text after # are ignored.
+func (c *tradingCalendar) loadHolidays(file string) error {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for line := 1; scanner.Scan(); line++ {
+
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		day, err := time.Parse(dateLayout, fields[0])
+		if err != nil {
+			return errors.New(file + ":" + strconv.Itoa(line) + ": invalid holiday date " + fields[0])
+		}
+
+		c.holidays[day.Format(dateLayout)] = true
+	}
+
+	return scanner.Err()
+}
+
+// isOpen returns true if t is inside the trading week and is not a holiday.
+func (c *tradingCalendar) isOpen(t time.Time) bool {
+
+	ny := NewYorkTime(t)
+	afterRollover := ny.Hour() >= NewYorkRolloverHour
+
+	switch ny.Weekday() {
+	case time.Saturday:
+		return false
+	case time.Sunday:
+		if !afterRollover {
+			return false
+		}
+	case time.Friday:
+		if afterRollover {
+			return false
+		}
+	}
+
+	if afterRollover { // trading days close at the rollover, so this time belongs to the next one
+		ny = ny.AddDate(0, 0, 1)
+	}
+
+	return !c.holidays[ny.Format(dateLayout)]
+}
+
+// inRollover returns true if t is closer to the daily rollover than the rollover blackout.
+func (c *tradingCalendar) inRollover(t time.Time) bool {
+
+	if c.rolloverBlackout <= 0 {
+		return false
+	}
+
+	ny := NewYorkTime(t)
+	rollover := time.Date(ny.Year(), ny.Month(), ny.Day(), NewYorkRolloverHour, 0, 0, 0, time.UTC)
+	distance := ny.Sub(rollover)
+
+	switch { // distance to the nearest rollover
+	case distance > 12*time.Hour:
+		distance -= 24 * time.Hour
+	case distance < -12*time.Hour:
+		distance += 24 * time.Hour
+	}
+
+	return distance < c.rolloverBlackout && distance > -c.rolloverBlackout
+}
+
+// inWindow returns true if t is inside a trading window of the instrument, or the instrument has no windows.
+func (c *tradingCalendar) inWindow(instrument string, t time.Time) bool {
+
+	windows := c.windows[instrument]
+	if len(windows) == 0 {
+		return true
+	}
+
+	for _, w := range windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// entriesAllowed returns true if new entries on the instrument are allowed at t.
+func (c *tradingCalendar) entriesAllowed(instrument string, t time.Time) bool {
+
+	if !c.isOpen(t) || c.inRollover(t) || !c.inWindow(instrument, t) {
+		return false
+	}
+
+	if c.flattenBefore > 0 && weeklyClose(t).Sub(t) <= c.flattenBefore { // no entries after the weekend flattening
+		return false
+	}
+
+	return true
+}
+
+// weeklyClose returns the first weekly close after t.
+func weeklyClose(t time.Time) time.Time {
+
+	ny := NewYorkTime(t)
+	daysToFriday := (int(time.Friday) - int(ny.Weekday()) + 7) % 7
+
+	close := time.Date(ny.Year(), ny.Month(), ny.Day()+daysToFriday, NewYorkRolloverHour, 0, 0, 0, time.UTC)
+	if !close.After(ny) {
+		close = close.AddDate(0, 0, 7)
+	}
+
+	return FromNewYorkTime(close)
+}
+
+// scheduleWeekendFlatten schedules the close of all trades flattenBefore the weekly close,
+// and reschedules itself for the following week.
+func scheduleWeekendFlatten(engine Engine, scheduler *scheduler, flattenBefore time.Duration, close time.Time) {
+
+	scheduler.schedule(close.Add(-flattenBefore), 0, func() {
+
+		closeAllTrades(engine)
+		scheduleWeekendFlatten(engine, scheduler, flattenBefore, weeklyClose(close))
+	})
+}
+
+// NewYorkTime returns the New York wall clock of t, expressed in a UTC time.Time,
+// so the FX session boundaries can be computed without the system time zone database.
+func NewYorkTime(t time.Time) time.Time {
+	return t.UTC().Add(newYorkOffset(t))
+}
+
+// FromNewYorkTime is the inverse of NewYorkTime.
+func FromNewYorkTime(wall time.Time) time.Time {
+	return wall.Add(-newYorkOffset(wall.Add(-newYorkOffset(wall))))
+}
+
+// newYorkOffset returns the New York UTC offset, following the US daylight saving rules
+// (from the second Sunday of March to the first Sunday of November, at 2am local time).
+// The rules are applied directly so the calendar doesn't depend on the system time zone database.
+func newYorkOffset(t time.Time) time.Duration {
+
+	t = t.UTC()
+	year := t.Year()
+
+	dstStart := nthSunday(year, time.March, 2).Add(2*time.Hour + 5*time.Hour)
+	dstEnd := nthSunday(year, time.November, 1).Add(2*time.Hour + 4*time.Hour)
+
+	if !t.Before(dstStart) && t.Before(dstEnd) {
+		return -4 * time.Hour
+	}
+
+	return -5 * time.Hour
+}
+
+func nthSunday(year int, month time.Month, n int) time.Time {
+
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(time.Sunday) - int(first.Weekday()) + 7) % 7
+
+	return first.AddDate(0, 0, offset+7*(n-1))
+}
@@ -0,0 +1,62 @@
+package gotrader
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTradingCalendar(t *testing.T) {
+
+	file, err := ioutil.TempFile("", "holidays")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString("# FX holidays\n2020-12-25 Christmas\n\n")
+	file.Close()
+
+	calendar, err := newTradingCalendar(&sessionParameters{
+		holidaysFile:     file.Name(),
+		rolloverBlackout: 5 * time.Minute,
+		flattenBefore:    time.Hour,
+		tradingWindows: map[string][]TradingWindow{
+			"EUR_USD": {{Start: 7 * time.Hour, End: 16 * time.Hour}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		time       string
+		instrument string
+		allowed    bool
+	}{
+		{"2020-07-05T20:59:00Z", "GBP_USD", false}, // before the Sunday open (EDT)
+		{"2020-07-05T21:10:00Z", "GBP_USD", true},
+		{"2020-07-06T20:57:00Z", "GBP_USD", false}, // rollover blackout
+		{"2020-07-06T10:00:00Z", "EUR_USD", true},
+		{"2020-07-06T17:00:00Z", "EUR_USD", false}, // outside the trading window
+		{"2020-07-03T19:59:00Z", "GBP_USD", true},
+		{"2020-07-03T20:30:00Z", "GBP_USD", false}, // weekend flattening
+		{"2020-12-24T21:00:00Z", "GBP_USD", true},  // before the holiday trading day (EST)
+		{"2020-12-24T23:00:00Z", "GBP_USD", false}, // holiday
+	}
+
+	for _, test := range tests {
+
+		at, _ := time.Parse(time.RFC3339, test.time)
+
+		if allowed := calendar.entriesAllowed(test.instrument, at); allowed != test.allowed {
+			t.Errorf("%s %s: entries allowed %v, expected %v", test.time, test.instrument, allowed, test.allowed)
+		}
+	}
+
+	at, _ := time.Parse(time.RFC3339, "2020-07-03T21:00:00Z")
+	if close := weeklyClose(at); !close.Equal(at.AddDate(0, 0, 7)) {
+		t.Errorf("weekly close after %s is %s", at, close)
+	}
+}
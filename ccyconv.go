@@ -147,7 +147,7 @@ func (ce *currencyConversionEngine) findBaseConversionFunctions(instConv *instru
 
 	} else if instConv.QuoteCurrency == ce.homeCurrency {
 
-		instConv.BaseConversionFunction = []string{"1", instConv.Name, "/"}
+		instConv.BaseConversionFunction = []string{"1", instConv.Name, "*"}
 		ce.addBaseDependentInstrument(instConv.Name, instConv.Name)
 
 	} else {
@@ -178,7 +178,7 @@ func (ce *currencyConversionEngine) findQuoteConversionFunctions(instConv *instr
 
 	if instConv.QuoteCurrency == ce.homeCurrency {
 
-		instConv.BaseConversionRate.Store(1)
+		instConv.QuoteConversionRate.Store(1)
 
 	} else if instConv.BaseCurrency == ce.homeCurrency {
 
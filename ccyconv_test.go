@@ -0,0 +1,111 @@
+package gotrader
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestCurrencyConversionRates(t *testing.T) {
+
+	available := map[string]InstrumentDetails{}
+	prices := map[string]float64{
+		"EUR_USD": 1.1,
+		"GBP_USD": 1.25,
+		"USD_JPY": 110,
+		"USD_CAD": 1.35,
+		"EUR_GBP": 0.88,
+		"EUR_JPY": 121,
+		"CAD_JPY": 81.5,
+	}
+
+	for name := range prices {
+		available[name] = InstrumentDetails{Name: name, BaseCurrency: name[:3], QuoteCurrency: name[4:]}
+	}
+
+	tests := []struct {
+		home       string
+		instrument string
+		base       float64
+		quote      float64
+	}{
+		{"USD", "EUR_USD", 1.1, 1},         // quote is home
+		{"EUR", "EUR_USD", 1, 1 / 1.1},     // base is home
+		{"USD", "EUR_GBP", 1.1, 1.25},      // both through instruments quoted in home
+		{"USD", "EUR_JPY", 1.1, 1 / 110.0}, // quote through an instrument based in home
+		{"USD", "CAD_JPY", 1 / 1.35, 1 / 110.0},
+		{"JPY", "EUR_USD", 121, 110},
+		{"GBP", "EUR_GBP", 0.88, 1},
+	}
+
+	for _, test := range tests {
+
+		details := available[test.instrument]
+		conversions := map[string]*instrumentConversion{
+			test.instrument: newInstrumentConversion(details.Name, details.BaseCurrency, details.QuoteCurrency),
+		}
+
+		ce := newCurrencyConversionEngine(conversions, available, test.home, logrus.New())
+		ce.start()
+
+		for name, conv := range ce.conversionInstruments {
+			conv.Bid.Store(prices[name])
+			conv.Ask.Store(prices[name])
+		}
+
+		for name := range ce.conversionInstruments {
+			ce.updateRate(name)
+		}
+
+		conv := ce.conversionInstruments[test.instrument]
+		if base := conv.BaseConversionRate.Load(); math.Abs(base-test.base) > 1e-9 {
+			t.Errorf("%s in %s: base conversion rate %v, expected %v", test.instrument, test.home, base, test.base)
+		}
+		if quote := conv.QuoteConversionRate.Load(); math.Abs(quote-test.quote) > 1e-9 {
+			t.Errorf("%s in %s: quote conversion rate %v, expected %v", test.instrument, test.home, quote, test.quote)
+		}
+	}
+}
+
+type marginTestStrategy struct {
+	fills []*OrderFill
+}
+
+func (s *marginTestStrategy) Initialize()                  {}
+func (s *marginTestStrategy) SetEngine(engine Engine)      {}
+func (s *marginTestStrategy) OnTick(tick *Tick)            {}
+func (s *marginTestStrategy) OnStop()                      {}
+func (s *marginTestStrategy) OnOrderFill(order *OrderFill) { s.fills = append(s.fills, order) }
+
+func TestBacktestMarginUsesBaseConversionRate(t *testing.T) {
+
+	strategy := &marginTestStrategy{}
+	e := newBtEngine(logrus.New())
+	e.strategy = strategy
+	e.parameters = &sessionParameters{}
+	e.account = newAccount("test")
+	e.account.homeCurrency = "USD"
+
+	inst := newInstrument("EUR_USD", "EUR", "USD", 20, -4, e.logger)
+	inst.ccyConversion = newInstrumentConversion("EUR_USD", "EUR", "USD")
+	inst.ccyConversion.BaseConversionRate.Store(1.1)
+	inst.ask.Store(1.1)
+	inst.bid.Store(1.1)
+	e.account.instruments["EUR_USD"] = inst
+
+	// 1000 EUR at 20:1 require 55 USD of margin
+	e.account.marginFree = 50
+	e.onOrderOpen("EUR_USD", 1000, Long)
+
+	if len(strategy.fills) != 1 || strategy.fills[0].Error != "NOT_ENOUGH_MARGIN" {
+		t.Fatalf("expected the order to be rejected for lack of margin, got %+v", strategy.fills)
+	}
+
+	e.account.marginFree = 60
+	e.onOrderOpen("EUR_USD", 1000, Long)
+
+	if len(strategy.fills) != 2 || strategy.fills[1].Error != "" {
+		t.Fatalf("expected the order to be filled, got %+v", strategy.fills[1])
+	}
+}
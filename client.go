@@ -16,6 +16,67 @@ type BrokerClient interface {
 	SubscribeFundsTransferNotifications(accountID string, fundsTransferCallback FundsTransferHandler) error
 }
 
+// HistoryClient is an optional BrokerClient capability, implemented by the clients that can
+// provide historical bars.
+type HistoryClient interface {
+	GetBars(request BarsRequest) ([]*Bar, error)
+}
+
+// ClosableClient is an optional BrokerClient capability, implemented by the clients with subscriptions that can
+// be stopped. Close stops all subscriptions and returns when no more callbacks will be called.
+type ClosableClient interface {
+	Close() error
+}
+
+// TaggingClient is an optional BrokerClient capability, implemented by the clients that can tag the trades opened
+// by an order, so the trades of a session can be identified in the broker.
+type TaggingClient interface {
+	OpenTaggedMarketOrder(accountID, instrument string, units int32, side, tag string) error
+}
+
+// ProtectionClient is an optional BrokerClient capability, implemented by the clients that can attach a stop loss
+// to an open trade.
+type ProtectionClient interface {
+	SetStopLoss(accountID, id string, price float64) error
+}
+
+// StreamErrorClient is an optional BrokerClient capability, implemented by the clients that report the
+// notification or price subscriptions stopped on an error they can't recover from.
+type StreamErrorClient interface {
+	SubscribeStreamErrors(accountID string, errorCallback StreamErrorHandler) error
+}
+
+type StreamErrorHandler func(err error)
+
+// HeartbeatClient is an optional BrokerClient capability, implemented by the clients with a price stream that sends
+// heartbeats and can be reopened, so a stream that stopped without an error can be detected and recovered.
+// Heartbeats are subscribed before the prices.
+type HeartbeatClient interface {
+	SubscribeHeartbeats(accountID string, heartbeatCallback HeartbeatHandler) error
+	ResubscribePrices(accountID string) error
+}
+
+type HeartbeatHandler func(t time.Time)
+
+// MetricsClient is an optional BrokerClient capability, implemented by the clients that count their requests and
+// stream reconnections, which are served with the session metrics.
+type MetricsClient interface {
+	Metrics() ClientMetrics
+}
+
+// BarsRequest defines the historical bars to retrieve, the range is defined by From and To or by
+// Count, the last Count bars before To (or now if To is not defined). Bars are aligned to the daily
+// close at AlignHour in AlignLocation (UTC if not defined).
+type BarsRequest struct {
+	Instrument    string
+	Timeframe     Timeframe
+	From          time.Time
+	To            time.Time
+	Count         int
+	AlignHour     int
+	AlignLocation *time.Location
+}
+
 type TradeDetails struct {
 	ID          string
 	Instrument  InstrumentDetails
@@ -24,14 +85,19 @@ type TradeDetails struct {
 	OpenPrice   float64
 	ChargedFees float64
 	OpenTime    time.Time
+	Tag         string
 }
 
 type InstrumentDetails struct {
-	Name          string
-	BaseCurrency  string
-	QuoteCurrency string
-	Leverage      float64
-	PipLocation   int
+	Name                string
+	BaseCurrency        string
+	QuoteCurrency       string
+	Leverage            float64
+	PipLocation         int
+	DisplayPrecision    int     // decimal places of the prices, by default one more than the pip location
+	MinimumTradeSize    float64 // minimum units of a trade, zero if there is no minimum
+	TradeUnitsPrecision int     // decimal places of the units of a trade
+	MaximumOrderUnits   float64 // maximum units of an order, zero if there is no maximum
 }
 
 type AccountStatus struct {
@@ -47,17 +113,31 @@ type AccountStatus struct {
 
 type TickHandler func(tick *Tick)
 
+// Tick represents a price update of an instrument. Bid and Ask are the top of the book, when the
+// broker provides depth Bids and Asks hold the full ladder ordered from the best price.
 type Tick struct {
-	Instrument string
-	Bid        float64
-	Ask        float64
-	Time       time.Time
+	Instrument  string
+	Bid         float64
+	Ask         float64
+	Time        time.Time
+	Bids        []PriceLevel
+	Asks        []PriceLevel
+	CloseoutBid float64
+	CloseoutAsk float64
+	IsWarmUp    bool // historical data replayed before the session starts trading
+}
+
+// PriceLevel represents a price of the order book and the liquidity available at it.
+type PriceLevel struct {
+	Price     float64
+	Liquidity int64
 }
 
 type OrderFillHandler func(order *OrderFill)
 
 type OrderFill struct {
 	Error       string
+	Rejection   *OrderRejection // defined when the order was rejected by the risk manager
 	TradeClose  bool
 	OrderID     string
 	TradeID     string
@@ -68,6 +148,7 @@ type OrderFill struct {
 	Profit      float64
 	ChargedFees float64
 	Time        time.Time
+	Tag         string // tag of the opened trade
 }
 
 type SwapChargeHandler func(charges *SwapCharge)
@@ -0,0 +1,165 @@
+package btrand
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/luismcruz/gotrader"
+)
+
+// Default market profiles of the generator (indexed by UTC hour)
+// Spread -> multiplier applied to the generated spread
+// Tick -> multiplier applied to the tick frequency
+var (
+	defaultSpreadProfile = [24]float64{
+		1.6, 1.5, 1.4, 1.3, 1.3, 1.3, 1.2, 1.0, // Asian session, thin liquidity
+		0.9, 0.9, 0.9, 0.9, 0.8, 0.8, 0.8, 0.8, // London session and New York overlap
+		0.9, 1.0, 1.1, 1.2, 1.4, 1.8, 2.0, 1.8, // New York afternoon and after close
+	}
+	defaultTickProfile = [24]float64{
+		0.5, 0.5, 0.6, 0.6, 0.6, 0.6, 0.8, 1.2,
+		1.5, 1.5, 1.4, 1.3, 1.6, 1.8, 1.8, 1.6,
+		1.3, 1.0, 0.8, 0.7, 0.5, 0.3, 0.3, 0.4,
+	}
+)
+
+const (
+	gapSigmaCore          float64       = 0.001
+	rolloverWindowCore    time.Duration = 15 * time.Minute
+	rolloverMultiplerCore float64       = 4.0
+)
+
+// MarketCalendar models the FX trading week used by the generator: the market opens on Sunday
+// and closes on Friday at 5pm New York, reopens with a price gap, and has spreads and tick
+// frequencies that depend on the time of day.
+type MarketCalendar struct {
+	spreadProfile  [24]float64
+	tickProfile    [24]float64
+	gapSigma       float64
+	rolloverWindow time.Duration
+	rolloverSpread float64
+}
+
+// CalendarOption represents a market calendar functional option
+type CalendarOption func(c *MarketCalendar)
+
+// SpreadProfile defines the spread multiplier for each UTC hour of the day.
+func SpreadProfile(profile [24]float64) CalendarOption {
+	return func(c *MarketCalendar) {
+		c.spreadProfile = profile
+	}
+}
+
+// TickFrequencyProfile defines the tick frequency multiplier for each UTC hour of the day.
+func TickFrequencyProfile(profile [24]float64) CalendarOption {
+	return func(c *MarketCalendar) {
+		c.tickProfile = profile
+	}
+}
+
+// ReopenGap defines the standard deviation of the relative price gap when the market reopens.
+func ReopenGap(sigma float64) CalendarOption {
+	return func(c *MarketCalendar) {
+		c.gapSigma = sigma
+	}
+}
+
+// Rollover defines the spread multiplier applied during the window that starts at the 5pm New York rollover.
+func Rollover(window time.Duration, multiplier float64) CalendarOption {
+	return func(c *MarketCalendar) {
+		c.rolloverWindow = window
+		c.rolloverSpread = multiplier
+	}
+}
+
+// NewMarketCalendar is the MarketCalendar constructor, without options it uses the default FX profiles.
+// It returns an error if any of the multipliers is not positive.
+func NewMarketCalendar(opts ...CalendarOption) (*MarketCalendar, error) {
+
+	cal := &MarketCalendar{
+		spreadProfile:  defaultSpreadProfile,
+		tickProfile:    defaultTickProfile,
+		gapSigma:       gapSigmaCore,
+		rolloverWindow: rolloverWindowCore,
+		rolloverSpread: rolloverMultiplerCore,
+	}
+
+	for _, o := range opts {
+		o(cal)
+	}
+
+	for h := 0; h < 24; h++ {
+		if cal.spreadProfile[h] <= 0 {
+			return nil, errors.New("spread multiplier of hour " + strconv.Itoa(h) + " must be positive")
+		}
+		if cal.tickProfile[h] <= 0 {
+			return nil, errors.New("tick frequency multiplier of hour " + strconv.Itoa(h) + " must be positive")
+		}
+	}
+
+	if cal.rolloverSpread <= 0 {
+		return nil, errors.New("rollover spread multiplier must be positive")
+	}
+
+	return cal, nil
+}
+
+// IsOpen returns true if the market is open at the given time.
+func (c *MarketCalendar) IsOpen(t time.Time) bool {
+
+	ny := gotrader.NewYorkTime(t)
+	rollover := ny.Hour() >= gotrader.NewYorkRolloverHour
+
+	switch ny.Weekday() {
+	case time.Saturday:
+		return false
+	case time.Sunday:
+		return rollover
+	case time.Friday:
+		return !rollover
+	}
+
+	return true
+}
+
+// NextOpen returns the next market open after the given time, or the time itself if the market is open.
+func (c *MarketCalendar) NextOpen(t time.Time) time.Time {
+
+	if c.IsOpen(t) {
+		return t
+	}
+
+	ny := gotrader.NewYorkTime(t)
+	daysToSunday := (int(time.Sunday) - int(ny.Weekday()) + 7) % 7
+
+	open := time.Date(ny.Year(), ny.Month(), ny.Day()+daysToSunday, gotrader.NewYorkRolloverHour, 0, 0, 0, time.UTC)
+
+	// open is expressed in New York wall clock, convert it back to UTC
+	return gotrader.FromNewYorkTime(open)
+}
+
+// SpreadMultiplier returns the spread multiplier at the given time.
+func (c *MarketCalendar) SpreadMultiplier(t time.Time) float64 {
+
+	multiplier := c.spreadProfile[t.UTC().Hour()]
+
+	ny := gotrader.NewYorkTime(t)
+	rollover := time.Date(ny.Year(), ny.Month(), ny.Day(), gotrader.NewYorkRolloverHour, 0, 0, 0, time.UTC)
+
+	if !ny.Before(rollover) && ny.Sub(rollover) < c.rolloverWindow {
+		multiplier *= c.rolloverSpread
+	}
+
+	return multiplier
+}
+
+// TickRateMultiplier returns the tick frequency multiplier at the given time.
+func (c *MarketCalendar) TickRateMultiplier(t time.Time) float64 {
+	return c.tickProfile[t.UTC().Hour()]
+}
+
+func (c *MarketCalendar) gap(r *rand.Rand) float64 {
+	return r.NormFloat64() * c.gapSigma
+}
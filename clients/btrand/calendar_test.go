@@ -0,0 +1,108 @@
+package btrand
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarketCalendarHours(t *testing.T) {
+
+	cal, err := NewMarketCalendar()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		time time.Time
+		open bool
+		next time.Time
+	}{
+		{"summer friday before close", time.Date(2020, 7, 10, 20, 59, 0, 0, time.UTC), true, time.Date(2020, 7, 10, 20, 59, 0, 0, time.UTC)},
+		{"summer friday close", time.Date(2020, 7, 10, 21, 0, 0, 0, time.UTC), false, time.Date(2020, 7, 12, 21, 0, 0, 0, time.UTC)},
+		{"summer saturday", time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC), false, time.Date(2020, 7, 12, 21, 0, 0, 0, time.UTC)},
+		{"summer sunday open", time.Date(2020, 7, 12, 21, 0, 0, 0, time.UTC), true, time.Date(2020, 7, 12, 21, 0, 0, 0, time.UTC)},
+		{"winter friday before close", time.Date(2020, 1, 10, 21, 30, 0, 0, time.UTC), true, time.Date(2020, 1, 10, 21, 30, 0, 0, time.UTC)},
+		{"winter friday close", time.Date(2020, 1, 10, 22, 0, 0, 0, time.UTC), false, time.Date(2020, 1, 12, 22, 0, 0, 0, time.UTC)},
+		{"winter sunday before open", time.Date(2020, 1, 12, 21, 59, 0, 0, time.UTC), false, time.Date(2020, 1, 12, 22, 0, 0, 0, time.UTC)},
+		{"daylight saving starts on sunday", time.Date(2020, 3, 7, 12, 0, 0, 0, time.UTC), false, time.Date(2020, 3, 8, 21, 0, 0, 0, time.UTC)},
+		{"daylight saving ends on sunday", time.Date(2020, 10, 31, 12, 0, 0, 0, time.UTC), false, time.Date(2020, 11, 1, 22, 0, 0, 0, time.UTC)},
+		{"friday close before daylight saving ends", time.Date(2020, 10, 30, 21, 0, 0, 0, time.UTC), false, time.Date(2020, 11, 1, 22, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		if open := cal.IsOpen(test.time); open != test.open {
+			t.Errorf("%s: open %v, expected %v", test.name, open, test.open)
+		}
+		if next := cal.NextOpen(test.time); !next.Equal(test.next) {
+			t.Errorf("%s: next open %v, expected %v", test.name, next, test.next)
+		}
+	}
+}
+
+func TestMarketCalendarInvalidMultipliers(t *testing.T) {
+
+	var profile [24]float64
+	for h := range profile {
+		profile[h] = 1
+	}
+	profile[3] = 0
+
+	if _, err := NewMarketCalendar(TickFrequencyProfile(profile)); err == nil {
+		t.Error("expected an error for a zero tick frequency multiplier")
+	}
+
+	if _, err := NewMarketCalendar(SpreadProfile(profile)); err == nil {
+		t.Error("expected an error for a zero spread multiplier")
+	}
+
+	if _, err := NewMarketCalendar(Rollover(time.Minute, -1)); err == nil {
+		t.Error("expected an error for a negative rollover multiplier")
+	}
+}
+
+func TestPriceGeneratorWeekendGap(t *testing.T) {
+
+	cal, err := NewMarketCalendar(ReopenGap(0.01))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	friday := time.Date(2020, 7, 10, 20, 50, 0, 0, time.UTC)
+	reopen := time.Date(2020, 7, 12, 21, 0, 0, 0, time.UTC)
+
+	gen := newCorePriceGenerator("EUR_USD", friday, 1.1, 1)
+	gen.setCalendar(cal)
+
+	lastBid := 0.0
+
+	for i := 0; i < 100000; i++ {
+
+		tick := gen.next()
+
+		if !cal.IsOpen(tick.Time) {
+			t.Fatalf("tick generated while the market is closed at %v", tick.Time)
+		}
+
+		if tick.Time.Before(reopen) {
+			lastBid = tick.Bid
+			continue
+		}
+
+		if lastBid == 0 {
+			t.Fatal("no ticks generated before the weekend")
+		}
+
+		if !tick.Time.Equal(reopen) {
+			t.Errorf("first tick after the weekend at %v, expected the reopen %v", tick.Time, reopen)
+		}
+
+		if tick.Bid == lastBid {
+			t.Error("expected a price gap when the market reopens")
+		}
+
+		return
+	}
+
+	t.Fatal("the generator never crossed the weekend")
+}
@@ -15,10 +15,22 @@ type btRandClient struct {
 	startTime           time.Time
 	endTime             time.Time
 	currentTime         time.Time
+	calendar            *MarketCalendar
+}
+
+// ClientOption represents a random client functional option
+type ClientOption func(c *btRandClient)
+
+// MarketHours makes the generator follow the trading hours, reopen gaps and the
+// spread/tick frequency profiles of the given calendar.
+func MarketHours(calendar *MarketCalendar) ClientOption {
+	return func(c *btRandClient) {
+		c.calendar = calendar
+	}
 }
 
 func NewBTRandClient(instruments []gotrader.InstrumentDetails,
-	startTime, endTime time.Time) gotrader.BrokerClient {
+	startTime, endTime time.Time, opts ...ClientOption) gotrader.BrokerClient {
 
 	rand.Seed(time.Now().UnixNano())
 
@@ -30,6 +42,10 @@ func NewBTRandClient(instruments []gotrader.InstrumentDetails,
 		currentTime:         startTime,
 	}
 
+	for _, o := range opts {
+		o(client)
+	}
+
 	return client
 }
 
@@ -44,6 +60,10 @@ func (c *btRandClient) SubscribePrices(accountID string, instruments []gotrader.
 		for _, inst := range instruments {
 			startPrice := rand.Float64()*0.6 + 0.9
 			c.instrumentsPriceGen[inst.Name] = newCorePriceGenerator(inst.Name, c.startTime, startPrice, rand.Int63())
+
+			if c.calendar != nil {
+				c.instrumentsPriceGen[inst.Name].setCalendar(c.calendar)
+			}
 		}
 
 		for c.currentTime.Before(c.endTime) {
@@ -9,6 +9,7 @@ import (
 type priceGenerator struct {
 	instrument string
 	randGen    *randomGenerator
+	calendar   *MarketCalendar
 	price      float64
 	time       time.Time
 }
@@ -23,15 +24,34 @@ func newCorePriceGenerator(instrument string, startTime time.Time, startPrice fl
 	}
 }
 
+func (p *priceGenerator) setCalendar(calendar *MarketCalendar) {
+	p.calendar = calendar
+	p.time = calendar.NextOpen(p.time)
+}
+
 func (p *priceGenerator) next() *gotrader.Tick {
 
 	timeInc, priceInc, spread := p.randGen.next()
 	p.price += priceInc
 
+	if p.calendar != nil {
+		timeInc /= p.calendar.TickRateMultiplier(p.time)
+	}
+
 	duration := time.Duration(timeInc * float64(time.Second))
 
 	p.time = p.time.Add(duration)
 
+	if p.calendar != nil {
+
+		if !p.calendar.IsOpen(p.time) { // Skip the closed period and reopen with a gap
+			p.time = p.calendar.NextOpen(p.time)
+			p.price += p.price * p.calendar.gap(p.randGen.rand)
+		}
+
+		spread *= p.calendar.SpreadMultiplier(p.time)
+	}
+
 	tick := &gotrader.Tick{
 		Instrument: p.instrument,
 		Ask:        p.price + spread,
@@ -2,6 +2,7 @@ package btrand
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"testing"
 )
@@ -20,10 +21,11 @@ func Test_newCoreRandomGenerator(t *testing.T) {
 		/*priceVec := make([]float64, points, points)
 		timeVec := make([]float64, points, points)*/
 
-		f, err := os.Create("./datapoints.csv")
+		f, err := ioutil.TempFile("", "datapoints*.csv")
 		if err != nil {
-			return
+			t.Fatal(err)
 		}
+		defer os.Remove(f.Name())
 		defer f.Close()
 
 		for i := 0; i < points; i++ {
@@ -0,0 +1,224 @@
+package oandacl
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MaxCandlesCount is the maximum number of candles returned by a single candles request.
+const MaxCandlesCount = 5000
+
+type Candles struct {
+	Instrument   string        `json:"instrument"`
+	Granularity  string        `json:"granularity"`
+	Candles      []Candlestick `json:"candles"`
+	ErrorMessage string        `json:"errorMessage"`
+}
+
+type Candlestick struct {
+	Time     time.Time        `json:"time"`
+	Bid      *CandlestickData `json:"bid"`
+	Ask      *CandlestickData `json:"ask"`
+	Mid      *CandlestickData `json:"mid"`
+	Volume   int              `json:"volume"`
+	Complete bool             `json:"complete"`
+}
+
+type CandlestickData struct {
+	O float64 `json:"o,string"`
+	H float64 `json:"h,string"`
+	L float64 `json:"l,string"`
+	C float64 `json:"c,string"`
+}
+
+// CandlesRequest defines the candles to retrieve.
+// Granularity is one of S5, S10, S15, S30, M1, M2, M4, M5, M10, M15, M30, H1, H2, H3, H4, H6, H8, H12, D, W and M.
+// Price is a combination of the components M (mid), B (bid) and A (ask), by default M.
+// The range is defined by From and To, From and Count or To and Count (the last Count candles before To).
+// DailyAlignment is the hour of the day, in AlignmentTimezone, used to align candles of H1 or above.
+type CandlesRequest struct {
+	Granularity       string
+	Price             string
+	From              time.Time
+	To                time.Time
+	Count             int
+	DailyAlignment    int
+	AlignmentTimezone string
+	IncludeFirst      *bool
+}
+
+func (r CandlesRequest) query() url.Values {
+
+	query := url.Values{}
+
+	if r.Granularity != "" {
+		query.Set("granularity", r.Granularity)
+	}
+
+	if r.Price != "" {
+		query.Set("price", r.Price)
+	}
+
+	if !r.From.IsZero() {
+		query.Set("from", r.From.UTC().Format(time.RFC3339Nano))
+	}
+
+	if !r.To.IsZero() {
+		query.Set("to", r.To.UTC().Format(time.RFC3339Nano))
+	}
+
+	if r.Count > 0 {
+		query.Set("count", strconv.Itoa(r.Count))
+	}
+
+	if r.AlignmentTimezone != "" {
+		query.Set("dailyAlignment", strconv.Itoa(r.DailyAlignment))
+		query.Set("alignmentTimezone", r.AlignmentTimezone)
+	}
+
+	if r.IncludeFirst != nil {
+		query.Set("includeFirst", strconv.FormatBool(*r.IncludeFirst))
+	}
+
+	return query
+}
+
+// GetCandles retrieves the candles of an instrument in a single request (up to MaxCandlesCount candles).
+func (c *OandaClient) GetCandles(instrument string, request CandlesRequest) (Candles, error) {
+
+	endpoint := "/instruments/" + instrument + "/candles?" + request.query().Encode()
+
+	response, err := c.get(endpoint)
+
+	if err != nil {
+		return Candles{}, err
+	}
+
+	data := Candles{}
+	err = json.Unmarshal(response, &data)
+
+	if err != nil {
+		return Candles{}, err
+	}
+
+	if data.ErrorMessage != "" {
+		return Candles{}, errors.New(data.ErrorMessage)
+	}
+
+	return data, nil
+}
+
+// GetCandlesRange retrieves the candles of an instrument paging through as many requests as needed,
+// so ranges and counts above MaxCandlesCount are supported. Candles are returned by time order.
+func (c *OandaClient) GetCandlesRange(instrument string, request CandlesRequest) (Candles, error) {
+
+	if request.From.IsZero() && request.Count > 0 {
+		return c.getCandlesBackward(instrument, request)
+	}
+
+	if request.From.IsZero() {
+		return Candles{}, errors.New("candles range needs a start time or a count")
+	}
+
+	var (
+		result    = Candles{Instrument: instrument, Granularity: request.Granularity}
+		remaining = request.Count
+		to        = request.To
+		page      = request
+		first     = true
+	)
+
+	page.To = time.Time{} // paging is done with from and count, which can't be used with to
+
+	for {
+
+		page.Count = MaxCandlesCount
+		if request.Count > 0 && remaining < MaxCandlesCount {
+			page.Count = remaining
+		}
+
+		if !first { // the candle at from was already returned by the previous page
+			includeFirst := false
+			page.IncludeFirst = &includeFirst
+		}
+
+		candles, err := c.GetCandles(instrument, page)
+		if err != nil {
+			return Candles{}, err
+		}
+
+		for _, candle := range candles.Candles {
+
+			if !to.IsZero() && !candle.Time.Before(to) {
+				return result, nil
+			}
+
+			result.Candles = append(result.Candles, candle)
+			remaining--
+
+			if request.Count > 0 && remaining == 0 {
+				return result, nil
+			}
+		}
+
+		if len(candles.Candles) < page.Count { // no more candles available
+			return result, nil
+		}
+
+		page.From = candles.Candles[len(candles.Candles)-1].Time
+		first = false
+	}
+}
+
+func (c *OandaClient) getCandlesBackward(instrument string, request CandlesRequest) (Candles, error) {
+
+	var (
+		result    = Candles{Instrument: instrument, Granularity: request.Granularity}
+		remaining = request.Count
+		page      = request
+	)
+
+	if page.To.IsZero() {
+		page.To = time.Now()
+	}
+
+	for remaining > 0 {
+
+		page.Count = remaining
+		if page.Count > MaxCandlesCount {
+			page.Count = MaxCandlesCount
+		}
+
+		candles, err := c.GetCandles(instrument, page)
+		if err != nil {
+			return Candles{}, err
+		}
+
+		if len(candles.Candles) == 0 {
+			break
+		}
+
+		for _, candle := range candles.Candles {
+			if candle.Time.Before(page.To) {
+				result.Candles = append(result.Candles, candle)
+				remaining--
+			}
+		}
+
+		if len(candles.Candles) < page.Count {
+			break
+		}
+
+		page.To = candles.Candles[0].Time
+	}
+
+	sort.Slice(result.Candles, func(i, j int) bool {
+		return result.Candles[i].Time.Before(result.Candles[j].Time)
+	})
+
+	return result, nil
+}
@@ -0,0 +1,251 @@
+package oandacl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var candlesEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeCandlesServer serves M1 candles from candlesEpoch until the current minute of now.
+type fakeCandlesServer struct {
+	now      time.Time
+	mutex    sync.Mutex
+	requests []string
+}
+
+func (s *fakeCandlesServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	s.mutex.Lock()
+	s.requests = append(s.requests, r.URL.RawQuery)
+	s.mutex.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"errorMessage":"Insufficient authorization to perform request."}`)
+		return
+	}
+
+	if !strings.HasSuffix(r.URL.Path, "/instruments/EUR_USD/candles") {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errorMessage":"Invalid value specified for 'instrument'"}`)
+		return
+	}
+
+	query := r.URL.Query()
+
+	count, _ := strconv.Atoi(query.Get("count"))
+	if count > MaxCandlesCount {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"errorMessage":"Maximum value for 'count' exceeded"}`)
+		return
+	}
+
+	var start, end int // candle indexes [start, end)
+
+	last := int(s.now.Sub(candlesEpoch) / time.Minute)
+
+	if from := query.Get("from"); from != "" {
+
+		fromTime, _ := time.Parse(time.RFC3339Nano, from)
+		start = int((fromTime.Sub(candlesEpoch) + time.Minute - 1) / time.Minute)
+
+		if query.Get("includeFirst") == "false" && candlesEpoch.Add(time.Duration(start)*time.Minute).Equal(fromTime) {
+			start++
+		}
+
+		end = start + count
+
+	} else {
+
+		end = last
+		if to := query.Get("to"); to != "" {
+			toTime, _ := time.Parse(time.RFC3339Nano, to)
+			end = int((toTime.Sub(candlesEpoch) + time.Minute - 1) / time.Minute)
+		}
+
+		start = end - count
+	}
+
+	if start < 0 {
+		start = 0
+	}
+
+	if end > last {
+		end = last
+	}
+
+	candles := Candles{Instrument: "EUR_USD", Granularity: query.Get("granularity")}
+
+	for i := start; i < end; i++ {
+
+		mid := 1.1 + float64(i%100)/10000
+		data := &CandlestickData{O: mid, H: mid + 0.0002, L: mid - 0.0002, C: mid + 0.0001}
+
+		candle := Candlestick{
+			Time:     candlesEpoch.Add(time.Duration(i) * time.Minute),
+			Volume:   i%50 + 1,
+			Complete: true,
+		}
+
+		if strings.Contains(query.Get("price"), "M") {
+			candle.Mid = data
+		}
+		if strings.Contains(query.Get("price"), "B") {
+			candle.Bid = &CandlestickData{O: data.O - 0.0001, H: data.H - 0.0001, L: data.L - 0.0001, C: data.C - 0.0001}
+		}
+		if strings.Contains(query.Get("price"), "A") {
+			candle.Ask = &CandlestickData{O: data.O + 0.0001, H: data.H + 0.0001, L: data.L + 0.0001, C: data.C + 0.0001}
+		}
+
+		candles.Candles = append(candles.Candles, candle)
+	}
+
+	// Prices are sent as strings, as the real API does
+	response, _ := json.Marshal(candles)
+	w.Write(response)
+}
+
+func (d CandlestickData) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"o":"%.5f","h":"%.5f","l":"%.5f","c":"%.5f"}`, d.O, d.H, d.L, d.C)), nil
+}
+
+func newTestClient(t *testing.T, handler http.Handler) (*OandaClient, func()) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+
+	client := NewClient("token", false)
+	client.restURL = server.URL + "/v3"
+
+	return client, server.Close
+}
+
+func assertContiguous(t *testing.T, candles []Candlestick, first time.Time, count int) {
+	t.Helper()
+
+	if len(candles) != count {
+		t.Fatalf("got %d candles, want %d", len(candles), count)
+	}
+
+	for i, candle := range candles {
+		if want := first.Add(time.Duration(i) * time.Minute); !candle.Time.Equal(want) {
+			t.Fatalf("candle %d time = %v, want %v", i, candle.Time, want)
+		}
+	}
+}
+
+func TestGetCandles(t *testing.T) {
+
+	server := &fakeCandlesServer{now: candlesEpoch.Add(30 * 24 * time.Hour)}
+	client, closeServer := newTestClient(t, server)
+	defer closeServer()
+
+	from := candlesEpoch.Add(10 * time.Minute)
+
+	candles, err := client.GetCandles("EUR_USD", CandlesRequest{
+		Granularity:       "M1",
+		Price:             "BAM",
+		From:              from,
+		Count:             3,
+		DailyAlignment:    17,
+		AlignmentTimezone: "America/New_York",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertContiguous(t, candles.Candles, from, 3)
+
+	candle := candles.Candles[0]
+	if candle.Mid == nil || candle.Bid == nil || candle.Ask == nil {
+		t.Fatalf("missing price components: %+v", candle)
+	}
+
+	if candle.Mid.O != 1.101 || candle.Bid.C != 1.101 || candle.Ask.H != 1.1013 || candle.Volume != 11 {
+		t.Errorf("unexpected candle values: mid %+v bid %+v ask %+v volume %d", *candle.Mid, *candle.Bid, *candle.Ask, candle.Volume)
+	}
+
+	query := server.requests[0]
+	for _, param := range []string{"granularity=M1", "price=BAM", "count=3", "dailyAlignment=17", "alignmentTimezone=America%2FNew_York"} {
+		if !strings.Contains(query, param) {
+			t.Errorf("query %q does not contain %q", query, param)
+		}
+	}
+}
+
+func TestGetCandlesError(t *testing.T) {
+
+	client, closeServer := newTestClient(t, &fakeCandlesServer{now: candlesEpoch.Add(time.Hour)})
+	defer closeServer()
+
+	if _, err := client.GetCandles("XXX_YYY", CandlesRequest{Granularity: "M1", Count: 10}); err == nil {
+		t.Error("expected error for invalid instrument")
+	}
+
+	if _, err := client.GetCandlesRange("EUR_USD", CandlesRequest{Granularity: "M1"}); err == nil {
+		t.Error("expected error for a range without start time or count")
+	}
+}
+
+func TestGetCandlesRange(t *testing.T) {
+
+	server := &fakeCandlesServer{now: candlesEpoch.Add(30 * 24 * time.Hour)}
+	client, closeServer := newTestClient(t, server)
+	defer closeServer()
+
+	t.Run("from-to", func(t *testing.T) {
+
+		from := candlesEpoch.Add(90 * time.Second) // not aligned, first candle is at 2 minutes
+		to := candlesEpoch.Add(12002 * time.Minute)
+
+		candles, err := client.GetCandlesRange("EUR_USD", CandlesRequest{Granularity: "M1", From: from, To: to})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertContiguous(t, candles.Candles, candlesEpoch.Add(2*time.Minute), 12000)
+	})
+
+	t.Run("from-count", func(t *testing.T) {
+
+		candles, err := client.GetCandlesRange("EUR_USD", CandlesRequest{Granularity: "M1", From: candlesEpoch, Count: 7500})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertContiguous(t, candles.Candles, candlesEpoch, 7500)
+	})
+
+	t.Run("to-count", func(t *testing.T) {
+
+		to := candlesEpoch.Add(20000 * time.Minute)
+
+		candles, err := client.GetCandlesRange("EUR_USD", CandlesRequest{Granularity: "M1", To: to, Count: 11000})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertContiguous(t, candles.Candles, to.Add(-11000*time.Minute), 11000)
+	})
+
+	t.Run("until-now", func(t *testing.T) {
+
+		from := server.now.Add(-6000 * time.Minute)
+
+		candles, err := client.GetCandlesRange("EUR_USD", CandlesRequest{Granularity: "M1", From: from})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertContiguous(t, candles.Candles, from, 6000)
+	})
+}
@@ -1,11 +1,15 @@
 package oandacl
 
 import (
-	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
 )
 
 type Headers struct {
@@ -22,8 +26,14 @@ type OandaClient struct {
 	streamClient             http.Client
 	priceSubscriptions       map[string]bool
 	transactionSubscriptions map[string]*transactionTypeLogic
+	transactionErrors        map[string]StreamErrorHandler
+	priceErrors              map[string]StreamErrorHandler
 	mutex                    sync.Locker
 	stopPriceSubscripton     chan bool
+	ctx                      context.Context // cancelled when the client is closed, stops the streams
+	cancel                   context.CancelFunc
+	streams                  sync.WaitGroup
+	stats                    *stats
 }
 
 func NewClient(token string, live bool) *OandaClient {
@@ -55,17 +65,32 @@ func NewClient(token string, live bool) *OandaClient {
 		restClient:               http.Client{},
 		streamClient:             http.Client{},
 		transactionSubscriptions: make(map[string]*transactionTypeLogic),
+		transactionErrors:        make(map[string]StreamErrorHandler),
+		priceErrors:              make(map[string]StreamErrorHandler),
+		stats:                    newStats(),
 		mutex: &sync.Mutex{},
 	}
 
+	connection.ctx, connection.cancel = context.WithCancel(context.Background())
+
 	return connection
 }
 
+// Close stops all the subscriptions and returns when their goroutines have exited.
+func (c *OandaClient) Close() error {
+
+	c.cancel()
+	c.streams.Wait()
+
+	return nil
+}
+
+// get requests are cancelled when the client is closed, as they only read the account state.
 func (c *OandaClient) get(endpoint string) ([]byte, error) {
 
 	url := c.restURL + endpoint
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, url, nil)
 
 	if err != nil {
 		return nil, err
@@ -74,11 +99,11 @@ func (c *OandaClient) get(endpoint string) ([]byte, error) {
 	return c.makeRequest(req)
 }
 
-func (c *OandaClient) put(endpoint string) ([]byte, error) {
+func (c *OandaClient) put(endpoint string, data []byte) ([]byte, error) {
 
 	url := c.restURL + endpoint
 
-	req, err := http.NewRequest(http.MethodPut, url, nil)
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(data))
 
 	if err != nil {
 		return nil, err
@@ -100,11 +125,13 @@ func (c *OandaClient) post(endpoint string, data []byte) ([]byte, error) {
 	return c.makeRequest(req)
 }
 
-func (c *OandaClient) dial(endpoint string) (*bufio.Reader, error) {
+// openStream returns the body of a stream endpoint, which must be closed by the caller.
+// The stream is interrupted when ctx is cancelled.
+func (c *OandaClient) openStream(ctx context.Context, endpoint string) (io.ReadCloser, error) {
 
 	url := c.streamURL + endpoint
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 
 	if err != nil {
 		return nil, err
@@ -118,25 +145,40 @@ func (c *OandaClient) dial(endpoint string) (*bufio.Reader, error) {
 		return nil, err
 	}
 
-	return bufio.NewReader(res.Body), nil
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, newStatusError(res)
+	}
+
+	return res.Body, nil
 }
 
+// makeRequest returns the body of a successful response, or a StatusError if the broker rejected the request.
 func (c *OandaClient) makeRequest(req *http.Request) ([]byte, error) {
 
 	c.setHeaders(req)
 
+	start := time.Now()
 	res, err := c.restClient.Do(req)
 
 	if err != nil {
+		c.stats.request(0, time.Since(start))
 		return nil, err
 	}
 
+	defer res.Body.Close()
+
 	body, err := ioutil.ReadAll(res.Body)
+	c.stats.request(res.StatusCode, time.Since(start))
 
 	if err != nil {
 		return nil, err
 	}
 
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, statusError(res.StatusCode, body)
+	}
+
 	return body, nil
 }
 
@@ -144,3 +186,38 @@ func (c *OandaClient) setHeaders(req *http.Request) {
 	req.Header.Set("Authorization", c.headers.auth)
 	req.Header.Set("Content-Type", c.headers.contentType)
 }
+
+// StatusError is returned when a request is rejected by the broker.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func newStatusError(res *http.Response) *StatusError {
+
+	body, _ := ioutil.ReadAll(res.Body)
+
+	return statusError(res.StatusCode, body)
+}
+
+func statusError(statusCode int, body []byte) *StatusError {
+
+	data := struct {
+		ErrorMessage string `json:"errorMessage"`
+	}{}
+
+	if err := json.Unmarshal(body, &data); err != nil || data.ErrorMessage == "" {
+		data.ErrorMessage = http.StatusText(statusCode)
+	}
+
+	return &StatusError{StatusCode: statusCode, Message: data.ErrorMessage}
+}
+
+func (e *StatusError) Error() string {
+	return strconv.Itoa(e.StatusCode) + ": " + e.Message
+}
+
+// Temporary returns true if the request can succeed when retried (server errors and rate limiting).
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+}
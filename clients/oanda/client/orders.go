@@ -72,23 +72,35 @@ type OrderFillTransaction struct {
 }
 
 type TradeOpened struct {
-	TradeID string  `json:"tradeID"`
-	Units   int32   `json:"units,string"`
-	Price   float64 `json:"price,string"`
+	TradeID          string            `json:"tradeID"`
+	Units            int32             `json:"units,string"`
+	Price            float64           `json:"price,string"`
+	ClientExtensions *ClientExtensions `json:"clientExtensions"`
 }
 
 func (c *OandaClient) CreateMarketOrder(accountID, instrument, side string, units int32) (OrderResponse, error) {
+	return c.createMarketOrder(accountID, instrument, side, units, nil)
+}
+
+// CreateTaggedMarketOrder creates a market order whose opened trade has the tag in its client extensions.
+func (c *OandaClient) CreateTaggedMarketOrder(accountID, instrument, side string, units int32, tag string) (OrderResponse, error) {
+	return c.createMarketOrder(accountID, instrument, side, units, &ClientExtensions{Tag: &tag})
+}
+
+func (c *OandaClient) createMarketOrder(accountID, instrument, side string, units int32,
+	extensions *ClientExtensions) (OrderResponse, error) {
 
 	if side == "SHORT" {
 		units = -units
 	}
 
 	order := Order{
-		Units:        units,
-		Instrument:   instrument,
-		TimeInForce:  "FOK",
-		Type:         "MARKET",
-		PositionFill: "DEFAULT",
+		Units:            units,
+		Instrument:       instrument,
+		TimeInForce:      "FOK",
+		Type:             "MARKET",
+		PositionFill:     "DEFAULT",
+		ClientExtensions: extensions,
 	}
 
 	body := OrderRequest{Order: order}
@@ -2,7 +2,10 @@ package oandacl
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"math"
 	"net/url"
 	"strings"
@@ -19,8 +22,8 @@ type Pricings struct {
 type Price struct {
 	Asks        []PriceBucket `json:"asks"`
 	Bids        []PriceBucket `json:"bids"`
-	CloseoutAsk string        `json:"closeoutAsk"`
-	CloseoutBid string        `json:"closeoutBid"`
+	CloseoutAsk float64       `json:"closeoutAsk,string"`
+	CloseoutBid float64       `json:"closeoutBid,string"`
 	Instrument  string        `json:"instrument"`
 	Time        time.Time     `json:"time"`
 }
@@ -30,8 +33,16 @@ type PriceBucket struct {
 	Price     float64 `json:"price,string"`
 }
 
+// PricingHeartbeat is sent by the price stream every 5 seconds, so a stream without data can be detected.
+type PricingHeartbeat struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+}
+
 type PriceHandler func(price Price)
 
+type HeartbeatHandler func(heartbeat PricingHeartbeat)
+
 func (c *OandaClient) GetPrices(accountID string, instruments []string) (Pricings, error) {
 
 	instrumentString := strings.Join(instruments, ",")
@@ -54,8 +65,16 @@ func (c *OandaClient) GetPrices(accountID string, instruments []string) (Pricing
 }
 
 func (c *OandaClient) SubscribePrices(accountID string, instruments []string, handler PriceHandler) (*PriceSubscription, error) {
+	return c.SubscribePricesWithHeartbeats(accountID, instruments, handler, nil)
+}
+
+// SubscribePricesWithHeartbeats subscribes the prices, the heartbeats of the stream are passed to heartbeatHandler.
+func (c *OandaClient) SubscribePricesWithHeartbeats(accountID string, instruments []string, handler PriceHandler,
+	heartbeatHandler HeartbeatHandler) (*PriceSubscription, error) {
+
+	subscription := newPriceSubscrption(c, handler, accountID)
+	subscription.heartbeatHandler = heartbeatHandler
 
-	subscription := newPriceSubscrption(c.dial, handler, accountID)
 	err := subscription.subscribe(instruments)
 
 	if err != nil {
@@ -67,31 +86,59 @@ func (c *OandaClient) SubscribePrices(accountID string, instruments []string, ha
 
 // PriceSubscription represents a subscription, can be used to free resources
 type PriceSubscription struct {
-	priceSubscriptions   map[string]bool
-	stopPriceSubscripton chan bool
-	handler              PriceHandler
-	mutex                *sync.Mutex
-	dial                 func(endpoint string) (*bufio.Reader, error)
-	accountID            string
-	activeSubscription   bool
+	priceSubscriptions map[string]bool
+	handler            PriceHandler
+	heartbeatHandler   HeartbeatHandler
+	mutex              *sync.Mutex
+	client             *OandaClient
+	accountID          string
+	cancel             context.CancelFunc // stops the active subscription, nil if there isn't one
+	done               chan struct{}      // closed when the goroutine of the active subscription exits
 }
 
-func newPriceSubscrption(dial func(endpoint string) (*bufio.Reader, error),
-	handler PriceHandler, accountID string) *PriceSubscription {
+func newPriceSubscrption(client *OandaClient, handler PriceHandler, accountID string) *PriceSubscription {
 
 	return &PriceSubscription{
-		priceSubscriptions:   make(map[string]bool),
-		stopPriceSubscripton: make(chan bool),
-		mutex:                &sync.Mutex{},
-		dial:                 dial,
-		accountID:            accountID,
-		handler:              handler,
+		priceSubscriptions: make(map[string]bool),
+		mutex:              &sync.Mutex{},
+		client:             client,
+		accountID:          accountID,
+		handler:            handler,
 	}
 }
 
-// Stop will stop the subscription
+// Stop will stop the subscription, returns when its goroutine has exited
 func (s *PriceSubscription) Stop() {
-	s.stopPriceSubscripton <- true
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.stop()
+}
+
+// Resubscribe replaces the stream of the subscription by a new one, to recover a stream that stopped sending
+// data without an error, such as a half-open connection.
+func (s *PriceSubscription) Resubscribe() error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.subscribePrices(); err != nil {
+		return err
+	}
+
+	s.client.stats.reconnected(PricingStream)
+
+	return nil
+}
+
+func (s *PriceSubscription) stop() {
+
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+		s.cancel = nil
+	}
 }
 
 func (s *PriceSubscription) subscribe(instruments []string) error {
@@ -113,75 +160,125 @@ func (s *PriceSubscription) subscribePrices() error {
 	instrumentString := strings.Join(s.getInstrumentsList(s.accountID), ",")
 	endpoint := "/accounts/" + s.accountID + "/pricing/stream?instruments=" + url.QueryEscape(instrumentString)
 
-	reader, err := s.dial(endpoint)
+	ctx, cancel := context.WithCancel(s.client.ctx)
+	body, err := s.client.openStream(ctx, endpoint)
 
 	if err != nil {
+		cancel()
 		return err
 	}
 
-	if s.activeSubscription { // Shuts down previous go routine
-		s.stopPriceSubscripton <- true // channel is not buffered to make sure it waits for the shutdown
-	} else {
-		s.activeSubscription = true
-	}
+	s.stop() // Shuts down previous subscription
 
-	go func(reader *bufio.Reader, endpoint string) {
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.client.streams.Add(1)
 
-	subLoop:
-		for {
-			select {
-			case <-s.stopPriceSubscripton:
-				break subLoop
-			default:
+	go func(ctx context.Context, body io.ReadCloser, done chan struct{}) {
 
-				line, err := reader.ReadBytes('\n')
+		defer s.client.streams.Done()
+		defer close(done)
 
-				if err != nil {
+		reader := bufio.NewReader(body)
 
-					logrus.Warn(err)
+		for {
 
-					if reader, err = s.reconnect(endpoint); err != nil { // Did not recover subscription, break outer loop
-						break subLoop
-					}
+			line, err := reader.ReadBytes('\n')
+
+			if err != nil {
+
+				body.Close()
 
-					continue
+				if ctx.Err() != nil { // Subscription stopped
+					return
 				}
 
-				if strings.Contains(string(line), "\"type\":\"HEARTBEAT\"") { // Ignore heartbeats
-					continue
+				logrus.Warn(err)
+
+				if body, err = s.reconnect(ctx, endpoint); err != nil { // Did not recover subscription
+					if ctx.Err() == nil {
+						s.client.pricesFailed(s.accountID, errors.New("price stream not recovered: "+err.Error()))
+					}
+					return
 				}
 
-				data := Price{}
-				err = json.Unmarshal(line, &data)
+				reader = bufio.NewReader(body)
+				continue
+			}
+
+			if strings.Contains(string(line), "\"type\":\"HEARTBEAT\"") {
+
+				if s.heartbeatHandler != nil {
+
+					heartbeat := PricingHeartbeat{}
+					if err := json.Unmarshal(line, &heartbeat); err != nil {
+						logrus.Warn(err)
+						continue
+					}
 
-				if err != nil {
-					logrus.Warn(err)
-					continue
+					s.heartbeatHandler(heartbeat)
 				}
 
-				s.handler(data)
+				continue
 			}
-		}
 
-		s.activeSubscription = false
+			data := Price{}
+			err = json.Unmarshal(line, &data)
 
-	}(reader, endpoint)
+			if err != nil {
+				logrus.Warn(err)
+				continue
+			}
+
+			s.handler(data)
+		}
+
+	}(ctx, body, s.done)
 
 	return nil
 }
 
-func (s *PriceSubscription) reconnect(endpoint string) (reader *bufio.Reader, err error) {
+// SubscribePriceErrors defines the handler called when the price subscription of the account stops because
+// the stream can't be reconnected. Without a handler the error is logged.
+func (c *OandaClient) SubscribePriceErrors(accountID string, handler StreamErrorHandler) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.priceErrors[accountID] = handler
+}
+
+func (c *OandaClient) pricesFailed(accountID string, err error) {
+
+	c.mutex.Lock()
+	handler := c.priceErrors[accountID]
+	c.mutex.Unlock()
+
+	if handler == nil {
+		logrus.Error("price subscription stopped: " + err.Error())
+		return
+	}
+
+	handler(err)
+}
+
+func (s *PriceSubscription) reconnect(ctx context.Context, endpoint string) (body io.ReadCloser, err error) {
 
 	for i := 0; i < 3; i++ { // Try reconnection 3 times with exponential backoff
 
 		logrus.Info("Trying to recover subscription...")
 
-		time.Sleep(time.Duration(math.Pow(2, float64(i))) * 100 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(math.Pow(2, float64(i))) * 100 * time.Millisecond):
+		}
 
-		reader, err = s.dial(endpoint)
+		body, err = s.client.openStream(ctx, endpoint)
 
 		if err == nil {
 			logrus.Info("Subscription recovered")
+			s.client.stats.reconnected(PricingStream)
 			return
 		}
 	}
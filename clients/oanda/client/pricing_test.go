@@ -0,0 +1,45 @@
+package oandacl
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPriceSubscriptionReportsLostStream(t *testing.T) {
+
+	opened := false
+
+	client, closeServer := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if opened { // the reconnections fail
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"errorMessage":"Insufficient authorization to perform request."}`)
+			return
+		}
+
+		opened = true
+		fmt.Fprintln(w, `{"type":"PRICE","instrument":"EUR_USD","bids":[{"price":"1.1","liquidity":1000000}],"asks":[{"price":"1.1001","liquidity":1000000}]}`)
+	}))
+	defer closeServer()
+	defer client.Close()
+
+	client.streamURL = client.restURL
+
+	errs := make(chan error, 1)
+	client.SubscribePriceErrors("1", func(err error) { errs <- err })
+
+	if _, err := client.SubscribePrices("1", []string{"EUR_USD"}, func(price Price) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected the stream error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lost price stream was not reported")
+	}
+}
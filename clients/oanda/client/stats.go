@@ -0,0 +1,89 @@
+package oandacl
+
+import (
+	"sync"
+	"time"
+)
+
+// Streams of the reconnection counters
+const (
+	PricingStream      = "pricing"
+	TransactionsStream = "transactions"
+)
+
+// latencyBounds are the upper bounds, in seconds, of the request latency buckets
+var latencyBounds = []float64{0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Stats are the counters of the requests and streams of the client, for monitoring.
+type Stats struct {
+	Requests         map[int]int64    // requests by response status code, 0 for requests without response
+	LatencyBounds    []float64        // upper bounds of the request latency buckets, in seconds
+	LatencyCounts    []int64          // requests by latency bucket, the last one without upper bound
+	LatencySum       float64          // seconds
+	StreamReconnects map[string]int64 // reconnections by stream
+}
+
+type stats struct {
+	requests      map[int]int64
+	latencyCounts []int64
+	latencySum    float64
+	reconnects    map[string]int64
+	mutex         sync.Mutex
+}
+
+func newStats() *stats {
+	return &stats{
+		requests:      make(map[int]int64),
+		latencyCounts: make([]int64, len(latencyBounds)+1),
+		reconnects:    make(map[string]int64),
+	}
+}
+
+func (s *stats) request(statusCode int, latency time.Duration) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests[statusCode]++
+
+	seconds := latency.Seconds()
+	s.latencySum += seconds
+
+	bucket := 0
+	for bucket < len(latencyBounds) && seconds > latencyBounds[bucket] {
+		bucket++
+	}
+
+	s.latencyCounts[bucket]++
+}
+
+func (s *stats) reconnected(stream string) {
+	s.mutex.Lock()
+	s.reconnects[stream]++
+	s.mutex.Unlock()
+}
+
+// Stats returns a copy of the counters of the requests and streams of the client.
+func (c *OandaClient) Stats() Stats {
+
+	c.stats.mutex.Lock()
+	defer c.stats.mutex.Unlock()
+
+	stats := Stats{
+		Requests:         make(map[int]int64, len(c.stats.requests)),
+		LatencyBounds:    latencyBounds,
+		LatencyCounts:    append([]int64{}, c.stats.latencyCounts...),
+		LatencySum:       c.stats.latencySum,
+		StreamReconnects: make(map[string]int64, len(c.stats.reconnects)),
+	}
+
+	for code, count := range c.stats.requests {
+		stats.Requests[code] = count
+	}
+
+	for stream, count := range c.stats.reconnects {
+		stats.StreamReconnects[stream] = count
+	}
+
+	return stats
+}
@@ -2,6 +2,7 @@ package oandacl
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 )
 
@@ -20,6 +21,22 @@ type Trade struct {
 	RealizedPL   float64   `json:"realizedPL,string"`
 	State        string    `json:"state"`
 	UnrealizedPL float64   `json:"unrealizedPL,string"`
+
+	ClientExtensions *ClientExtensions `json:"clientExtensions"`
+}
+
+type StopLossDetails struct {
+	Price       string `json:"price"`
+	TimeInForce string `json:"timeInForce"`
+}
+
+type TradeOrdersRequest struct {
+	StopLoss *StopLossDetails `json:"stopLoss"`
+}
+
+type TradeOrdersResponse struct {
+	StopLossOrderTransaction *OrderCreateTransaction `json:"stopLossOrderTransaction"`
+	ErrorMessage             string                  `json:"errorMessage"`
 }
 
 type CloseTradeResponse struct {
@@ -71,10 +88,10 @@ func (c *OandaClient) CloseTrade(accountID, tradeID string) (CloseTradeResponse,
 
 	endpoint := "/accounts/" + accountID + "/trades/" + tradeID + "/close"
 
-	response, err := c.put(endpoint)
+	response, err := c.put(endpoint, nil)
 
 	if err != nil {
-		return CloseTradeResponse{}, nil
+		return CloseTradeResponse{}, err
 	}
 
 	data := CloseTradeResponse{}
@@ -87,3 +104,36 @@ func (c *OandaClient) CloseTrade(accountID, tradeID string) (CloseTradeResponse,
 	return data, nil
 
 }
+
+// SetTradeStopLoss creates or replaces the stop loss of a trade, the price must have the instrument precision.
+func (c *OandaClient) SetTradeStopLoss(accountID, tradeID, price string) (TradeOrdersResponse, error) {
+
+	endpoint := "/accounts/" + accountID + "/trades/" + tradeID + "/orders"
+
+	jsonBody, err := json.Marshal(TradeOrdersRequest{
+		StopLoss: &StopLossDetails{Price: price, TimeInForce: "GTC"},
+	})
+
+	if err != nil {
+		return TradeOrdersResponse{}, err
+	}
+
+	response, err := c.put(endpoint, jsonBody)
+
+	if err != nil {
+		return TradeOrdersResponse{}, err
+	}
+
+	data := TradeOrdersResponse{}
+	err = json.Unmarshal(response, &data)
+
+	if err != nil {
+		return TradeOrdersResponse{}, err
+	}
+
+	if data.ErrorMessage != "" {
+		return TradeOrdersResponse{}, errors.New(data.ErrorMessage)
+	}
+
+	return data, nil
+}
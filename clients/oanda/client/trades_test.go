@@ -0,0 +1,23 @@
+package oandacl
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCloseTradeError(t *testing.T) {
+
+	client, closeServer := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errorMessage":"The Trade specified does not exist"}`)
+	}))
+	defer closeServer()
+
+	_, err := client.CloseTrade("1", "100")
+
+	statusError, ok := err.(*StatusError)
+	if !ok || statusError.StatusCode != http.StatusNotFound || statusError.Message != "The Trade specified does not exist" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -3,7 +3,9 @@ package oandacl
 import (
 	"bufio"
 	"encoding/json"
-	"math"
+	"errors"
+	"io"
+	"strconv"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -35,14 +37,30 @@ type Transaction struct {
 	Time               time.Time            `json:"time"`
 	TradeOpened        *TradeOpened         `json:"tradeOpened"`
 	TradesClosed       []*TradeReduced      `json:"tradesClosed"`
+	TradeReduced       *TradeReduced        `json:"tradeReduced"`
 	PositionFinancings []*PositionFinancing `json:"positionFinancings"`
 	Type               string               `json:"type"`
 	Units              string               `json:"units"`
 	RejectReason       *string              `json:"rejectReason"`
 }
 
+// Transactions is the response of the transactions range endpoints.
+type Transactions struct {
+	Transactions      []*Transaction `json:"transactions"`
+	LastTransactionID string         `json:"lastTransactionID"`
+}
+
 type TransactionHandler func(transaction *Transaction)
 
+// StreamErrorHandler is called when a subscription stops on an error it can't recover from.
+type StreamErrorHandler func(err error)
+
+// Backoff of the transactions subscription reconnections
+const (
+	minReconnectBackoff = 100 * time.Millisecond
+	maxReconnectBackoff = time.Minute
+)
+
 type transactionTypeLogic struct {
 	orderFill     *atomic.Bool
 	orderCreate   *atomic.Bool
@@ -136,6 +154,7 @@ func (c *OandaClient) SubscribeTransactions(accountID string, transType []Transa
 		err := c.subscribeTransactions(accountID, handler)
 
 		if err != nil {
+			delete(c.transactionSubscriptions, accountID)
 			return err
 		}
 
@@ -162,67 +181,241 @@ func (c *OandaClient) SubscribeTransactions(accountID string, transType []Transa
 	return nil
 }
 
+// SubscribeTransactionErrors defines the handler called when the transactions subscription of the account
+// stops on an error it can't recover from, such as an invalid token. Without a handler the error is logged.
+func (c *OandaClient) SubscribeTransactionErrors(accountID string, handler StreamErrorHandler) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.transactionErrors[accountID] = handler
+}
+
+// GetTransactionsSinceID returns the transactions of the account after the given transaction ID, in order.
+func (c *OandaClient) GetTransactionsSinceID(accountID, id string) (Transactions, error) {
+
+	endpoint := "/accounts/" + accountID + "/transactions/sinceid?id=" + id
+
+	response, err := c.get(endpoint)
+
+	if err != nil {
+		return Transactions{}, err
+	}
+
+	data := Transactions{}
+	err = json.Unmarshal(response, &data)
+
+	if err != nil {
+		return Transactions{}, err
+	}
+
+	return data, nil
+}
+
 func (c *OandaClient) subscribeTransactions(accountID string, handler TransactionHandler) error {
 
-	endpoint := "/accounts/" + accountID + "/transactions/stream"
-	reader, err := c.dial(endpoint)
+	summary, err := c.GetAccountSummary(accountID)
+
+	if err != nil {
+		return err
+	}
+
+	stream := &transactionStream{
+		client:    c,
+		accountID: accountID,
+		endpoint:  "/accounts/" + accountID + "/transactions/stream",
+		handler:   handler,
+	}
+
+	// Transactions after the account summary are replayed once the stream is open
+	stream.lastID, err = strconv.ParseInt(summary.Account.LastTransactionID, 10, 64)
+
+	if err != nil {
+		return errors.New("invalid last transaction ID of account " + accountID)
+	}
+
+	body, err := c.openStream(c.ctx, stream.endpoint)
 
 	if err != nil {
 		return err
 	}
 
-	go func(reader *bufio.Reader, endpoint string) {
+	c.streams.Add(1)
 
-	subLoop:
-		for {
+	go func() {
+		defer c.streams.Done()
+		stream.run(body)
+	}()
 
-			line, err := reader.ReadBytes('\n')
+	return nil
+}
 
-			if err != nil {
+func (c *OandaClient) transactionsFailed(accountID string, err error) {
 
-				logrus.Warn(err)
+	c.mutex.Lock()
+	handler := c.transactionErrors[accountID]
+	delete(c.transactionSubscriptions, accountID) // the account can be subscribed again
+	c.mutex.Unlock()
 
-				if reader, err = c.reconnect(endpoint); err != nil { // Did not recover subscription, break outer loop
-					break subLoop
-				}
+	if handler == nil {
+		logrus.Error("transactions subscription stopped: " + err.Error())
+		return
+	}
 
-				continue
-			}
+	handler(err)
+}
+
+// transactionStream delivers the transactions of an account in order and without gaps: every time the stream
+// is (re)opened, the transactions after the last one processed are replayed before the stream is read, and the
+// streamed transactions already replayed are skipped.
+type transactionStream struct {
+	client    *OandaClient
+	accountID string
+	endpoint  string
+	handler   TransactionHandler
+	lastID    int64 // last transaction processed
+}
 
-			data := &Transaction{}
-			err = json.Unmarshal(line, data)
+func (s *transactionStream) run(body io.ReadCloser) {
 
-			if err != nil {
-				logrus.Warn(err)
-				continue
-			}
+	err := s.replay()
+
+	for {
+
+		if err == nil {
 
-			if c.transactionSubscriptions[accountID].checkIgnore(data) {
-				continue
+			var line []byte
+			reader := bufio.NewReader(body)
+
+			for err == nil {
+				if line, err = reader.ReadBytes('\n'); err == nil {
+					s.processLine(line)
+				}
 			}
+		}
 
-			handler(data)
+		body.Close()
+
+		if s.client.ctx.Err() != nil { // Client closed
+			return
 		}
-	}(reader, endpoint)
 
-	return nil
+		logrus.Warn(err)
+
+		if body, err = s.reconnect(); err != nil {
+
+			if s.client.ctx.Err() == nil { // Did not recover subscription, the error is reported
+				s.client.transactionsFailed(s.accountID, err)
+			}
+
+			return
+		}
+	}
 }
 
-func (c *OandaClient) reconnect(endpoint string) (reader *bufio.Reader, err error) {
+// reconnect reopens the stream and replays the missed transactions, retrying with capped exponential backoff
+// until it succeeds or the broker rejects the subscription.
+func (s *transactionStream) reconnect() (io.ReadCloser, error) {
 
-	for i := 0; i < 3; i++ { // Try reconnection 3 times with exponential backoff
+	backoff := minReconnectBackoff
+
+	for {
 
 		logrus.Debug("Trying to recover subscription...")
 
-		time.Sleep(time.Duration(math.Pow(2, float64(i))) * 100 * time.Millisecond)
+		select {
+		case <-s.client.ctx.Done():
+			return nil, s.client.ctx.Err()
+		case <-time.After(backoff):
+		}
 
-		reader, err = c.dial(endpoint)
+		body, err := s.client.openStream(s.client.ctx, s.endpoint)
 
 		if err == nil {
-			logrus.Debug("Subscription recovered")
-			return
+
+			if err = s.replay(); err == nil {
+				logrus.Debug("Subscription recovered")
+				s.client.stats.reconnected(TransactionsStream)
+				return body, nil
+			}
+
+			body.Close()
 		}
+
+		if statusErr, ok := err.(*StatusError); ok && !statusErr.Temporary() {
+			return nil, err
+		}
+
+		logrus.Warn(err)
+
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+	}
+}
+
+// replay processes the transactions after the last one processed.
+func (s *transactionStream) replay() error {
+
+	for {
+
+		lastID := s.lastID
+
+		data, err := s.client.GetTransactionsSinceID(s.accountID, strconv.FormatInt(lastID, 10))
+
+		if err != nil {
+			return err
+		}
+
+		for _, transaction := range data.Transactions {
+			s.process(transaction)
+		}
+
+		if s.lastID == lastID { // no more transactions
+			return nil
+		}
+	}
+}
+
+func (s *transactionStream) processLine(line []byte) {
+
+	data := &Transaction{}
+	err := json.Unmarshal(line, data)
+
+	if err != nil {
+		logrus.Warn(err)
+		return
+	}
+
+	s.process(data)
+}
+
+func (s *transactionStream) process(transaction *Transaction) {
+
+	if transaction.Type == "HEARTBEAT" {
+		return
+	}
+
+	id, err := strconv.ParseInt(transaction.ID, 10, 64)
+
+	if err != nil {
+		logrus.Warn("invalid transaction ID " + transaction.ID)
+		return
+	}
+
+	if id <= s.lastID { // already processed
+		return
+	}
+
+	s.lastID = id
+
+	s.client.mutex.Lock()
+	logic, exist := s.client.transactionSubscriptions[s.accountID]
+	s.client.mutex.Unlock()
+
+	if !exist || logic.checkIgnore(transaction) {
+		return
 	}
 
-	return
+	s.handler(transaction)
 }
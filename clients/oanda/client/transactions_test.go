@@ -0,0 +1,149 @@
+package oandacl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTransactionsServer serves the transactions stream of an account: the first connection streams
+// transactions 2 and 3 and disconnects, meanwhile transactions 4 and 5 happen, and the following connections
+// stream 5 and 6 or, once unauthorized, are rejected.
+type fakeTransactionsServer struct {
+	mutex        sync.Mutex
+	connections  int
+	unauthorized bool
+}
+
+func fill(id int) string {
+	return fmt.Sprintf(`{"id":"%d","type":"ORDER_FILL","instrument":"EUR_USD","units":"1"}`, id)
+}
+
+func (s *fakeTransactionsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.unauthorized {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"errorMessage":"Insufficient authorization to perform request."}`)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/summary"):
+		fmt.Fprint(w, `{"account":{"lastTransactionID":"1"}}`)
+
+	case strings.HasSuffix(r.URL.Path, "/sinceid"):
+
+		last := 3 // transactions 4 and 5 happen after the first connection
+		if s.connections > 1 {
+			last = 5
+		}
+
+		since, _ := strconv.Atoi(r.URL.Query().Get("id"))
+
+		var replay []string
+		for id := since + 1; id <= last; id++ {
+			replay = append(replay, fill(id))
+		}
+
+		fmt.Fprint(w, `{"transactions":[`+strings.Join(replay, ",")+`],"lastTransactionID":"5"}`)
+
+	case strings.HasSuffix(r.URL.Path, "/transactions/stream"):
+
+		s.connections++
+
+		if s.connections == 1 {
+			fmt.Fprintln(w, fill(2))
+			fmt.Fprintln(w, `{"type":"HEARTBEAT","lastTransactionID":"3"}`)
+			fmt.Fprintln(w, fill(3))
+			return
+		}
+
+		fmt.Fprintln(w, fill(5))
+		fmt.Fprintln(w, fill(6))
+	}
+}
+
+func TestSubscribeTransactionsReplaysMissedTransactions(t *testing.T) {
+
+	fake := &fakeTransactionsServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := NewClient("token", false)
+	client.restURL = server.URL + "/v3"
+	client.streamURL = server.URL + "/v3"
+
+	var (
+		mutex    sync.Mutex
+		received []string
+		failed   = make(chan error, 1)
+	)
+
+	client.SubscribeTransactionErrors("account", func(err error) { failed <- err })
+
+	err := client.SubscribeTransactions("account", []TransactionType{OrderFill}, func(transaction *Transaction) {
+
+		mutex.Lock()
+		received = append(received, transaction.ID)
+		mutex.Unlock()
+
+		if transaction.ID == "6" { // reject the next reconnection
+			fake.mutex.Lock()
+			fake.unauthorized = true
+			fake.mutex.Unlock()
+		}
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-failed:
+		statusErr, ok := err.(*StatusError)
+		if !ok || statusErr.StatusCode != http.StatusUnauthorized || statusErr.Temporary() {
+			t.Errorf("expected an unauthorized status error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscription error was not reported")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if got := strings.Join(received, ","); got != "2,3,4,5,6" {
+		t.Errorf("expected transactions 2,3,4,5,6 once and in order, got %s", got)
+	}
+
+	if stats := client.Stats(); stats.StreamReconnects[TransactionsStream] != 1 || stats.Requests[http.StatusOK] == 0 {
+		t.Errorf("expected a reconnection and the successful requests to be counted, got %+v", stats)
+	}
+}
+
+func TestGetTransactionsSinceIDError(t *testing.T) {
+
+	client, closeServer := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errorMessage":"The transaction ID specified does not exist"}`)
+	}))
+	defer closeServer()
+
+	_, err := client.GetTransactionsSinceID("1", "100")
+
+	statusError, ok := err.(*StatusError)
+	if !ok || statusError.StatusCode != http.StatusNotFound || statusError.Message != "The transaction ID specified does not exist" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if stats := client.Stats(); stats.Requests[http.StatusNotFound] != 1 {
+		t.Errorf("request not counted in the stats %+v", stats.Requests)
+	}
+}
@@ -1,8 +1,11 @@
 package oanda
 
 import (
+	"errors"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/luismcruz/gotrader"
 
@@ -35,9 +38,14 @@ func (c *oandaClientWrapper) GetAccountStatus(accountID string) (gotrader.Accoun
 		return gotrader.AccountStatus{}, err
 	}
 
+	hedge := gotrader.NoHedge // positions are netted without hedging
+	if accountSummary.Account.HedgingEnabled {
+		hedge = gotrader.HalfHedge
+	}
+
 	resp := gotrader.AccountStatus{
 		Currency:              accountSummary.Account.Currency,
-		Hedge:                 gotrader.HalfHedge,
+		Hedge:                 hedge,
 		Equity:                accountSummary.Account.NAV,
 		Balance:               accountSummary.Account.Balance,
 		UnrealizedGrossProfit: accountSummary.Account.UnrealizedPL,
@@ -66,11 +74,15 @@ func (c *oandaClientWrapper) GetAvailableInstruments(accountID string) ([]gotrad
 		ccys := strings.Split(inst.Name, "_")
 
 		newInst := gotrader.InstrumentDetails{
-			Name:          inst.Name,
-			BaseCurrency:  ccys[0],
-			QuoteCurrency: ccys[1],
-			Leverage:      1 / inst.MarginRate,
-			PipLocation:   inst.PipLocation,
+			Name:                inst.Name,
+			BaseCurrency:        ccys[0],
+			QuoteCurrency:       ccys[1],
+			Leverage:            1 / inst.MarginRate,
+			PipLocation:         inst.PipLocation,
+			DisplayPrecision:    inst.DisplayPrecision,
+			MinimumTradeSize:    inst.MinimumTradeSize,
+			TradeUnitsPrecision: inst.TradeUnitsPrecision,
+			MaximumOrderUnits:   inst.MaximumOrderUnits,
 		}
 
 		if _, exist := c.instrumentsDetails[inst.Name]; !exist {
@@ -95,6 +107,20 @@ func (c *oandaClientWrapper) OpenMarketOrder(accountID, instrument string, units
 	return nil
 }
 
+func (c *oandaClientWrapper) OpenTaggedMarketOrder(accountID, instrument string, units int32, side, tag string) error {
+
+	_, err := c.client.CreateTaggedMarketOrder(accountID, instrument, side, units, tag)
+
+	return err
+}
+
+func (c *oandaClientWrapper) SetStopLoss(accountID, id string, price float64) error {
+
+	_, err := c.client.SetTradeStopLoss(accountID, id, strconv.FormatFloat(price, 'f', -1, 64))
+
+	return err
+}
+
 func (c *oandaClientWrapper) CloseTrade(accountID, id string) error {
 
 	_, err := c.client.CloseTrade(accountID, id)
@@ -135,6 +161,7 @@ func (c *oandaClientWrapper) GetOpenTrades(accountID string) ([]gotrader.TradeDe
 			OpenPrice:   tr.Price,
 			ChargedFees: tr.Financing,
 			OpenTime:    tr.OpenTime,
+			Tag:         extensionsTag(tr.ClientExtensions),
 		}
 
 	}
@@ -151,19 +178,56 @@ func (c *oandaClientWrapper) SubscribePrices(accountID string, instruments []got
 		instrumentsStrings[i] = inst.Name
 	}
 
-	if c.priceSubscription == nil {
-		c.priceSubscription = &priceSubscription{handler: callback}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	subscription := c.getPriceSubscription()
+	if subscription.handler == nil {
+		subscription.handler = callback
 	}
 
-	_, err := c.client.SubscribePrices(accountID, instrumentsStrings, c.priceSubscription.priceHandler)
+	priceSubscription, err := c.client.SubscribePricesWithHeartbeats(accountID, instrumentsStrings,
+		subscription.priceHandler, subscription.heartbeatHandler)
 
 	if err != nil {
 		return err
 	}
 
+	subscription.subscriptions[accountID] = priceSubscription
+
+	return nil
+}
+
+func (c *oandaClientWrapper) SubscribeHeartbeats(accountID string, heartbeatCallback gotrader.HeartbeatHandler) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.getPriceSubscription().heartbeat = heartbeatCallback
+
 	return nil
 }
 
+func (c *oandaClientWrapper) ResubscribePrices(accountID string) error {
+	c.mutex.Lock()
+	subscription, exist := c.getPriceSubscription().subscriptions[accountID]
+	c.mutex.Unlock()
+
+	if !exist {
+		return errors.New("prices of account " + accountID + " are not subscribed")
+	}
+
+	return subscription.Resubscribe()
+}
+
+func (c *oandaClientWrapper) getPriceSubscription() *priceSubscription {
+
+	if c.priceSubscription == nil {
+		c.priceSubscription = &priceSubscription{subscriptions: make(map[string]*oandacl.PriceSubscription)}
+	}
+
+	return c.priceSubscription
+}
+
 func (c *oandaClientWrapper) SubscribeOrderFillNotifications(accountID string, orderFillCallback gotrader.OrderFillHandler) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -227,22 +291,84 @@ func (c *oandaClientWrapper) SubscribeFundsTransferNotifications(accountID strin
 	return nil
 }
 
+func (c *oandaClientWrapper) Close() error {
+	return c.client.Close()
+}
+
+func (c *oandaClientWrapper) SubscribeStreamErrors(accountID string, errorCallback gotrader.StreamErrorHandler) error {
+
+	c.client.SubscribeTransactionErrors(accountID, oandacl.StreamErrorHandler(errorCallback))
+	c.client.SubscribePriceErrors(accountID, oandacl.StreamErrorHandler(errorCallback))
+
+	return nil
+}
+
+func (c *oandaClientWrapper) Metrics() gotrader.ClientMetrics {
+
+	stats := c.client.Stats()
+
+	return gotrader.ClientMetrics{
+		Requests: stats.Requests,
+		RequestLatency: gotrader.Histogram{
+			Bounds: stats.LatencyBounds,
+			Counts: stats.LatencyCounts,
+			Sum:    stats.LatencySum,
+		},
+		StreamReconnects: stats.StreamReconnects,
+	}
+}
+
+func extensionsTag(extensions *oandacl.ClientExtensions) string {
+
+	if extensions == nil || extensions.Tag == nil {
+		return ""
+	}
+
+	return *extensions.Tag
+}
+
 type priceSubscription struct {
-	handler gotrader.TickHandler
+	handler       gotrader.TickHandler
+	heartbeat     gotrader.HeartbeatHandler
+	subscriptions map[string]*oandacl.PriceSubscription // by account
 }
 
 func (p *priceSubscription) priceHandler(price oandacl.Price) {
 
 	tick := &gotrader.Tick{
-		Instrument: price.Instrument,
-		Bid:        price.Bids[0].Price,
-		Ask:        price.Asks[0].Price,
-		Time:       price.Time,
+		Instrument:  price.Instrument,
+		Bid:         price.Bids[0].Price,
+		Ask:         price.Asks[0].Price,
+		Time:        price.Time,
+		Bids:        priceLevels(price.Bids),
+		Asks:        priceLevels(price.Asks),
+		CloseoutBid: price.CloseoutBid,
+		CloseoutAsk: price.CloseoutAsk,
 	}
 
 	p.handler(tick)
 }
 
+func (p *priceSubscription) heartbeatHandler(heartbeat oandacl.PricingHeartbeat) {
+	if p.heartbeat != nil {
+		p.heartbeat(heartbeat.Time)
+	}
+}
+
+func priceLevels(buckets []oandacl.PriceBucket) []gotrader.PriceLevel {
+
+	levels := make([]gotrader.PriceLevel, len(buckets), len(buckets))
+
+	for i, bucket := range buckets {
+		levels[i] = gotrader.PriceLevel{
+			Price:     bucket.Price,
+			Liquidity: int64(bucket.Liquidity),
+		}
+	}
+
+	return levels
+}
+
 type transactionSubscription struct {
 	insturmentDetails     map[string]gotrader.InstrumentDetails
 	orderFillCallback     gotrader.OrderFillHandler
@@ -254,52 +380,55 @@ func (t *transactionSubscription) transactionHandler(transaction *oandacl.Transa
 
 	if transaction.Type == "ORDER_FILL" && t.orderFillCallback != nil {
 
-		var (
-			orderFill *gotrader.OrderFill
-			side      = gotrader.Long
-		)
-
 		if transaction.RejectReason != nil {
+			t.orderFillCallback(&gotrader.OrderFill{
+				Error:      *transaction.RejectReason,
+				Instrument: t.insturmentDetails[transaction.Instrument],
+				Time:       transaction.Time,
+			})
+			return
+		}
 
-			orderFill = &gotrader.OrderFill{
-				Error: *transaction.RejectReason,
-			}
+		// Without hedging, a fill closes or reduces the opposite trades before opening a trade with the rest
+		closed := transaction.TradesClosed
+		if transaction.TradeReduced != nil {
+			closed = append(closed, transaction.TradeReduced)
+		}
 
-			t.orderFillCallback(orderFill)
+		for _, trade := range closed {
 
-		} else if transaction.TradesClosed != nil {
+			side := gotrader.Long
 
-			for _, trade := range transaction.TradesClosed {
+			if trade.Units > 0 { // Closing short trade
+				side = gotrader.Short
+			} else {
+				trade.Units = -trade.Units
+			}
 
-				if trade.Units > 0 { // Closing short trade
-					side = gotrader.Short
-				} else {
-					trade.Units = -trade.Units
-				}
+			t.orderFillCallback(&gotrader.OrderFill{
+				TradeClose:  true,
+				OrderID:     transaction.OrderID,
+				TradeID:     trade.TradeID,
+				Side:        side,
+				Instrument:  t.insturmentDetails[transaction.Instrument],
+				Price:       trade.Price,
+				Units:       trade.Units,
+				Profit:      trade.RealizedPL,
+				ChargedFees: trade.Financing,
+				Time:        transaction.Time,
+			})
+		}
 
-				orderFill = &gotrader.OrderFill{
-					TradeClose:  true,
-					OrderID:     transaction.OrderID,
-					TradeID:     trade.TradeID,
-					Side:        side,
-					Instrument:  t.insturmentDetails[transaction.Instrument],
-					Price:       trade.Price,
-					Units:       trade.Units,
-					Profit:      trade.RealizedPL,
-					ChargedFees: trade.Financing,
-					Time:        transaction.Time,
-				}
+		if transaction.TradeOpened != nil {
 
-				t.orderFillCallback(orderFill)
-			}
-		} else if transaction.TradeOpened != nil {
+			side := gotrader.Long
 
 			if transaction.TradeOpened.Units < 0 {
 				side = gotrader.Short
 				transaction.TradeOpened.Units = -transaction.TradeOpened.Units
 			}
 
-			orderFill = &gotrader.OrderFill{
+			t.orderFillCallback(&gotrader.OrderFill{
 				TradeClose: false,
 				OrderID:    transaction.OrderID,
 				TradeID:    transaction.TradeOpened.TradeID,
@@ -308,9 +437,8 @@ func (t *transactionSubscription) transactionHandler(transaction *oandacl.Transa
 				Price:      transaction.TradeOpened.Price,
 				Units:      transaction.TradeOpened.Units,
 				Time:       transaction.Time,
-			}
-
-			t.orderFillCallback(orderFill)
+				Tag:        extensionsTag(transaction.TradeOpened.ClientExtensions),
+			})
 		}
 
 	} else if transaction.Type == "TRANSFER_FUNDS" && t.fundsTransferCallback != nil {
@@ -357,3 +485,65 @@ func (t *transactionSubscription) transactionHandler(transaction *oandacl.Transa
 		t.swapChargeCallback(charge)
 	}
 }
+
+var granularities = map[gotrader.Timeframe]string{
+	gotrader.S5: "S5", gotrader.S10: "S10", gotrader.S15: "S15", gotrader.S30: "S30",
+	gotrader.M1: "M1", gotrader.M2: "M2", gotrader.M4: "M4", gotrader.M5: "M5",
+	gotrader.M10: "M10", gotrader.M15: "M15", gotrader.M30: "M30",
+	gotrader.H1: "H1", gotrader.H2: "H2", gotrader.H3: "H3", gotrader.H4: "H4",
+	gotrader.H6: "H6", gotrader.H8: "H8", gotrader.H12: "H12",
+	gotrader.D1: "D",
+}
+
+func (c *oandaClientWrapper) GetBars(request gotrader.BarsRequest) ([]*gotrader.Bar, error) {
+
+	granularity, exist := granularities[request.Timeframe]
+	if !exist {
+		return nil, errors.New("timeframe " + request.Timeframe.String() + " is not supported by oanda")
+	}
+
+	location := request.AlignLocation
+	if location == nil {
+		location = time.UTC
+	}
+
+	candles, err := c.client.GetCandlesRange(request.Instrument, oandacl.CandlesRequest{
+		Granularity:       granularity,
+		Price:             "BAM",
+		From:              request.From,
+		To:                request.To,
+		Count:             request.Count,
+		DailyAlignment:    request.AlignHour,
+		AlignmentTimezone: location.String(),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	bars := make([]*gotrader.Bar, len(candles.Candles), len(candles.Candles))
+
+	for i, candle := range candles.Candles {
+		bars[i] = &gotrader.Bar{
+			Instrument: request.Instrument,
+			Timeframe:  request.Timeframe,
+			Time:       candle.Time,
+			Bid:        ohlc(candle.Bid),
+			Ask:        ohlc(candle.Ask),
+			Mid:        ohlc(candle.Mid),
+			Volume:     int64(candle.Volume),
+			Complete:   candle.Complete,
+		}
+	}
+
+	return bars, nil
+}
+
+func ohlc(data *oandacl.CandlestickData) gotrader.OHLC {
+
+	if data == nil {
+		return gotrader.OHLC{}
+	}
+
+	return gotrader.OHLC{Open: data.O, High: data.H, Low: data.L, Close: data.C}
+}
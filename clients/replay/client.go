@@ -0,0 +1,94 @@
+package replay
+
+import (
+	"io"
+
+	"github.com/luismcruz/gotrader"
+)
+
+type replayClient struct {
+	gotrader.BrokerClient
+	files         []string
+	instruments   []gotrader.InstrumentDetails
+	errorCallback gotrader.StreamErrorHandler
+}
+
+// NewReplayClient is the replay client constructor. It feeds to the backtest engine, in order,
+// the ticks stored in files written by a gotrader.Recorder (see gotrader.RecordFiles).
+// If instruments is nil, the instruments details stored in the files are used.
+// Recorded order fills, swap charges and funds transfers are skipped, the backtest engine simulates
+// its own. A corrupted file is reported to the stream error handler before the replay ends.
+func NewReplayClient(files []string, instruments []gotrader.InstrumentDetails) gotrader.BrokerClient {
+	return &replayClient{
+		files:       files,
+		instruments: instruments,
+	}
+}
+
+func (c *replayClient) GetAvailableInstruments(accountID string) ([]gotrader.InstrumentDetails, error) {
+
+	if c.instruments != nil {
+		return c.instruments, nil
+	}
+
+	reader := gotrader.NewRecordReader(c.files...)
+	defer reader.Close()
+
+	for { // instruments are stored before the first tick
+
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if record.Tick != nil {
+			break
+		}
+
+		c.instruments = append(c.instruments, record.Instruments...)
+	}
+
+	return c.instruments, nil
+}
+
+func (c *replayClient) SubscribeStreamErrors(accountID string, errorCallback gotrader.StreamErrorHandler) error {
+	c.errorCallback = errorCallback
+	return nil
+}
+
+func (c *replayClient) SubscribePrices(accountID string, instruments []gotrader.InstrumentDetails, callback gotrader.TickHandler) error {
+
+	subscribed := make(map[string]bool, len(instruments))
+	for _, inst := range instruments {
+		subscribed[inst.Name] = true
+	}
+
+	go func() {
+
+		reader := gotrader.NewRecordReader(c.files...)
+		defer reader.Close()
+
+		for {
+
+			record, err := reader.Next()
+			if err != nil {
+				if err != io.EOF && c.errorCallback != nil {
+					c.errorCallback(err)
+				}
+				break
+			}
+
+			if record.Tick != nil && subscribed[record.Tick.Instrument] {
+				callback(record.Tick)
+			}
+		}
+
+		callback(nil)
+
+	}()
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package replay
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/luismcruz/gotrader"
+)
+
+func TestReplayIsBitExact(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	prefix := filepath.Join(dir, "ticks")
+
+	instruments := []gotrader.InstrumentDetails{
+		{Name: "EUR_USD", BaseCurrency: "EUR", QuoteCurrency: "USD", Leverage: 30, PipLocation: -4},
+	}
+
+	start := time.Date(2020, 7, 6, 12, 0, 0, 123456789, time.UTC)
+
+	ticks := make([]*gotrader.Tick, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		bid := 1.1 + float64(i)*0.1/3 // values without exact decimal representation
+		ticks = append(ticks, &gotrader.Tick{
+			Instrument:  "EUR_USD",
+			Bid:         bid,
+			Ask:         bid + 0.00013,
+			Time:        start.Add(time.Duration(i) * 333 * time.Millisecond),
+			Bids:        []gotrader.PriceLevel{{Price: bid, Liquidity: 1000000}, {Price: bid - 0.0001, Liquidity: 5000000}},
+			Asks:        []gotrader.PriceLevel{{Price: bid + 0.00013, Liquidity: 1000000}},
+			CloseoutBid: bid - 0.0002,
+			CloseoutAsk: bid + 0.00033,
+		})
+	}
+
+	recorder, err := gotrader.NewRecorder(prefix, 4096) // small files to force rotation
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recorder.RecordInstruments(instruments); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tick := range ticks {
+		if err := recorder.RecordTick(tick); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := gotrader.RecordFiles(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) < 2 {
+		t.Fatalf("expected rotated files, got %d", len(files))
+	}
+
+	client := NewReplayClient(files, nil)
+
+	available, err := client.GetAvailableInstruments("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(available, instruments) {
+		t.Fatalf("instruments = %v, want %v", available, instruments)
+	}
+
+	replayed := make(chan *gotrader.Tick, len(ticks)+1)
+	if err := client.SubscribePrices("", instruments, func(tick *gotrader.Tick) { replayed <- tick }); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range ticks {
+
+		got := <-replayed
+
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("tick %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	if tick := <-replayed; tick != nil {
+		t.Fatalf("expected end of replay, got %+v", tick)
+	}
+}
+
+func TestReplaySkipsEventsAndReportsErrors(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	prefix := filepath.Join(dir, "events")
+	instrument := gotrader.InstrumentDetails{Name: "EUR_USD", BaseCurrency: "EUR", QuoteCurrency: "USD"}
+	start := time.Date(2020, 7, 6, 12, 0, 0, 0, time.UTC)
+
+	recorder, err := gotrader.NewRecorder(prefix, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []error{
+		recorder.RecordInstruments([]gotrader.InstrumentDetails{instrument}),
+		recorder.RecordTick(&gotrader.Tick{Instrument: "EUR_USD", Bid: 1.1, Ask: 1.1002, Time: start}),
+		recorder.RecordOrderFill(&gotrader.OrderFill{OrderID: "1", TradeID: "2", Instrument: instrument, Units: 1000, Time: start, Tag: "session"}),
+		recorder.RecordOrderFill(&gotrader.OrderFill{Error: gotrader.MaxUnitsRule, Instrument: instrument, Units: 1000, Time: start,
+			Rejection: &gotrader.OrderRejection{Rule: gotrader.MaxUnitsRule, Reason: "limit"}}),
+		recorder.RecordSwapCharge(&gotrader.SwapCharge{Time: start, Charges: []*gotrader.TradeSwapCharge{{ID: "2", Ammount: -0.1, Instrument: instrument}}}),
+		recorder.RecordFundsTransfer(&gotrader.FundsTransfer{Ammount: 100, Time: start}),
+		recorder.RecordTick(&gotrader.Tick{Instrument: "EUR_USD", Bid: 1.1001, Ask: 1.1003, Time: start.Add(time.Second)}),
+		recorder.Close(),
+	}
+
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A truncated record at the end of a second file
+	corrupted := append([]byte("GTREC\x01"), 1, 100, 0)
+	if err := ioutil.WriteFile(prefix+".000001", corrupted, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := gotrader.RecordFiles(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fills are recorded with their tag and rejection
+	reader := gotrader.NewRecordReader(files...)
+	var fills []*gotrader.OrderFill
+
+	for record, err := reader.Next(); err == nil; record, err = reader.Next() {
+		if record.OrderFill != nil {
+			fills = append(fills, record.OrderFill)
+		}
+	}
+	reader.Close()
+
+	if len(fills) != 2 || fills[0].Tag != "session" || fills[0].Rejection != nil ||
+		fills[1].Rejection == nil || fills[1].Rejection.Rule != gotrader.MaxUnitsRule || fills[1].Rejection.Reason != "limit" {
+		t.Fatalf("unexpected recorded fills %+v", fills)
+	}
+
+	client := NewReplayClient(files, nil)
+	events := make(chan string, 10)
+
+	client.(gotrader.StreamErrorClient).SubscribeStreamErrors("", func(err error) { events <- "error" })
+
+	err = client.SubscribePrices("", []gotrader.InstrumentDetails{instrument}, func(tick *gotrader.Tick) {
+		if tick == nil {
+			events <- "end"
+			return
+		}
+		events <- "tick"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The backtest engine simulates its own fills, only the ticks are replayed
+	expected := []string{"tick", "tick", "error", "end"}
+
+	for i, want := range expected {
+		if got := <-events; got != want {
+			t.Fatalf("event %d = %s, want %s", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gotrader
+
+// FillPrice estimates the volume weighted average price of a market order of the given units,
+// walking the ask ladder for Long orders and the bid ladder for Short orders.
+// It returns the units that the ladder can fill, which can be lower than the requested units.
+// Without depth information the top of the book is used and the order is assumed to be filled.
+func (t *Tick) FillPrice(side Side, units int32) (price float64, filled int32) {
+
+	levels := t.levels(side)
+
+	if len(levels) == 0 {
+		if side == Long {
+			return t.Ask, units
+		}
+		return t.Bid, units
+	}
+
+	var (
+		remaining = int64(units)
+		notional  float64
+	)
+
+	for _, level := range levels {
+
+		if remaining <= 0 {
+			break
+		}
+
+		levelUnits := level.Liquidity
+		if levelUnits > remaining {
+			levelUnits = remaining
+		}
+
+		notional += level.Price * float64(levelUnits)
+		remaining -= levelUnits
+	}
+
+	filled = units - int32(remaining)
+
+	if filled == 0 {
+		return 0, 0
+	}
+
+	return notional / float64(filled), filled
+}
+
+// Liquidity returns the total liquidity available in the ladder used by orders of the given side,
+// or zero if the broker does not provide depth.
+func (t *Tick) Liquidity(side Side) int64 {
+
+	var liquidity int64
+
+	for _, level := range t.levels(side) {
+		liquidity += level.Liquidity
+	}
+
+	return liquidity
+}
+
+// UnitsWithinSlippage returns the maximum units that can be filled with an average price
+// at most slippage away from the top of the book.
+func (t *Tick) UnitsWithinSlippage(side Side, slippage float64) int64 {
+
+	levels := t.levels(side)
+
+	if len(levels) == 0 {
+		return 0
+	}
+
+	var (
+		top      = levels[0].Price
+		sign     = sideSign(side)
+		units    int64
+		notional float64
+	)
+
+	for _, level := range levels {
+
+		if level.Liquidity <= 0 { // Empty levels don't move the average price
+			continue
+		}
+
+		newUnits := units + level.Liquidity
+		newNotional := notional + level.Price*float64(level.Liquidity)
+
+		if (newNotional/float64(newUnits)-top)*sign <= slippage {
+			units, notional = newUnits, newNotional
+			continue
+		}
+
+		// Partially consume the level up to the slippage limit:
+		// (notional + p*x) / (units + x) = top + sign*slippage
+		limit := top + sign*slippage
+		if denominator := level.Price - limit; denominator != 0 {
+			if x := int64((limit*float64(units) - notional) / denominator); x > 0 {
+				units += x
+			}
+		}
+
+		break
+	}
+
+	return units
+}
+
+func (t *Tick) levels(side Side) []PriceLevel {
+
+	if side == Long {
+		return t.Asks
+	}
+
+	return t.Bids
+}
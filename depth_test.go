@@ -0,0 +1,79 @@
+package gotrader
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTickDepth(t *testing.T) {
+
+	tick := &Tick{
+		Bid: 1.1,
+		Ask: 1.1002,
+		Bids: []PriceLevel{
+			{Price: 1.1, Liquidity: 1000},
+			{Price: 1.0998, Liquidity: 3000},
+		},
+		Asks: []PriceLevel{
+			{Price: 1.1002, Liquidity: 1000},
+			{Price: 1.1004, Liquidity: 1000},
+			{Price: 1.1010, Liquidity: 2000},
+		},
+	}
+
+	fillTests := []struct {
+		side   Side
+		units  int32
+		price  float64
+		filled int32
+	}{
+		{Long, 500, 1.1002, 500},
+		{Long, 2000, 1.1003, 2000},
+		{Long, 5000, (1.1002 + 1.1004 + 2*1.1010) / 4, 4000},
+		{Short, 2000, 1.0999, 2000},
+	}
+
+	for _, test := range fillTests {
+		price, filled := tick.FillPrice(test.side, test.units)
+		if math.Abs(price-test.price) > 1e-9 || filled != test.filled {
+			t.Errorf("fill of %d %v units: %v %d, expected %v %d", test.units, test.side, price, filled, test.price, test.filled)
+		}
+	}
+
+	if tick.Liquidity(Long) != 4000 || tick.Liquidity(Short) != 4000 {
+		t.Errorf("unexpected liquidity %d %d", tick.Liquidity(Long), tick.Liquidity(Short))
+	}
+
+	slippageTests := []struct {
+		side     Side
+		slippage float64
+		units    int64
+	}{
+		{Long, 0, 1000},
+		{Long, 0.00015, 2153},
+		{Long, 0.0002, 2333}, // partially consumes the third level
+		{Short, 0.00005, 1333},
+		{Short, 1, 4000},
+	}
+
+	for _, test := range slippageTests {
+		if units := tick.UnitsWithinSlippage(test.side, test.slippage); units != test.units {
+			t.Errorf("%v units within %v slippage: %d, expected %d", test.side, test.slippage, units, test.units)
+		}
+	}
+
+	// Without depth the top of the book is used
+	top := &Tick{Bid: 1.1, Ask: 1.1002}
+	if price, filled := top.FillPrice(Long, 1000); price != 1.1002 || filled != 1000 {
+		t.Errorf("unexpected fill without depth %v %d", price, filled)
+	}
+	if top.Liquidity(Long) != 0 || top.UnitsWithinSlippage(Long, 0.001) != 0 {
+		t.Error("expected no liquidity without depth")
+	}
+
+	// An empty first level must not produce NaN
+	empty := &Tick{Asks: []PriceLevel{{Price: 1.1002, Liquidity: 0}, {Price: 1.1002, Liquidity: 1000}}}
+	if units := empty.UnitsWithinSlippage(Long, 0); units != 1000 {
+		t.Errorf("units within slippage with an empty first level: %d, expected 1000", units)
+	}
+}
@@ -1,13 +1,13 @@
 package gotrader
 
 import (
+	"context"
 	"errors"
 	"math"
-	"os"
-	"os/signal"
 	"sort"
 	"strconv"
-	"syscall"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/atomic"
@@ -20,7 +20,29 @@ type Engine interface {
 	Buy(instrument string, units int32)
 	Sell(instrument string, units int32)
 	CloseTrade(instrument string, id string)
-	StopSession() // Gracefully stops trading session from strategy
+	Schedule(at time.Time, fn func()) *ScheduledTask        // Runs fn at the given time (as soon as possible if zero), serialized with ticks
+	Every(interval time.Duration, fn func()) *ScheduledTask // Runs fn periodically, serialized with ticks
+	MarketOpen(instrument string) bool                      // Returns true if new entries on the instrument are allowed (orders reducing the position of an account without hedging always are)
+	RejectedTicks() map[TickRejection]int64                 // Returns the number of ticks dropped by the tick filter
+	Sizer() *Sizer                                          // Returns the position sizing helpers
+	State() *State                                          // Returns the strategy key-value state
+	StopSession()                                           // Gracefully stops trading session from strategy
+}
+
+// closeAllTrades closes all the trades of the trading instruments.
+func closeAllTrades(engine Engine) {
+
+	for _, inst := range engine.Account().Instruments() {
+
+		var ids []string
+		for trade := range inst.Trades() {
+			ids = append(ids, trade.ID())
+		}
+
+		for _, id := range ids {
+			engine.CloseTrade(inst.Name(), id)
+		}
+	}
 }
 
 /***********************************************************************************************
@@ -40,8 +62,33 @@ type liveEngine struct {
 	orders                   chan *OrderFill
 	fundsTransfers           chan *FundsTransfer
 	swapCharges              chan *SwapCharge
+	recorder                 *Recorder
+	bars                     *barBuilder
+	barStrategy              BarStrategy
+	pendingBars              []*Bar
+	warmUp                   *warmUp
+	scheduler                *scheduler
+	calendar                 *tradingCalendar
+	tickFilter               *tickFilter
+	rejectionStrategy        TickRejectionStrategy
+	sizer                    *Sizer
+	values                   *State
+	riskManager              *riskManager
+	breaker                  *circuitBreaker
+	throttle                 *orderThrottle
+	reconciler               *reconciler
+	watchdog                 *priceWatchdog
+	state                    *stateKeeper
+	errors                   *errorLog   // recent errors, kept for the status server
+	inFlight                 *sentOrders // orders sent to the broker, until the request returns
+	lastTick                 time.Time
+	metrics                  *engineMetrics
+	running                  chan struct{} // closed when the event loop starts
 	ready                    bool
-	endOfSession             chan bool
+	ctx                      context.Context // cancelled when the session stops
+	stop                     context.CancelFunc
+	routines                 sync.WaitGroup // broker requests and reconciler
+	routinesMutex            sync.Mutex     // prevents new routines once the session is stopping
 	logger                   Logger
 }
 
@@ -52,14 +99,60 @@ func newLiveEngine(logger Logger) *liveEngine {
 		fundsTransfers:          make(chan *FundsTransfer, 100),
 		swapCharges:             make(chan *SwapCharge, 100),
 		availableInstrumentsMap: make(map[string]InstrumentDetails),
-		endOfSession:            make(chan bool, 1),
+		scheduler:               newScheduler(),
+		inFlight:                newSentOrders(),
+		metrics:                 newEngineMetrics(),
+		running:                 make(chan struct{}),
 		logger:                  logger,
 	}
 }
 
-func (e *liveEngine) start() error {
+func (e *liveEngine) start(ctx context.Context) error {
+
+	e.ctx, e.stop = context.WithCancel(ctx)
+	defer e.stop()
+
+	if e.parameters.statusAddress != "" { // The errors logged are kept for the status
+		e.errors = newErrorLog(e.logger)
+		e.logger = e.errors
+	}
+
+	if e.parameters.sessionTag != "" {
+		if _, ok := e.client.(TaggingClient); !ok {
+			return errors.New("client can't tag the session trades")
+		}
+	}
+
+	if err := e.parameters.shutdownPolicy.validate(e.client, e.parameters.sessionTag); err != nil {
+		return err
+	}
 
 	e.account = newAccount(e.parameters.account)
+	e.sizer = newSizer(e.account)
+	e.riskManager = newRiskManager(e.parameters.riskRules)
+
+	if e.parameters.throttle {
+		e.throttle = newOrderThrottle(e.parameters.throttlePolicy, e.parameters.accountOrderLimit, e.parameters.instrumentOrderLimit)
+	}
+
+	if e.parameters.breakerOptions != nil { // The circuit breaker is the first rule, orders are blocked while it is tripped
+		e.breaker = newCircuitBreaker(e.parameters.breakerOptions)
+		e.riskManager.rules = append([]RiskRule{e.breaker}, e.riskManager.rules...)
+	}
+
+	// Load the persisted state, it is closed with a final snapshot when the session ends
+	if e.parameters.stateStore != nil {
+
+		state, err := loadState(e.parameters.stateStore, e.logger)
+		if err != nil {
+			return err
+		}
+
+		e.state = state
+		defer e.state.close()
+	}
+
+	e.values = newState(e.state)
 
 	// Account Status Retrieval
 	accountStatus, err := e.client.GetAccountStatus(e.parameters.account)
@@ -77,6 +170,26 @@ func (e *liveEngine) start() error {
 		return err
 	}
 
+	// Initialize trading calendar
+	e.calendar, err = newTradingCalendar(e.parameters)
+	if err != nil {
+		return err
+	}
+
+	// Initialize recorder
+	if e.parameters.recordPrefix != "" {
+
+		e.recorder, err = NewRecorder(e.parameters.recordPrefix, e.parameters.recordMaxFileSize)
+		if err != nil {
+			return err
+		}
+		defer e.recorder.Close()
+
+		if err := e.recorder.RecordInstruments(availableInstruments); err != nil {
+			return err
+		}
+	}
+
 	conversionInstruments := make(map[string]*instrumentConversion)
 
 	for _, inst := range availableInstruments {
@@ -94,6 +207,7 @@ func (e *liveEngine) start() error {
 					e.logger,
 				)
 				e.account.instruments[inst.Name].hedgeType = accountStatus.Hedge
+				e.account.instruments[inst.Name].setDetails(inst)
 				conversionInstruments[inst.Name] = newInstrumentConversion(
 					inst.Name,
 					inst.BaseCurrency,
@@ -134,202 +248,774 @@ func (e *liveEngine) start() error {
 		if exist {
 			trade := inst.openTrade(t.ID, t.Side, t.OpenTime, t.Units, t.OpenPrice)
 			trade.chargedFees.Add(t.ChargedFees)
+			trade.tag = t.Tag
 		}
 	}
 
-	// Subscribe prices
-	err = e.client.SubscribePrices(e.account.id, e.currencyConversionEngine.conversionInstrumentsDetails, e.onTick)
-	if err != nil {
+	// Merge the persisted state with the broker state
+	if err := e.state.restore(e.account, e.breaker); err != nil {
 		return err
 	}
 
-	// Subscribe notifications
-	err = e.client.SubscribeOrderFillNotifications(e.account.id, e.onOrderFill)
-	if err != nil {
-		return err
+	if e.state != nil && e.breaker != nil { // The breaker state changes with every tick, so it is saved periodically
+		e.scheduler.schedule(time.Now().Add(stateSaveInterval), stateSaveInterval, func() {
+			e.state.breakerUpdated(e.breaker.state())
+		})
 	}
 
-	err = e.client.SubscribeSwapChargeNotifications(e.account.id, e.onSwapCharge)
-	if err != nil {
-		return err
+	// Initialize bar aggregation, bars are only delivered to strategies that implement BarStrategy
+	e.bars = newBarBuilder(e.parameters.timeframes, e.parameters.alignHour, e.parameters.alignLocation)
+	e.barStrategy, _ = e.strategy.(BarStrategy)
+
+	// Initialize tick filter, rejections are only notified to strategies that implement TickRejectionStrategy
+	if e.parameters.filterTicks {
+		e.tickFilter = newTickFilter(e.parameters.jumpATRs, e.parameters.atrPeriod)
 	}
+	e.rejectionStrategy, _ = e.strategy.(TickRejectionStrategy)
 
-	err = e.client.SubscribeFundsTransferNotifications(e.account.id, e.onFundsTransfer)
-	if err != nil {
-		return err
+	// Initialize warm up before subscribing prices, so live ticks are buffered while warming up
+	if e.parameters.warmUpCount > 0 {
+		e.warmUp = newWarmUp(e.client, e.parameters, e.bars, e.strategy, e.logger)
+	}
+
+	// Watch the prices, so the strategy doesn't trade on prices frozen by a stream that stopped without an error.
+	// The status reports the stream connectivity from the watchdog, so it is always on with the status server.
+	tickTimeout, heartbeatTimeout := e.parameters.staleTickTimeout, e.parameters.staleHeartbeatTimeout
+	_, heartbeats := e.client.(HeartbeatClient)
+
+	if e.parameters.statusAddress != "" && tickTimeout <= 0 && heartbeatTimeout <= 0 {
+		if heartbeats {
+			heartbeatTimeout = defaultStatusHeartbeatTimeout
+		} else {
+			tickTimeout = defaultStatusTickTimeout
+		}
+	}
+
+	if tickTimeout > 0 || heartbeatTimeout > 0 {
+
+		e.watchdog = newPriceWatchdog(tickTimeout, heartbeatTimeout, heartbeats, time.Now())
+		e.scheduler.schedule(time.Now().Add(staleCheckInterval), staleCheckInterval, e.checkPrices)
 	}
 
-	// Initialize consumers (buffered channels are used to prevent race conditions)
-	e.startOrderFillConsumer()
-	e.startSwapChargesConsumer()
-	e.startFundsTransferConsumer()
+	// Subscribe prices and notifications
+	if err = e.subscribe(); err != nil {
+		e.shutdown()
+		return err
+	}
 
 	// Initialize strategy
 	e.strategy.SetEngine(e)
 	e.strategy.Initialize()
 
-	// Run strategy OnStop method when the app receives the signal to shutdown
-	e.shutdownHook()
+	// Warm up strategy with historical data, then process the live ticks received meanwhile
+	if e.warmUp != nil {
+		e.warmUp.run(time.Now())
 
-	// Run strategy
+		for _, tick := range e.warmUp.finish() {
+			e.processTick(tick)
+		}
+	}
+
+	// Orders that were waiting for the throttle are stale after the restart, they are reported instead of sent
+	if orders := e.state.waitingOrders(); len(orders) > 0 {
+
+		for _, order := range orders {
+			e.rejectOrder(order, &OrderRejection{Rule: ExpiredOnRestartRule, Reason: "waiting for the throttle when the session stopped"})
+		}
+
+		e.state.ordersWaiting(nil)
+	}
+
+	if e.calendar.flattenBefore > 0 {
+		scheduleWeekendFlatten(e, e.scheduler, e.calendar.flattenBefore, weeklyClose(time.Now()))
+	}
+
+	// Reconcile the account with the broker periodically
+	if e.parameters.reconcileInterval > 0 {
+		e.reconciler = newReconciler(e.account, e.parameters.reconcileInterval, e.parameters.reconcileRepair, e.applyOrderFill, e.logger)
+		e.startReconciler()
+	}
+
+	close(e.running)
+
+	// Serve the session status, it is stopped with the session
+	if e.parameters.statusAddress != "" {
+		if err := e.startStatusServer(); err != nil {
+			e.shutdown()
+			return err
+		}
+	}
+
+	// Run strategy until the session is stopped or the context is cancelled
 	e.run()
 
+	// Apply the shutdown policy while the notifications are still subscribed
+	if e.parameters.shutdownPolicy.Action != KeepTrades {
+		e.onShutdown(e.parameters.shutdownPolicy.execute(e.account, e.client, e.parameters.sessionTag, e.await))
+	}
+
+	// Stop subscriptions and drain the notifications, so the strategy stops with the final state
+	e.shutdown()
+
 	// Stop strategy
 	e.strategy.OnStop()
 
 	return nil
 }
 
-func (e *liveEngine) shutdownHook() {
-	var singalChan = make(chan os.Signal)
-	signal.Notify(singalChan, syscall.SIGTERM)
-	signal.Notify(singalChan, syscall.SIGINT)
+func (e *liveEngine) subscribe() error {
+
+	if heartbeatClient, ok := e.client.(HeartbeatClient); ok && e.watchdog != nil {
+		if err := heartbeatClient.SubscribeHeartbeats(e.account.id, e.onHeartbeat); err != nil {
+			return err
+		}
+	}
+
+	err := e.client.SubscribePrices(e.account.id, e.currencyConversionEngine.conversionInstrumentsDetails, e.onTick)
+	if err != nil {
+		return err
+	}
+
+	// The session is stopped if the notifications fail, since the account state can't be tracked without them
+	if errorClient, ok := e.client.(StreamErrorClient); ok {
+		err = errorClient.SubscribeStreamErrors(e.account.id, e.onStreamError)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = e.client.SubscribeOrderFillNotifications(e.account.id, e.onOrderFill)
+	if err != nil {
+		return err
+	}
+
+	err = e.client.SubscribeSwapChargeNotifications(e.account.id, e.onSwapCharge)
+	if err != nil {
+		return err
+	}
+
+	return e.client.SubscribeFundsTransferNotifications(e.account.id, e.onFundsTransfer)
+}
+
+func (e *liveEngine) onShutdown(report *ShutdownReport) {
+
+	message := "shutdown policy " + report.Action.String() + ": " +
+		strconv.Itoa(len(report.Closed)) + " trades closed, " +
+		strconv.Itoa(len(report.Protected)) + " trades protected, " +
+		strconv.Itoa(len(report.Failed)) + " failed, " +
+		strconv.Itoa(len(report.Skipped)) + " skipped after the deadline"
+
+	if report.Done() {
+		e.logger.Info(message)
+	} else {
+		e.logger.Error(message)
+	}
+
+	if shutdownStrategy, ok := e.strategy.(ShutdownStrategy); ok {
+		shutdownStrategy.OnShutdown(report)
+	}
+}
+
+// shutdown stops the subscriptions and waits for the engine goroutines, applying the notifications received
+// meanwhile. Notifications are only drained when the client can be closed, otherwise the client could still
+// deliver them after the strategy stops.
+func (e *liveEngine) shutdown() {
+
+	e.routinesMutex.Lock()
+	e.stop()
+	e.routinesMutex.Unlock()
+
+	closable, ok := e.client.(ClosableClient)
+
+	stopped := make(chan struct{})
 
 	go func() {
-		<-singalChan
-		e.strategy.OnStop()
-		os.Exit(0)
+		defer close(stopped)
+
+		if ok {
+			if err := closable.Close(); err != nil {
+				e.logger.Error("closing client: " + err.Error())
+			}
+		}
+
+		e.routines.Wait()
 	}()
+
+	// The client and the routines can be blocked sending notifications until they are applied
+	for stopped != nil {
+		select {
+		case <-stopped:
+			stopped = nil
+		case orderFill := <-e.orders:
+			e.processOrderFill(orderFill)
+		case swapCharge := <-e.swapCharges:
+			e.processSwapCharge(swapCharge)
+		case funds := <-e.fundsTransfers:
+			e.processFundsTransfer(funds)
+		}
+	}
+
+	if e.breaker != nil {
+		e.state.breakerUpdated(e.breaker.state())
+	}
+
+	if !ok {
+		e.logger.Warn("client subscriptions can't be stopped, notifications will be ignored")
+		return
+	}
+
+	for {
+		select {
+		case orderFill := <-e.orders:
+			e.processOrderFill(orderFill)
+		case swapCharge := <-e.swapCharges:
+			e.processSwapCharge(swapCharge)
+		case funds := <-e.fundsTransfers:
+			e.processFundsTransfer(funds)
+		default:
+			return
+		}
+	}
 }
 
 func (e *liveEngine) onTick(tick *Tick) { // Ticks callback
 
+	e.watchdog.tick(tick.Instrument, time.Now())
+	e.metrics.ticksReceived.Inc()
+
+	if e.recorder != nil {
+		if err := e.recorder.RecordTick(tick); err != nil {
+			e.logger.Error(err)
+		}
+	}
+
+	if e.warmUp != nil && e.warmUp.buffer(tick) {
+		return
+	}
+
 	select { // non blocking buffered channel
 	case e.ticks <- tick:
 	default: // Replaces older ticks by newer ones (extreme case)
 		<-e.ticks
 		e.ticks <- tick
+		e.metrics.ticksDropped.Inc()
 	}
 
 }
 
 func (e *liveEngine) onOrderFill(orderFill *OrderFill) { // Orders callback
+
+	if e.recorder != nil && e.parameters.recordEvents {
+		if err := e.recorder.RecordOrderFill(orderFill); err != nil {
+			e.logger.Error(err)
+		}
+	}
+
 	e.orders <- orderFill
 }
 
 func (e *liveEngine) onSwapCharge(swapCharge *SwapCharge) { // Swap/Rollover charges callback
+
+	if e.recorder != nil && e.parameters.recordEvents {
+		if err := e.recorder.RecordSwapCharge(swapCharge); err != nil {
+			e.logger.Error(err)
+		}
+	}
+
 	e.swapCharges <- swapCharge
 }
 
 func (e *liveEngine) onFundsTransfer(funds *FundsTransfer) { // Funds transfer callback
+
+	if e.recorder != nil && e.parameters.recordEvents {
+		if err := e.recorder.RecordFundsTransfer(funds); err != nil {
+			e.logger.Error(err)
+		}
+	}
+
 	e.fundsTransfers <- funds
 }
 
-func (e *liveEngine) startOrderFillConsumer() {
+func (e *liveEngine) onHeartbeat(t time.Time) { // Price stream heartbeats callback, the local time is kept
+	e.watchdog.heartbeat(time.Now())
+}
+
+func (e *liveEngine) onStreamError(err error) { // Unrecoverable subscription errors callback
 
-	go func() {
-		for orderFill := range e.orders {
-
-			if orderFill.Error == "" {
-				if !orderFill.TradeClose {
-					e.account.instruments[orderFill.Instrument.Name].openTrade(
-						orderFill.TradeID,
-						orderFill.Side,
-						orderFill.Time,
-						orderFill.Units,
-						orderFill.Price,
-					)
-				} else {
-					e.account.instruments[orderFill.Instrument.Name].closeTrade(orderFill.TradeID)
-					e.account.balance.Add(orderFill.Profit)
+	e.logger.Error("subscription failed, stopping session: " + err.Error())
+	e.stop()
+}
+
+// await applies the notifications until done returns true or the deadline expires, out of the event loop.
+func (e *liveEngine) await(done func() bool, deadline time.Time) {
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	for !done() {
+		select {
+		case <-timer.C:
+			return
+		case orderFill := <-e.orders:
+			e.processOrderFill(orderFill)
+		case swapCharge := <-e.swapCharges:
+			e.processSwapCharge(swapCharge)
+		case funds := <-e.fundsTransfers:
+			e.processFundsTransfer(funds)
+		}
+	}
+}
+
+// processOrderFill applies an order fill to the account and notifies the strategy, it runs in the event loop.
+func (e *liveEngine) processOrderFill(orderFill *OrderFill) {
+
+	if orderFill.Error != "" {
+
+		reason := orderFill.Error // rejected by the broker, the orders rejected by the engine have their rule
+		if orderFill.Rejection != nil {
+			reason = orderFill.Rejection.Rule
+		}
+
+		e.metrics.rejected(reason)
+	}
+
+	// The fills of the sent orders, closes can only be filled by an order without hedging
+	if orderFill.Rejection == nil && (!orderFill.TradeClose || e.netting(orderFill.Instrument.Name)) {
+		if latency, matched := e.metrics.fills.match(orderFill, time.Now()); matched {
+			e.metrics.orderLatency.observe(latency.Seconds())
+		}
+	}
+
+	if orderFill.Error == "" {
+		e.applyOrderFill(orderFill)
+	}
+
+	e.strategy.OnOrderFill(orderFill)
+}
+
+// applyOrderFill updates the account and the persisted state, for broker fills and reconciliation repairs.
+func (e *liveEngine) applyOrderFill(orderFill *OrderFill) {
+
+	if orderFill.TradeClose {
+		e.account.applyOrderFill(orderFill)
+		if inst := e.account.instruments[orderFill.Instrument.Name]; inst == nil || inst.Trade(orderFill.TradeID) == nil {
+			e.state.tradeClosed(orderFill.TradeID) // not only reduced
+		}
+		return
+	}
+
+	if trade := e.account.applyOrderFill(orderFill); trade != nil {
+		e.state.tradeOpened(trade)
+	}
+}
+
+func (e *liveEngine) processSwapCharge(swapCharge *SwapCharge) {
+
+	for _, charge := range swapCharge.Charges {
+
+		tr, exist := e.account.instruments[charge.Instrument.Name].trades.Get(charge.ID)
+		if !exist {
+			e.logger.Warn(charge, "charging swap on unexisting trade")
+			continue
+		}
+
+		trade := tr.(*Trade)
+		trade.chargedFees.Add(charge.Ammount)
+		e.account.balance.Add(charge.Ammount)
+	}
+}
+
+func (e *liveEngine) processFundsTransfer(funds *FundsTransfer) {
+	e.account.balance.Add(funds.Ammount)
+}
+
+// startReconciler retrieves the broker state periodically, the comparison with the account runs in the event loop.
+func (e *liveEngine) startReconciler() {
+
+	e.async(func() {
+
+		ticker := time.NewTicker(e.reconciler.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-e.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			status, err := e.client.GetAccountStatus(e.account.id)
+			if err != nil {
+				e.reconciler.failures.Inc()
+				e.logger.Error("reconciliation failed: " + err.Error())
+				continue
+			}
+
+			trades, err := e.client.GetOpenTrades(e.account.id)
+			if err != nil {
+				e.reconciler.failures.Inc()
+				e.logger.Error("reconciliation failed: " + err.Error())
+				continue
+			}
+
+			e.scheduler.schedule(time.Now(), 0, func() {
+				e.onReconciliation(e.reconciler.reconcile(status, trades, time.Now()))
+			})
+		}
+	})
+}
+
+func (e *liveEngine) onReconciliation(report *Reconciliation) {
+
+	if report.Consistent() {
+		return
+	}
+
+	e.logger.Warn("account state differs from the broker: " +
+		strconv.Itoa(len(report.MissingTrades)) + " missing trades, " +
+		strconv.Itoa(len(report.GhostTrades)) + " ghost trades, " +
+		strconv.Itoa(len(report.UnitsMismatches)) + " units mismatches, balance difference " +
+		strconv.FormatFloat(report.BalanceDifference, 'f', 2, 64))
+
+	if reconciliationStrategy, ok := e.strategy.(ReconciliationStrategy); ok {
+		reconciliationStrategy.OnReconciliation(report)
+	}
+}
+
+// checkPrices updates the stale state of the prices, which are resubscribed while the stream looks broken.
+func (e *liveEngine) checkPrices() {
+
+	now := time.Now()
+
+	instruments := make([]string, 0, len(e.account.instruments))
+	for name := range e.account.instruments {
+		instruments = append(instruments, name)
+	}
+
+	state, changed := e.watchdog.check(instruments, e.calendar.isOpen(now), now)
+
+	if changed {
+		e.onStaleData(state)
+	}
+
+	if e.watchdog.resubscribeDue(state, len(instruments), now) {
+		e.resubscribePrices()
+	}
+}
+
+func (e *liveEngine) onStaleData(state *StaleData) {
+
+	switch {
+	case state.HeartbeatLost:
+		e.logger.Warn("price stream heartbeat lost, new orders are blocked")
+	case state.Stale():
+		e.logger.Warn("stale prices of " + strings.Join(state.Instruments, ", ") + ", new orders are blocked")
+	default:
+		e.logger.Info("prices are updating again, new orders are allowed")
+	}
+
+	if staleStrategy, ok := e.strategy.(StaleDataStrategy); ok {
+		staleStrategy.OnStaleData(state)
+	}
+}
+
+func (e *liveEngine) resubscribePrices() {
+
+	client, ok := e.client.(HeartbeatClient)
+	if !ok {
+		e.logger.Warn("client can't resubscribe the prices")
+		return
+	}
+
+	e.async(func() {
+		if err := client.ResubscribePrices(e.account.id); err != nil {
+			e.logger.Error("resubscribing prices: " + err.Error())
+		}
+	})
+}
+
+func (e *liveEngine) run() {
+
+	var (
+		timer    = time.NewTimer(time.Hour)
+		deadline time.Time // execution time of the next scheduled task, zero if the timer is stopped
+	)
+
+	timer.Stop()
+
+	for { // Application blocks until end of session
+
+		select {
+		case <-e.ctx.Done():
+			return
+		case tick := <-e.ticks:
+			e.processTick(tick)
+		case orderFill := <-e.orders:
+			e.processOrderFill(orderFill)
+		case swapCharge := <-e.swapCharges:
+			e.processSwapCharge(swapCharge)
+		case funds := <-e.fundsTransfers:
+			e.processFundsTransfer(funds)
+		case <-timer.C:
+			deadline = time.Time{}
+			e.runScheduledTasks(time.Now())
+		case <-e.scheduler.wake:
+		}
+
+		// Keep the timer set to the next scheduled task
+		next, exist := e.scheduler.next()
+
+		if exist && !next.Equal(deadline) {
+
+			if !deadline.IsZero() && !timer.Stop() {
+				<-timer.C
+			}
+
+			timer.Reset(time.Until(next))
+			deadline = next
+
+		} else if !exist && !deadline.IsZero() {
+
+			if !timer.Stop() {
+				<-timer.C
+			}
+
+			deadline = time.Time{}
+		}
+	}
+}
+
+func (e *liveEngine) runScheduledTasks(now time.Time) {
+	for task := e.scheduler.pop(now, true); task != nil; task = e.scheduler.pop(now, true) {
+		task.fn()
+	}
+}
+
+func (e *liveEngine) processTick(tick *Tick) {
+
+	if e.tickFilter != nil {
+		if reason, accepted := e.tickFilter.check(tick); !accepted {
+
+			e.logger.Debug("tick dropped by the filter: " + reason.String())
+
+			if e.rejectionStrategy != nil {
+				e.rejectionStrategy.OnTickRejected(tick, reason)
+			}
+
+			return
+		}
+	}
+
+	e.lastTick = time.Now()
+
+	if _, exist := e.account.instruments[tick.Instrument]; exist {
+
+		e.account.instruments[tick.Instrument].updatePrice(tick)
+		e.currencyConversionEngine.updateRate(tick.Instrument)
+		e.account.time = tick.Time
+		completedBars := e.bars.update(tick)
+
+		if e.ready && e.pendingBars != nil {
+			completedBars = append(e.pendingBars, completedBars...)
+			e.pendingBars = nil
+		}
+
+		if e.ready {
+			e.account.calculateUnrealized()
+			e.account.calculateMarginUsed()
+			e.account.calculateFreeMargin()
+
+			if e.breaker != nil {
+				if breach := e.breaker.update(e.account.time, e.account.Equity()); breach != nil {
+					e.onBreach(breach)
+				}
+			}
+
+			if e.barStrategy != nil {
+				for _, bar := range completedBars {
+					e.barStrategy.OnBar(bar)
 				}
 			}
 
-			e.strategy.OnOrderFill(orderFill)
+			e.strategy.OnTick(tick)
+		} else {
+			// Bars completed while prices are missing are delivered with the first tick after ready
+			e.pendingBars = append(e.pendingBars, completedBars...)
+			e.checkState()
 		}
-	}()
+
+	} else { // This is the auxiliar instrument update (price state is kept only on the ccyconv engine)
+
+		if _, exist := e.currencyConversionEngine.conversionInstruments[tick.Instrument]; exist {
+
+			inst := e.currencyConversionEngine.conversionInstruments[tick.Instrument]
+			inst.Bid.Store(tick.Bid)
+			inst.Ask.Store(tick.Ask)
+			e.currencyConversionEngine.updateRate(tick.Instrument)
+		} else {
+			e.logger.Warn("received a tick from an instrument that was not subscribed and it has been ignored")
+		}
+	}
+}
+
+// Check if all instruments have already a price defined
+func (e *liveEngine) checkState() {
+	for _, inst := range e.currencyConversionEngine.conversionInstruments {
+		if inst.Ask.Load() == 0.0 {
+			return
+		}
+	}
+
+	e.ready = true
+}
+
+func (e *liveEngine) onBreach(breach *Breach) {
+
+	e.logger.Warn("circuit breaker tripped, " + breach.Limit + " limit breached")
+	e.state.breakerUpdated(e.breaker.state())
+
+	if e.breaker.closeAll {
+		closeAllTrades(e)
+	}
+
+	if breakerStrategy, ok := e.strategy.(CircuitBreakerStrategy); ok {
+		breakerStrategy.OnCircuitBreak(breach)
+	}
 }
 
-func (e *liveEngine) startSwapChargesConsumer() {
+// submitOrder sends the order if the throttle allows it, otherwise it waits for the rate limits or is rejected.
+func (e *liveEngine) submitOrder(order *OrderRequest) {
 
-	go func() {
-		for swapCharge := range e.swapCharges {
-			for _, charge := range swapCharge.Charges {
+	if e.throttle != nil {
 
-				tr, exist := e.account.instruments[charge.Instrument.Name].trades.Get(charge.ID)
-				if !exist {
-					e.logger.Warn(charge, "charging swap on unexisting trade")
-					continue
-				}
+		admitted, rejected := e.throttle.admit(order, e.netting(order.Instrument))
+
+		for _, throttled := range rejected {
+			e.rejectOrder(throttled.order, throttled.rejection)
+		}
 
-				trade := tr.(*Trade)
-				trade.chargedFees.Add(charge.Ammount)
-				e.account.balance.Add(charge.Ammount)
+		if !admitted {
+			e.state.ordersWaiting(e.throttle.waiting())
+			if e.throttle.needsRelease() {
+				e.scheduleRelease(order.Time)
 			}
+			return
 		}
-	}()
+	}
 
+	e.sendOrder(order)
 }
 
-func (e *liveEngine) startFundsTransferConsumer() {
+// scheduleRelease sends the orders waiting for the throttle, at the given time and then when the rate limits allow it.
+func (e *liveEngine) scheduleRelease(at time.Time) {
 
-	go func() {
-		for funds := range e.fundsTransfers {
-			e.account.balance.Add(funds.Ammount)
+	e.scheduler.schedule(at, 0, func() {
+
+		orders, next := e.throttle.release(time.Now())
+		e.state.ordersWaiting(e.throttle.waiting())
+
+		for _, order := range orders {
+			e.sendOrder(order)
 		}
-	}()
 
+		if !next.IsZero() {
+			e.scheduleRelease(next)
+		}
+	})
 }
 
-func (e *liveEngine) run() {
+func (e *liveEngine) sendOrder(order *OrderRequest) {
 
-	for { // Application blocks until end of session
+	if rejection := e.watchdog.reject(e.account, order); rejection != nil {
+		e.rejectOrder(order, rejection)
+		return
+	}
 
-		select {
-		case <-e.endOfSession:
-			return
-		case tick := <-e.ticks:
+	if rejection := e.riskManager.check(e.account, order, e.pendingOrders()); rejection != nil {
+		e.rejectOrder(order, rejection)
+		return
+	}
 
-			if _, exist := e.account.instruments[tick.Instrument]; exist {
+	if e.account.instruments[order.Instrument].MarginRequired(order.Units) > e.account.marginFree { // Only send request if there is enough margin
+		e.rejectOrder(order, &OrderRejection{Rule: notEnoughMarginReason, Reason: "margin required above the free margin"})
+		return
+	}
 
-				e.account.instruments[tick.Instrument].updatePrice(tick)
-				e.currencyConversionEngine.updateRate(tick.Instrument)
-				e.account.time = tick.Time
+	e.async(func() {
 
-				if e.ready {
-					e.account.calculateUnrealized()
-					e.account.calculateMarginUsed()
-					e.account.calculateFreeMargin()
+		var err error
 
-					e.strategy.OnTick(tick)
-				} else {
-					e.checkState()
-				}
+		e.inFlight.add(order)
+		defer e.inFlight.remove(order)
 
-			} else { // This is the auxiliar instrument update (price state is kept only on the ccyconv engine)
+		e.metrics.fills.add(order)
 
-				if _, exist := e.currencyConversionEngine.conversionInstruments[tick.Instrument]; exist {
+		if e.parameters.sessionTag != "" {
+			err = e.client.(TaggingClient).OpenTaggedMarketOrder(e.account.id, order.Instrument, order.Units, order.Side.String(), e.parameters.sessionTag)
+		} else {
+			err = e.client.OpenMarketOrder(e.account.id, order.Instrument, order.Units, order.Side.String())
+		}
 
-					inst := e.currencyConversionEngine.conversionInstruments[tick.Instrument]
-					inst.Bid.Store(tick.Bid)
-					inst.Ask.Store(tick.Ask)
-					e.currencyConversionEngine.updateRate(tick.Instrument)
-				} else {
-					e.logger.Warn("received a tick from an instrument that was not subscribed and it has been ignored")
-				}
+		if err != nil {
+			e.metrics.fills.remove(order)
+			e.orders <- &OrderFill{
+				Error:      err.Error(),
+				Rejection:  &OrderRejection{Rule: brokerErrorReason, Reason: err.Error()},
+				Instrument: e.availableInstrumentsMap[order.Instrument],
+				Side:       order.Side,
+				Units:      order.Units,
+				Time:       time.Now(),
 			}
 		}
+	})
+}
+
+// async runs fn in a goroutine that is joined when the session stops, fn is ignored if the session is stopping.
+func (e *liveEngine) async(fn func()) {
+
+	e.routinesMutex.Lock()
+	defer e.routinesMutex.Unlock()
+
+	if e.ctx.Err() != nil {
+		e.logger.Debug("request ignored, the session is stopping")
+		return
 	}
+
+	e.routines.Add(1)
+
+	go func() {
+		defer e.routines.Done()
+		fn()
+	}()
 }
 
-// Check if all instruments have already a price defined
-func (e *liveEngine) checkState() {
-	for _, inst := range e.currencyConversionEngine.conversionInstruments {
-		if inst.Ask.Load() == 0.0 {
-			return
-		}
+// pendingOrders returns the orders sent to the broker and the orders waiting for the throttle, which aren't filled yet.
+func (e *liveEngine) pendingOrders() []*OrderRequest {
+
+	pending := e.inFlight.orders()
+
+	if e.throttle != nil {
+		pending = append(pending, e.throttle.waiting()...)
 	}
 
-	e.ready = true
+	return pending
 }
 
-func (e *liveEngine) calcMarginUsed(instrument string, units int32) float64 {
+// rejectOrder notifies the strategy of an order rejected by the engine, as an order fill.
+func (e *liveEngine) rejectOrder(order *OrderRequest, rejection *OrderRejection) {
 
-	leverage := e.account.instruments[instrument].leverage
-	conversionRate := e.account.instruments[instrument].ccyConversion.BaseConversionRate.Load()
-	marginUsed := float64(units) / leverage.Load() * conversionRate
+	e.logger.Warn("order rejected, " + rejection.Error())
 
-	return marginUsed
+	e.async(func() {
+		e.orders <- &OrderFill{
+			Error:      rejection.Rule,
+			Rejection:  rejection,
+			Instrument: e.availableInstrumentsMap[order.Instrument],
+			Side:       order.Side,
+			Units:      order.Units,
+			Time:       time.Now(),
+		}
+	})
 }
 
 /**************************
@@ -344,84 +1030,111 @@ func (e *liveEngine) Account() *Account {
 
 func (e *liveEngine) Buy(instrument string, units int32) {
 
-	go func() {
-
-		if e.calcMarginUsed(instrument, units) > e.account.marginFree { // Only send request if there is enough margin
-			e.orders <- &OrderFill{
-				Error:      "NOT_ENOUGH_MARGIN",
-				Instrument: e.availableInstrumentsMap[instrument],
-				Side:       Long,
-				Units:      units,
-				Time:       time.Now(),
-			}
-			return
-		}
+	if e.warmingUp() {
+		e.logger.Debug("order ignored during strategy warm up")
+		return
+	}
 
-		err := e.client.OpenMarketOrder(e.account.id, instrument, units, Long.String())
-		if err != nil {
-			e.orders <- &OrderFill{
-				Error:      err.Error(),
-				Instrument: e.availableInstrumentsMap[instrument],
-				Side:       Short,
-				Units:      units,
-				Time:       time.Now(),
-			}
-		}
+	order := &OrderRequest{Instrument: instrument, Side: Long, Units: units, Time: time.Now()}
 
-	}()
+	if !e.MarketOpen(instrument) && !e.reducesPosition(instrument, Long, units) {
+		e.rejectOrder(order, &OrderRejection{Rule: MarketClosedRule, Reason: "new entries on " + instrument + " are not allowed"})
+		return
+	}
 
+	e.submitOrder(order)
 }
 
 func (e *liveEngine) Sell(instrument string, units int32) {
 
-	go func() {
-
-		if e.calcMarginUsed(instrument, units) > e.account.marginFree { // Only send request if there is enough margin
-			e.orders <- &OrderFill{
-				Error:      "NOT_ENOUGH_MARGIN",
-				Instrument: e.availableInstrumentsMap[instrument],
-				Side:       Short,
-				Units:      units,
-				Time:       time.Now(),
-			}
-			return
-		}
+	if e.warmingUp() {
+		e.logger.Debug("order ignored during strategy warm up")
+		return
+	}
 
-		err := e.client.OpenMarketOrder(e.account.id, instrument, units, Short.String())
-		if err != nil {
-			e.orders <- &OrderFill{
-				Error:      err.Error(),
-				Instrument: e.availableInstrumentsMap[instrument],
-				Side:       Short,
-				Units:      units,
-				Time:       time.Now(),
-			}
-		}
+	order := &OrderRequest{Instrument: instrument, Side: Short, Units: units, Time: time.Now()}
 
-	}()
+	if !e.MarketOpen(instrument) && !e.reducesPosition(instrument, Short, units) {
+		e.rejectOrder(order, &OrderRejection{Rule: MarketClosedRule, Reason: "new entries on " + instrument + " are not allowed"})
+		return
+	}
 
+	e.submitOrder(order)
 }
 
 func (e *liveEngine) CloseTrade(instrument, id string) {
 
-	go func() {
+	if e.warmingUp() {
+		e.logger.Debug("order ignored during strategy warm up")
+		return
+	}
+
+	e.async(func() {
 
 		err := e.client.CloseTrade(e.account.id, id)
 		if err != nil {
 			e.orders <- &OrderFill{
 				Error:      err.Error(),
+				Rejection:  &OrderRejection{Rule: brokerErrorReason, Reason: err.Error()},
 				Instrument: e.availableInstrumentsMap[instrument],
 				TradeID:    id,
 				Time:       time.Now(),
 			}
 		}
 
-	}()
+	})
+
+}
+
+func (e *liveEngine) Schedule(at time.Time, fn func()) *ScheduledTask {
+
+	if at.IsZero() { // the live clock is always defined, so there is nothing to anchor
+		at = time.Now()
+	}
+
+	return e.scheduler.schedule(at, 0, fn)
+}
+
+func (e *liveEngine) Every(interval time.Duration, fn func()) *ScheduledTask {
+
+	if interval <= 0 {
+		e.logger.Error("scheduling interval must be positive")
+		return newCancelledTask()
+	}
+
+	return e.scheduler.schedule(time.Now().Add(interval), interval, fn)
+}
+
+func (e *liveEngine) MarketOpen(instrument string) bool {
+	return e.calendar.entriesAllowed(instrument, time.Now())
+}
+
+// reducesPosition returns true if the order only reduces the net position, so it's allowed when entries are not.
+// netting returns true if the account has no hedging on the instrument, so its positions are netted.
+func (e *liveEngine) netting(instrument string) bool {
+	inst, exist := e.account.instruments[instrument]
+	return exist && inst.hedgeType == NoHedge
+}
+
+func (e *liveEngine) reducesPosition(instrument string, side Side, units int32) bool {
+	inst, exist := e.account.instruments[instrument]
+	return exist && inst.reduces(side, units)
+}
+
+func (e *liveEngine) RejectedTicks() map[TickRejection]int64 {
+	return e.tickFilter.rejected()
+}
+
+func (e *liveEngine) Sizer() *Sizer {
+	return e.sizer
+}
 
+func (e *liveEngine) State() *State {
+	return e.values
 }
 
 func (e *liveEngine) StopSession() {
-	e.endOfSession <- true
+	e.stop()
 }
 
 /***********************************************************************************************
@@ -441,6 +1154,19 @@ type btEngine struct {
 	ticks                    chan *Tick
 	tradesCounter            *atomic.Int32
 	instrumentsDetails       map[string]InstrumentDetails
+	bars                     *barBuilder
+	barStrategy              BarStrategy
+	pendingBars              []*Bar
+	warmUp                   *warmUp
+	scheduler                *scheduler
+	calendar                 *tradingCalendar
+	tickFilter               *tickFilter
+	rejectionStrategy        TickRejectionStrategy
+	sizer                    *Sizer
+	values                   *State
+	riskManager              *riskManager
+	breaker                  *circuitBreaker
+	throttle                 *orderThrottle
 	ready                    bool
 	endOfSession             chan bool
 	logger                   Logger
@@ -452,18 +1178,31 @@ func newBtEngine(logger Logger) *btEngine {
 		tradesCounter:      atomic.NewInt32(0),
 		instrumentsDetails: make(map[string]InstrumentDetails),
 		endOfSession:       make(chan bool, 1),
+		scheduler:          newScheduler(),
 		logger:             logger,
 	}
 }
 
-func (e *btEngine) start() error {
-
-	e.account = newAccount(e.parameters.account)
+func (e *btEngine) start(ctx context.Context) error {
 
 	if e.parameters == nil || e.parameters.testParameters == nil {
 		return errors.New("parameters are no defined")
 	}
 
+	e.account = newAccount(e.parameters.account)
+	e.sizer = newSizer(e.account)
+	e.values = newState(nil) // in memory, so each backtest starts with an empty state
+	e.riskManager = newRiskManager(e.parameters.riskRules)
+
+	if e.parameters.throttle {
+		e.throttle = newOrderThrottle(e.parameters.throttlePolicy, e.parameters.accountOrderLimit, e.parameters.instrumentOrderLimit)
+	}
+
+	if e.parameters.breakerOptions != nil { // The circuit breaker is the first rule, orders are blocked while it is tripped
+		e.breaker = newCircuitBreaker(e.parameters.breakerOptions)
+		e.riskManager.rules = append([]RiskRule{e.breaker}, e.riskManager.rules...)
+	}
+
 	// Account Status Retrieval
 	e.account.balance.Store(e.parameters.testParameters.initialBalance)
 	e.account.homeCurrency = e.parameters.testParameters.homeCurrency
@@ -472,6 +1211,13 @@ func (e *btEngine) start() error {
 		e.account.leverage = 1
 	}
 
+	// Initialize trading calendar
+	calendar, err := newTradingCalendar(e.parameters)
+	if err != nil {
+		return err
+	}
+	e.calendar = calendar
+
 	// Initialize Trading Instruments
 	availableInstruments, err := e.client.GetAvailableInstruments(e.account.id)
 	if err != nil {
@@ -500,6 +1246,7 @@ func (e *btEngine) start() error {
 					e.logger,
 				)
 				e.account.instruments[inst.Name].hedgeType = e.parameters.testParameters.hedge
+				e.account.instruments[inst.Name].setDetails(inst)
 				conversionInstruments[inst.Name] = newInstrumentConversion(
 					inst.Name,
 					inst.BaseCurrency,
@@ -522,6 +1269,28 @@ func (e *btEngine) start() error {
 
 	e.currencyConversionEngine.setPricePointers(e.account.instruments)
 
+	// Initialize bar aggregation, bars are only delivered to strategies that implement BarStrategy
+	e.bars = newBarBuilder(e.parameters.timeframes, e.parameters.alignHour, e.parameters.alignLocation)
+	e.barStrategy, _ = e.strategy.(BarStrategy)
+
+	// Initialize tick filter, rejections are only notified to strategies that implement TickRejectionStrategy
+	if e.parameters.filterTicks {
+		e.tickFilter = newTickFilter(e.parameters.jumpATRs, e.parameters.atrPeriod)
+	}
+	e.rejectionStrategy, _ = e.strategy.(TickRejectionStrategy)
+
+	// Initialize warm up before subscribing prices, so live ticks are buffered while warming up
+	if e.parameters.warmUpCount > 0 {
+		e.warmUp = newWarmUp(e.client, e.parameters, e.bars, e.strategy, e.logger)
+	}
+
+	// Subscribe errors of clients that read the prices from a source that can fail, as the replay client
+	if errorClient, ok := e.client.(StreamErrorClient); ok {
+		if err := errorClient.SubscribeStreamErrors(e.account.id, e.onStreamError); err != nil {
+			return err
+		}
+	}
+
 	// Subscribe prices
 	err = e.client.SubscribePrices(e.account.id, e.currencyConversionEngine.conversionInstrumentsDetails, e.onTick)
 	if err != nil {
@@ -533,7 +1302,7 @@ func (e *btEngine) start() error {
 	e.strategy.Initialize()
 
 	// Run strategy
-	e.run()
+	e.run(ctx)
 
 	// Stop strategy
 	e.strategy.OnStop()
@@ -545,6 +1314,10 @@ func (e *btEngine) onTick(tick *Tick) { // Ticks callback
 	e.ticks <- tick
 }
 
+func (e *btEngine) onStreamError(err error) { // Price source errors callback, the prices end after it
+	e.logger.Error("price subscription failed, ending backtest: " + err.Error())
+}
+
 func (e *btEngine) onOrderOpen(instrument string, units int32, side Side) {
 
 	var (
@@ -552,28 +1325,33 @@ func (e *btEngine) onOrderOpen(instrument string, units int32, side Side) {
 		order *OrderFill
 	)
 
+	if e.account.instruments[instrument].hedgeType == NoHedge {
+		if units = e.netOrder(instrument, side, units); units == 0 {
+			return
+		}
+	}
+
 	if side == Long {
 		price = e.account.instruments[instrument].Ask()
 	} else {
 		price = e.account.instruments[instrument].Bid()
 	}
 
-	leverage := e.account.instruments[instrument].leverage
-	conversionRate := e.account.instruments[instrument].ccyConversion.BaseConversionRate.Load()
-	marginUsed := float64(units) / leverage.Load() / conversionRate
+	marginUsed := e.account.instruments[instrument].MarginRequired(units)
 
 	tradeID := strconv.FormatInt(int64(e.tradesCounter.Inc()), 10)
 	time := e.account.time
 
 	if marginUsed < e.account.marginFree {
 
-		e.account.instruments[instrument].openTrade(
+		trade := e.account.instruments[instrument].openTrade(
 			tradeID,
 			side,
 			time,
 			units,
 			price,
 		)
+		trade.tag = e.parameters.sessionTag
 
 		e.account.calculateMarginUsed()
 		e.account.calculateFreeMargin()
@@ -589,6 +1367,7 @@ func (e *btEngine) onOrderOpen(instrument string, units int32, side Side) {
 			Profit:      0.0,
 			ChargedFees: 0.0,
 			Time:        time,
+			Tag:         e.parameters.sessionTag,
 		}
 
 	} else {
@@ -603,6 +1382,60 @@ func (e *btEngine) onOrderOpen(instrument string, units int32, side Side) {
 	e.strategy.OnOrderFill(order)
 }
 
+// netOrder closes the opposite trades, oldest first, with the units of an order in an account without hedging.
+// Returns the units left to open a trade.
+func (e *btEngine) netOrder(instrument string, side Side, units int32) int32 {
+
+	opposite := e.account.instruments[instrument].shortPosition
+	if side == Short {
+		opposite = e.account.instruments[instrument].longPosition
+	}
+
+	for units > 0 && opposite.TradesNumber() > 0 {
+
+		trade := opposite.TradeByOrder(0)
+
+		if trade.units <= units {
+			units -= trade.units
+			e.onCloseTrade(trade.id, instrument)
+			continue
+		}
+
+		e.onReduceTrade(trade, units)
+		units = 0
+	}
+
+	return units
+}
+
+// onReduceTrade closes part of the units of a trade, realizing the same part of its profit.
+func (e *btEngine) onReduceTrade(trade *Trade, units int32) {
+
+	part := float64(units) / float64(trade.units)
+	profit := trade.unrealizedNetProfit * part
+	fees := trade.chargedFees.Load() * part
+
+	e.account.balance.Add(trade.unrealizedEffectiveProfit * part)
+	trade.chargedFees.Sub(fees)
+	e.account.instruments[trade.instrumentName].reduceTrade(trade.id, units)
+	e.account.calculateUnrealized()
+	e.account.calculateMarginUsed()
+	e.account.calculateFreeMargin()
+
+	e.strategy.OnOrderFill(&OrderFill{
+		TradeClose:  true,
+		OrderID:     trade.id,
+		TradeID:     trade.id,
+		Side:        trade.side,
+		Instrument:  e.instrumentsDetails[trade.instrumentName],
+		Price:       trade.CurrentPrice(),
+		Units:       units,
+		Profit:      profit,
+		ChargedFees: fees,
+		Time:        e.account.time,
+	})
+}
+
 func (e *btEngine) onCloseTrade(tradeID, instrument string) {
 
 	var (
@@ -647,51 +1480,114 @@ func (e *btEngine) onCloseTrade(tradeID, instrument string) {
 
 }
 
-func (e *btEngine) run() {
+func (e *btEngine) run(ctx context.Context) {
+
+	first := true
 
 	for { // Application blocks until ticks channel is closed
 
 		select {
 		case <-e.endOfSession:
 			return
+		case <-ctx.Done():
+			return
 		case tick := <-e.ticks:
 
 			if tick == nil {
 				return
 			}
 
-			if _, exist := e.account.instruments[tick.Instrument]; exist {
+			if e.warmingUp() { // Warm up with the history before the first tick
+				e.warmUp.run(tick.Time)
+				e.warmUp.finish()
+			}
 
-				e.account.instruments[tick.Instrument].updatePrice(tick)
-				e.currencyConversionEngine.updateRate(tick.Instrument)
-				e.account.time = tick.Time
+			if first && e.calendar.flattenBefore > 0 { // The simulated clock starts with the first tick
+				scheduleWeekendFlatten(e, e.scheduler, e.calendar.flattenBefore, weeklyClose(tick.Time))
+			}
+			first = false
 
-				if e.ready {
-					e.account.calculateUnrealized()
-					e.account.calculateMarginUsed()
-					e.account.calculateFreeMargin()
+			// Scheduled tasks due before the tick are executed first, with the simulated clock
+			e.scheduler.anchor(tick.Time)
+			e.runScheduledTasks(tick.Time)
 
-					e.strategy.OnTick(tick)
-				} else {
-					e.checkState()
-				}
+			e.processTick(tick)
+		}
+	}
+}
+
+func (e *btEngine) processTick(tick *Tick) {
+
+	if e.tickFilter != nil {
+		if reason, accepted := e.tickFilter.check(tick); !accepted {
 
-			} else { // This is the auxiliar instrument update (price state is kept only on the ccyconv engine)
+			e.logger.Debug("tick dropped by the filter: " + reason.String())
 
-				if _, exist := e.currencyConversionEngine.conversionInstruments[tick.Instrument]; exist {
+			if e.rejectionStrategy != nil {
+				e.rejectionStrategy.OnTickRejected(tick, reason)
+			}
+
+			return
+		}
+	}
+
+	if _, exist := e.account.instruments[tick.Instrument]; exist {
+
+		e.account.instruments[tick.Instrument].updatePrice(tick)
+		e.currencyConversionEngine.updateRate(tick.Instrument)
+		e.account.time = tick.Time
+		completedBars := e.bars.update(tick)
+
+		if e.ready && e.pendingBars != nil {
+			completedBars = append(e.pendingBars, completedBars...)
+			e.pendingBars = nil
+		}
+
+		if e.ready {
+			e.account.calculateUnrealized()
+			e.account.calculateMarginUsed()
+			e.account.calculateFreeMargin()
+
+			if e.breaker != nil {
+				if breach := e.breaker.update(e.account.time, e.account.Equity()); breach != nil {
+					e.onBreach(breach)
+				}
+			}
 
-					inst := e.currencyConversionEngine.conversionInstruments[tick.Instrument]
-					inst.Bid.Store(tick.Bid)
-					inst.Ask.Store(tick.Ask)
-					e.currencyConversionEngine.updateRate(tick.Instrument)
-				} else {
-					e.logger.Warn("received a tick from an instrument that was not subscribed and it has been ignored")
+			if e.barStrategy != nil {
+				for _, bar := range completedBars {
+					e.barStrategy.OnBar(bar)
 				}
 			}
+
+			e.strategy.OnTick(tick)
+		} else {
+			// Bars completed while prices are missing are delivered with the first tick after ready
+			e.pendingBars = append(e.pendingBars, completedBars...)
+			e.checkState()
+		}
+
+	} else { // This is the auxiliar instrument update (price state is kept only on the ccyconv engine)
+
+		if _, exist := e.currencyConversionEngine.conversionInstruments[tick.Instrument]; exist {
+
+			inst := e.currencyConversionEngine.conversionInstruments[tick.Instrument]
+			inst.Bid.Store(tick.Bid)
+			inst.Ask.Store(tick.Ask)
+			e.currencyConversionEngine.updateRate(tick.Instrument)
+		} else {
+			e.logger.Warn("received a tick from an instrument that was not subscribed and it has been ignored")
 		}
 	}
 }
 
+func (e *btEngine) runScheduledTasks(now time.Time) {
+	for task := e.scheduler.pop(now, false); task != nil; task = e.scheduler.pop(now, false) {
+		e.account.time = task.at
+		task.fn()
+	}
+}
+
 // Check if all instruments have already a price defined
 func (e *btEngine) checkState() {
 	for _, inst := range e.currencyConversionEngine.conversionInstruments {
@@ -703,6 +1599,93 @@ func (e *btEngine) checkState() {
 	e.ready = true
 }
 
+func (e *btEngine) onBreach(breach *Breach) {
+
+	e.logger.Warn("circuit breaker tripped, " + breach.Limit + " limit breached")
+
+	if e.breaker.closeAll {
+		closeAllTrades(e)
+	}
+
+	if breakerStrategy, ok := e.strategy.(CircuitBreakerStrategy); ok {
+		breakerStrategy.OnCircuitBreak(breach)
+	}
+}
+
+// submitOrder opens the trade if the throttle allows it, otherwise it waits for the rate limits or is rejected.
+func (e *btEngine) submitOrder(order *OrderRequest) {
+
+	if e.throttle != nil {
+
+		admitted, rejected := e.throttle.admit(order, e.netting(order.Instrument))
+
+		for _, throttled := range rejected {
+			e.rejectOrder(throttled.order, throttled.rejection)
+		}
+
+		if !admitted {
+			if e.throttle.needsRelease() {
+				e.scheduleRelease(order.Time)
+			}
+			return
+		}
+	}
+
+	e.sendOrder(order)
+}
+
+// scheduleRelease opens the trades waiting for the throttle, at the given time and then when the rate limits allow it.
+func (e *btEngine) scheduleRelease(at time.Time) {
+
+	e.scheduler.schedule(at, 0, func() {
+
+		orders, next := e.throttle.release(e.account.time)
+
+		for _, order := range orders {
+			e.sendOrder(order)
+		}
+
+		if !next.IsZero() {
+			e.scheduleRelease(next)
+		}
+	})
+}
+
+func (e *btEngine) sendOrder(order *OrderRequest) {
+
+	if rejection := e.riskManager.check(e.account, order, e.pendingOrders()); rejection != nil {
+		e.rejectOrder(order, rejection)
+		return
+	}
+
+	e.onOrderOpen(order.Instrument, order.Units, order.Side)
+}
+
+// pendingOrders returns the orders waiting for the throttle, the other orders are filled as soon as they are sent.
+func (e *btEngine) pendingOrders() []*OrderRequest {
+
+	if e.throttle != nil {
+		return e.throttle.waiting()
+	}
+
+	return nil
+}
+
+// rejectOrder notifies the strategy of an order rejected by the engine, as an order fill.
+func (e *btEngine) rejectOrder(order *OrderRequest, rejection *OrderRejection) {
+
+	e.logger.Debug("order rejected, " + rejection.Error())
+
+	e.strategy.OnOrderFill(&OrderFill{
+		Error:      rejection.Rule,
+		Rejection:  rejection,
+		Instrument: e.instrumentsDetails[order.Instrument],
+		Side:       order.Side,
+		Units:      order.Units,
+		Time:       e.account.time,
+	})
+}
+
 /**************************
 *
 *	Accessible Methods
@@ -715,22 +1698,99 @@ func (e *btEngine) Account() *Account {
 
 func (e *btEngine) Buy(instrument string, units int32) {
 
-	e.onOrderOpen(instrument, units, Long)
+	if e.warmingUp() {
+		e.logger.Debug("order ignored during strategy warm up")
+		return
+	}
+
+	order := &OrderRequest{Instrument: instrument, Side: Long, Units: units, Time: e.account.time}
+
+	if !e.MarketOpen(instrument) && !e.reducesPosition(instrument, Long, units) {
+		e.rejectOrder(order, &OrderRejection{Rule: MarketClosedRule, Reason: "new entries on " + instrument + " are not allowed"})
+		return
+	}
+
+	e.submitOrder(order)
 
 }
 
 func (e *btEngine) Sell(instrument string, units int32) {
 
-	e.onOrderOpen(instrument, units, Short)
+	if e.warmingUp() {
+		e.logger.Debug("order ignored during strategy warm up")
+		return
+	}
+
+	order := &OrderRequest{Instrument: instrument, Side: Short, Units: units, Time: e.account.time}
+
+	if !e.MarketOpen(instrument) && !e.reducesPosition(instrument, Short, units) {
+		e.rejectOrder(order, &OrderRejection{Rule: MarketClosedRule, Reason: "new entries on " + instrument + " are not allowed"})
+		return
+	}
+
+	e.submitOrder(order)
 
 }
 
 func (e *btEngine) CloseTrade(instrument, id string) {
 
+	if e.warmingUp() {
+		e.logger.Debug("order ignored during strategy warm up")
+		return
+	}
+
 	e.onCloseTrade(id, instrument)
 
 }
 
+// Schedule runs fn at the given simulated time, a zero time runs it before the first tick.
+func (e *btEngine) Schedule(at time.Time, fn func()) *ScheduledTask {
+	return e.scheduler.schedule(at, 0, fn)
+}
+
+// Every runs fn every interval of simulated time, starting from the first tick if there is no tick yet.
+func (e *btEngine) Every(interval time.Duration, fn func()) *ScheduledTask {
+
+	if interval <= 0 {
+		e.logger.Error("scheduling interval must be positive")
+		return newCancelledTask()
+	}
+
+	if e.account == nil || e.account.time.IsZero() {
+		return e.scheduler.schedule(time.Time{}, interval, fn)
+	}
+
+	return e.scheduler.schedule(e.account.time.Add(interval), interval, fn)
+}
+
+func (e *btEngine) MarketOpen(instrument string) bool {
+	return e.calendar.entriesAllowed(instrument, e.account.time)
+}
+
+// reducesPosition returns true if the order only reduces the net position, so it's allowed when entries are not.
+// netting returns true if the account has no hedging on the instrument, so its positions are netted.
+func (e *btEngine) netting(instrument string) bool {
+	inst, exist := e.account.instruments[instrument]
+	return exist && inst.hedgeType == NoHedge
+}
+
+func (e *btEngine) reducesPosition(instrument string, side Side, units int32) bool {
+	inst, exist := e.account.instruments[instrument]
+	return exist && inst.reduces(side, units)
+}
+
+func (e *btEngine) RejectedTicks() map[TickRejection]int64 {
+	return e.tickFilter.rejected()
+}
+
+func (e *btEngine) Sizer() *Sizer {
+	return e.sizer
+}
+
+func (e *btEngine) State() *State {
+	return e.values
+}
+
 func (e *btEngine) StopSession() {
 	e.endOfSession <- true
 }
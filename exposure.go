@@ -0,0 +1,67 @@
+package gotrader
+
+import (
+	"math"
+)
+
+// CurrencyExposure represents the exposure to a currency in the home currency, aggregated from
+// the base and quote currency legs of the positions. Net is positive when the account is long
+// the currency, Gross is the sum of the absolute legs.
+type CurrencyExposure struct {
+	Net   float64
+	Gross float64
+}
+
+func (c *CurrencyExposure) add(leg float64) {
+	c.Net += leg
+	c.Gross += math.Abs(leg)
+}
+
+// exposureLegs returns the value in the home currency of the base and quote currency legs of the
+// units of an instrument, at the current mid price. Legs are positive when the currency is bought.
+func exposureLegs(inst *Instrument, side Side, units int32) (base, quote float64) {
+
+	if inst.ccyConversion == nil {
+		return 0, 0
+	}
+
+	signedUnits := float64(units) * sideSign(side)
+	mid := (inst.Bid() + inst.Ask()) / 2
+
+	base = signedUnits * inst.ccyConversion.BaseConversionRate.Load()
+	quote = -signedUnits * mid * inst.ccyConversion.QuoteConversionRate.Load()
+
+	return base, quote
+}
+
+// accountExposure aggregates the exposure of the open positions by currency.
+func accountExposure(a *Account) map[string]*CurrencyExposure {
+
+	exposures := make(map[string]*CurrencyExposure)
+
+	add := func(currency string, leg float64) {
+
+		exposure, exist := exposures[currency]
+		if !exist {
+			exposure = &CurrencyExposure{}
+			exposures[currency] = exposure
+		}
+
+		exposure.add(leg)
+	}
+
+	for _, inst := range a.instruments {
+		for _, position := range []*Position{inst.longPosition, inst.shortPosition} {
+
+			if position.Units() == 0 {
+				continue
+			}
+
+			base, quote := exposureLegs(inst, position.side, position.Units())
+			add(inst.baseCurrency, base)
+			add(inst.quoteCurrency, quote)
+		}
+	}
+
+	return exposures
+}
@@ -0,0 +1,61 @@
+package gotrader
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAccountExposure(t *testing.T) {
+
+	account := newAccount("test")
+	account.homeCurrency = "USD"
+
+	instrument := func(name, base, quote string, bid, ask, baseRate, quoteRate float64) *Instrument {
+
+		inst := newInstrument(name, base, quote, 20, -4, nil)
+		inst.ccyConversion = newInstrumentConversion(name, base, quote)
+		inst.ccyConversion.BaseConversionRate.Store(baseRate)
+		inst.ccyConversion.QuoteConversionRate.Store(quoteRate)
+		inst.updatePrice(&Tick{Instrument: name, Bid: bid, Ask: ask})
+		account.instruments[name] = inst
+
+		return inst
+	}
+
+	eurusd := instrument("EUR_USD", "EUR", "USD", 1.1, 1.1, 1.1, 1)
+	eurgbp := instrument("EUR_GBP", "EUR", "GBP", 0.9, 0.9, 1.1, 1.25)
+
+	eurusd.openTrade("1", Long, time.Time{}, 1000, 1.1)
+	eurgbp.openTrade("2", Short, time.Time{}, 2000, 0.9)
+
+	expected := map[string]CurrencyExposure{
+		"EUR": {Net: -1100, Gross: 3300},
+		"USD": {Net: -1100, Gross: 1100},
+		"GBP": {Net: 2250, Gross: 2250},
+	}
+
+	exposures := account.Exposure()
+
+	for currency, exposure := range expected {
+		if math.Abs(exposures[currency].Net-exposure.Net) > 1e-6 || math.Abs(exposures[currency].Gross-exposure.Gross) > 1e-6 {
+			t.Errorf("%s exposure %+v, expected %+v", currency, exposures[currency], exposure)
+		}
+	}
+
+	rules := []struct {
+		rule     RiskRule
+		order    *OrderRequest
+		rejected bool
+	}{
+		{MaxNetExposure(1500), &OrderRequest{Instrument: "EUR_GBP", Side: Long, Units: 1000}, false},
+		{MaxNetExposure(1500), &OrderRequest{Instrument: "EUR_USD", Side: Short, Units: 1000}, true},
+		{MaxGrossExposure(3500), &OrderRequest{Instrument: "EUR_USD", Side: Long, Units: 500}, true},
+	}
+
+	for i, test := range rules {
+		if rejection := test.rule.Check(account, test.order); (rejection != nil) != test.rejected {
+			t.Errorf("rule %d: rejection %+v, expected rejected %v", i, rejection, test.rejected)
+		}
+	}
+}
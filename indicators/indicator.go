@@ -0,0 +1,211 @@
+// Package indicators implements streaming technical indicators, every update is O(1)
+// (amortized for the windowed minimum/maximum), so they can be fed tick by tick.
+package indicators
+
+import (
+	"github.com/luismcruz/gotrader"
+)
+
+// Source selects the price of a tick or bar used to feed an indicator.
+type Source int
+
+const (
+	// Mid is the average between bid and ask
+	Mid Source = iota
+
+	// Bid is the bid price
+	Bid
+
+	// Ask is the ask price
+	Ask
+)
+
+// Indicator is the behaviour shared by all the indicators of this package.
+type Indicator interface {
+	UpdateTick(tick *gotrader.Tick)
+	UpdateBar(bar *gotrader.Bar)
+	Ready() bool
+}
+
+// TickPrice returns the price of the tick for the given source.
+func TickPrice(tick *gotrader.Tick, source Source) float64 {
+
+	switch source {
+	case Bid:
+		return tick.Bid
+	case Ask:
+		return tick.Ask
+	}
+
+	return (tick.Bid + tick.Ask) / 2
+}
+
+// BarPrices returns the OHLC prices of the bar for the given source.
+func BarPrices(bar *gotrader.Bar, source Source) gotrader.OHLC {
+
+	switch source {
+	case Bid:
+		return bar.Bid
+	case Ask:
+		return bar.Ask
+	}
+
+	return bar.Mid
+}
+
+/**************************
+*
+*	Internal Helpers
+*
+***************************/
+
+// window is a fixed size ring buffer keeping the running sum of its values.
+type window struct {
+	values []float64
+	next   int
+	count  int
+	sum    float64
+}
+
+func newWindow(size int) *window {
+
+	if size < 1 {
+		size = 1
+	}
+
+	return &window{values: make([]float64, size)}
+}
+
+// push adds a value and returns the value that left the window, if any.
+func (w *window) push(value float64) (float64, bool) {
+
+	var (
+		old     float64
+		removed bool
+	)
+
+	if w.full() {
+		old = w.values[w.next]
+		removed = true
+		w.sum -= old
+	} else {
+		w.count++
+	}
+
+	w.values[w.next] = value
+	w.sum += value
+	w.next = (w.next + 1) % len(w.values)
+
+	return old, removed
+}
+
+func (w *window) full() bool {
+	return w.count == len(w.values)
+}
+
+func (w *window) mean() float64 {
+
+	if w.count == 0 {
+		return 0
+	}
+
+	return w.sum / float64(w.count)
+}
+
+// variance returns the population variance of the values in the window. It is calculated from the values,
+// as the running sums lose precision with prices far from zero.
+func (w *window) variance() float64 {
+
+	if w.count == 0 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, value := range w.values[:w.count] {
+		mean += value
+	}
+	mean /= float64(w.count)
+
+	variance := 0.0
+	for _, value := range w.values[:w.count] {
+		variance += (value - mean) * (value - mean)
+	}
+
+	return variance / float64(w.count)
+}
+
+// extremum keeps the minimum or maximum of the last size values with a monotonic deque.
+type extremum struct {
+	size    int
+	max     bool
+	index   int
+	indexes []int
+	values  []float64
+}
+
+func newExtremum(size int, max bool) *extremum {
+
+	if size < 1 {
+		size = 1
+	}
+
+	return &extremum{size: size, max: max}
+}
+
+func (e *extremum) push(value float64) {
+
+	for len(e.values) > 0 {
+
+		last := e.values[len(e.values)-1]
+
+		if (e.max && last > value) || (!e.max && last < value) {
+			break
+		}
+
+		e.values = e.values[:len(e.values)-1]
+		e.indexes = e.indexes[:len(e.indexes)-1]
+	}
+
+	e.values = append(e.values, value)
+	e.indexes = append(e.indexes, e.index)
+
+	if e.indexes[0] <= e.index-e.size {
+		e.values = e.values[1:]
+		e.indexes = e.indexes[1:]
+	}
+
+	e.index++
+}
+
+func (e *extremum) value() float64 {
+
+	if len(e.values) == 0 {
+		return 0
+	}
+
+	return e.values[0]
+}
+
+// wilder implements the Wilder smoothing, seeded with the simple average of the first period values.
+type wilder struct {
+	period int
+	count  int
+	value  float64
+}
+
+func (w *wilder) update(value float64) float64 {
+
+	w.count++
+
+	if w.count <= w.period {
+		w.value += (value - w.value) / float64(w.count)
+	} else {
+		w.value = (w.value*float64(w.period-1) + value) / float64(w.period)
+	}
+
+	return w.value
+}
+
+func (w *wilder) ready() bool {
+	return w.count >= w.period
+}
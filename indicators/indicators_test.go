@@ -0,0 +1,223 @@
+package indicators
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/luismcruz/gotrader"
+)
+
+// Reference values were computed with a non-streaming implementation of each indicator,
+// recalculating the whole series at every step.
+var closes = []float64{
+	1.1012, 1.1025, 1.1019, 1.1034, 1.1048, 1.1041, 1.1056, 1.1062, 1.1050, 1.1043,
+	1.1031, 1.1027, 1.1039, 1.1052, 1.1066, 1.1071, 1.1068, 1.1080, 1.1092, 1.1085,
+	1.1077, 1.1064, 1.1058, 1.1070, 1.1083, 1.1095, 1.1102, 1.1098, 1.1110, 1.1121,
+}
+
+func testBars() []*gotrader.Bar {
+
+	bars := make([]*gotrader.Bar, len(closes))
+
+	for i, c := range closes {
+		bars[i] = &gotrader.Bar{
+			Instrument: "EUR_USD",
+			Mid: gotrader.OHLC{
+				Open:  c,
+				High:  c + 0.0008 + 0.0001*float64(i%3),
+				Low:   c - 0.0007 - 0.0001*float64(i%4),
+				Close: c,
+			},
+			Volume: int64(10 + (i*7)%13),
+		}
+	}
+
+	return bars
+}
+
+func feed(indicator Indicator, bars []*gotrader.Bar) {
+	for _, bar := range bars {
+		indicator.UpdateBar(bar)
+	}
+}
+
+func assertClose(t *testing.T, name string, got, want float64) {
+	t.Helper()
+
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+func TestIndicators(t *testing.T) {
+
+	bars := testBars()
+
+	t.Run("SMA", func(t *testing.T) {
+		sma := NewSMA(10, Mid)
+		feed(sma, bars)
+		assertClose(t, "sma", sma.Value(), 1.1087799999999999)
+	})
+
+	t.Run("EMA", func(t *testing.T) {
+		ema := NewEMA(10, Mid)
+		feed(ema, bars)
+		assertClose(t, "ema", ema.Value(), 1.1093848545695653)
+	})
+
+	t.Run("WMA", func(t *testing.T) {
+		wma := NewWMA(10, Mid)
+		feed(wma, bars)
+		assertClose(t, "wma", wma.Value(), 1.1097127272727272)
+	})
+
+	t.Run("RSI", func(t *testing.T) {
+		rsi := NewRSI(14, Mid)
+		feed(rsi, bars)
+		assertClose(t, "rsi", rsi.Value(), 71.9365228670737)
+	})
+
+	t.Run("MACD", func(t *testing.T) {
+		macd := NewMACD(5, 10, 4, Mid)
+		feed(macd, bars)
+		assertClose(t, "macd", macd.Value(), 0.0011463865334988554)
+		assertClose(t, "signal", macd.Signal(), 0.0009430692948882054)
+		assertClose(t, "histogram", macd.Histogram(), 0.0011463865334988554-0.0009430692948882054)
+	})
+
+	t.Run("BollingerBands", func(t *testing.T) {
+		bb := NewBollingerBands(20, 2, Mid)
+		feed(bb, bars)
+		assertClose(t, "middle", bb.Middle(), 1.107445)
+		assertClose(t, "upper", bb.Upper(), 1.1123686064018157)
+		assertClose(t, "lower", bb.Lower(), 1.1025213935981844)
+	})
+
+	t.Run("ATR", func(t *testing.T) {
+		atr := NewATR(14, Mid)
+		feed(atr, bars)
+		assertClose(t, "atr", atr.Value(), 0.0019954867728306995)
+	})
+
+	t.Run("ADX", func(t *testing.T) {
+		adx := NewADX(7, Mid)
+		feed(adx, bars)
+		assertClose(t, "adx", adx.Value(), 37.42049252517349)
+		assertClose(t, "+di", adx.PlusDI(), 39.291785983415764)
+		assertClose(t, "-di", adx.MinusDI(), 10.916782437070294)
+	})
+
+	t.Run("Stochastic", func(t *testing.T) {
+		stoch := NewStochastic(14, 3, Mid)
+		feed(stoch, bars)
+		assertClose(t, "%k", stoch.K(), 87.8048780487818)
+		assertClose(t, "%d", stoch.D(), 84.24183765647226)
+	})
+
+	t.Run("DonchianChannels", func(t *testing.T) {
+		dc := NewDonchianChannels(20, Mid)
+		feed(dc, bars)
+		assertClose(t, "upper", dc.Upper(), 1.1131)
+		assertClose(t, "lower", dc.Lower(), 1.1017000000000001)
+	})
+
+	t.Run("KeltnerChannels", func(t *testing.T) {
+		kc := NewKeltnerChannels(10, 7, 2, Mid)
+		feed(kc, bars)
+		assertClose(t, "middle", kc.Middle(), 1.1093848545695653)
+		assertClose(t, "upper", kc.Upper(), 1.113390449698887)
+		assertClose(t, "lower", kc.Lower(), 1.1053792594402436)
+	})
+
+	t.Run("VWAP", func(t *testing.T) {
+		vwap := NewVWAP(Mid)
+		feed(vwap, bars)
+		assertClose(t, "vwap", vwap.Value(), 1.1062804378531075)
+	})
+}
+
+func TestReadiness(t *testing.T) {
+
+	bars := testBars()
+
+	tests := []struct {
+		name      string
+		indicator Indicator
+		warmUp    int // bars needed to be ready
+	}{
+		{"SMA", NewSMA(10, Mid), 10},
+		{"EMA", NewEMA(10, Mid), 10},
+		{"WMA", NewWMA(10, Mid), 10},
+		{"RSI", NewRSI(14, Mid), 15},
+		{"MACD", NewMACD(5, 10, 4, Mid), 13},
+		{"BollingerBands", NewBollingerBands(20, 2, Mid), 20},
+		{"ATR", NewATR(14, Mid), 14},
+		{"ADX", NewADX(7, Mid), 14},
+		{"Stochastic", NewStochastic(14, 3, Mid), 16},
+		{"DonchianChannels", NewDonchianChannels(20, Mid), 20},
+		{"KeltnerChannels", NewKeltnerChannels(10, 7, 2, Mid), 10},
+		{"VWAP", NewVWAP(Mid), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			feed(tt.indicator, bars[:tt.warmUp-1])
+			if tt.indicator.Ready() {
+				t.Errorf("ready after %d bars, want %d", tt.warmUp-1, tt.warmUp)
+			}
+
+			tt.indicator.UpdateBar(bars[tt.warmUp-1])
+			if !tt.indicator.Ready() {
+				t.Errorf("not ready after %d bars", tt.warmUp)
+			}
+		})
+	}
+}
+
+func TestTickSource(t *testing.T) {
+
+	tick := &gotrader.Tick{Instrument: "EUR_USD", Bid: 1.1000, Ask: 1.1002}
+
+	assertClose(t, "bid", TickPrice(tick, Bid), 1.1000)
+	assertClose(t, "ask", TickPrice(tick, Ask), 1.1002)
+	assertClose(t, "mid", TickPrice(tick, Mid), 1.1001)
+
+	sma := NewSMA(2, Ask)
+	sma.UpdateTick(tick)
+	sma.UpdateTick(&gotrader.Tick{Instrument: "EUR_USD", Bid: 1.1002, Ask: 1.1004})
+	assertClose(t, "sma", sma.Value(), 1.1003)
+}
+
+func TestBollingerBandsPrecision(t *testing.T) {
+
+	const period = 20
+
+	bb := NewBollingerBands(period, 2, Mid)
+	random := rand.New(rand.NewSource(1))
+	prices := make([]float64, 0, 1000000)
+
+	// Tick sized moves around 1.1, where the running sums of squares lose precision
+	for price := 1.1; len(prices) < cap(prices); price += (random.Float64() - 0.5) * 0.0002 {
+		prices = append(prices, price)
+		bb.Update(price)
+	}
+
+	last := prices[len(prices)-period:]
+
+	mean := 0.0
+	for _, price := range last {
+		mean += price
+	}
+	mean /= period
+
+	variance := 0.0
+	for _, price := range last {
+		variance += (price - mean) * (price - mean)
+	}
+
+	if want := math.Sqrt(variance / period); math.Abs(bb.StdDev()-want) > want*1e-6 {
+		t.Errorf("std dev %v, want %v", bb.StdDev(), want)
+	}
+}
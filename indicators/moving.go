@@ -0,0 +1,154 @@
+package indicators
+
+import (
+	"github.com/luismcruz/gotrader"
+)
+
+// SMA is the simple moving average of the last period prices.
+type SMA struct {
+	source Source
+	window *window
+}
+
+// NewSMA is the SMA constructor.
+func NewSMA(period int, source Source) *SMA {
+	return &SMA{source: source, window: newWindow(period)}
+}
+
+// Update adds a price and returns the current average.
+func (i *SMA) Update(price float64) float64 {
+	i.window.push(price)
+	return i.Value()
+}
+
+// UpdateTick adds the tick price of the indicator source.
+func (i *SMA) UpdateTick(tick *gotrader.Tick) {
+	i.Update(TickPrice(tick, i.source))
+}
+
+// UpdateBar adds the bar close of the indicator source.
+func (i *SMA) UpdateBar(bar *gotrader.Bar) {
+	i.Update(BarPrices(bar, i.source).Close)
+}
+
+// Value returns the current average.
+func (i *SMA) Value() float64 {
+	return i.window.mean()
+}
+
+// Ready returns true when period prices have been received.
+func (i *SMA) Ready() bool {
+	return i.window.full()
+}
+
+// EMA is the exponential moving average with smoothing 2/(period+1),
+// seeded with the simple average of the first period prices.
+type EMA struct {
+	source Source
+	period int
+	alpha  float64
+	count  int
+	value  float64
+}
+
+// NewEMA is the EMA constructor.
+func NewEMA(period int, source Source) *EMA {
+
+	if period < 1 {
+		period = 1
+	}
+
+	return &EMA{
+		source: source,
+		period: period,
+		alpha:  2 / float64(period+1),
+	}
+}
+
+// Update adds a price and returns the current average.
+func (i *EMA) Update(price float64) float64 {
+
+	i.count++
+
+	if i.count <= i.period {
+		i.value += (price - i.value) / float64(i.count)
+	} else {
+		i.value += i.alpha * (price - i.value)
+	}
+
+	return i.value
+}
+
+// UpdateTick adds the tick price of the indicator source.
+func (i *EMA) UpdateTick(tick *gotrader.Tick) {
+	i.Update(TickPrice(tick, i.source))
+}
+
+// UpdateBar adds the bar close of the indicator source.
+func (i *EMA) UpdateBar(bar *gotrader.Bar) {
+	i.Update(BarPrices(bar, i.source).Close)
+}
+
+// Value returns the current average.
+func (i *EMA) Value() float64 {
+	return i.value
+}
+
+// Ready returns true when period prices have been received.
+func (i *EMA) Ready() bool {
+	return i.count >= i.period
+}
+
+// WMA is the linearly weighted moving average, the most recent price has weight period.
+type WMA struct {
+	source   Source
+	window   *window
+	weighted float64
+}
+
+// NewWMA is the WMA constructor.
+func NewWMA(period int, source Source) *WMA {
+	return &WMA{source: source, window: newWindow(period)}
+}
+
+// Update adds a price and returns the current average.
+func (i *WMA) Update(price float64) float64 {
+
+	sum := i.window.sum
+
+	if i.window.full() {
+		i.weighted += float64(i.window.count)*price - sum
+	} else {
+		i.weighted += float64(i.window.count+1) * price
+	}
+
+	i.window.push(price)
+
+	return i.Value()
+}
+
+// UpdateTick adds the tick price of the indicator source.
+func (i *WMA) UpdateTick(tick *gotrader.Tick) {
+	i.Update(TickPrice(tick, i.source))
+}
+
+// UpdateBar adds the bar close of the indicator source.
+func (i *WMA) UpdateBar(bar *gotrader.Bar) {
+	i.Update(BarPrices(bar, i.source).Close)
+}
+
+// Value returns the current average.
+func (i *WMA) Value() float64 {
+
+	n := float64(i.window.count)
+	if n == 0 {
+		return 0
+	}
+
+	return i.weighted / (n * (n + 1) / 2)
+}
+
+// Ready returns true when period prices have been received.
+func (i *WMA) Ready() bool {
+	return i.window.full()
+}
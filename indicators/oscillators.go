@@ -0,0 +1,226 @@
+package indicators
+
+import (
+	"github.com/luismcruz/gotrader"
+)
+
+// RSI is the Wilder relative strength index.
+type RSI struct {
+	source    Source
+	gains     *wilder
+	losses    *wilder
+	previous  float64
+	hasPrice  bool
+	lastValue float64
+}
+
+// NewRSI is the RSI constructor.
+func NewRSI(period int, source Source) *RSI {
+
+	if period < 1 {
+		period = 1
+	}
+
+	return &RSI{
+		source: source,
+		gains:  &wilder{period: period},
+		losses: &wilder{period: period},
+	}
+}
+
+// Update adds a price and returns the current index.
+func (i *RSI) Update(price float64) float64 {
+
+	if !i.hasPrice {
+		i.previous = price
+		i.hasPrice = true
+		return i.lastValue
+	}
+
+	change := price - i.previous
+	i.previous = price
+
+	gain, loss := 0.0, 0.0
+	if change > 0 {
+		gain = change
+	} else {
+		loss = -change
+	}
+
+	avgGain := i.gains.update(gain)
+	avgLoss := i.losses.update(loss)
+
+	switch {
+	case avgLoss == 0 && avgGain == 0:
+		i.lastValue = 50
+	case avgLoss == 0:
+		i.lastValue = 100
+	default:
+		i.lastValue = 100 - 100/(1+avgGain/avgLoss)
+	}
+
+	return i.lastValue
+}
+
+// UpdateTick adds the tick price of the indicator source.
+func (i *RSI) UpdateTick(tick *gotrader.Tick) {
+	i.Update(TickPrice(tick, i.source))
+}
+
+// UpdateBar adds the bar close of the indicator source.
+func (i *RSI) UpdateBar(bar *gotrader.Bar) {
+	i.Update(BarPrices(bar, i.source).Close)
+}
+
+// Value returns the current index, between 0 and 100.
+func (i *RSI) Value() float64 {
+	return i.lastValue
+}
+
+// Ready returns true when period price changes have been received.
+func (i *RSI) Ready() bool {
+	return i.gains.ready()
+}
+
+// MACD is the moving average convergence divergence, the difference between a fast and a slow EMA,
+// with a signal EMA of that difference.
+type MACD struct {
+	source Source
+	fast   *EMA
+	slow   *EMA
+	signal *EMA
+	macd   float64
+}
+
+// NewMACD is the MACD constructor, usually used with periods 12, 26 and 9.
+func NewMACD(fastPeriod, slowPeriod, signalPeriod int, source Source) *MACD {
+	return &MACD{
+		source: source,
+		fast:   NewEMA(fastPeriod, source),
+		slow:   NewEMA(slowPeriod, source),
+		signal: NewEMA(signalPeriod, source),
+	}
+}
+
+// Update adds a price and returns the current MACD line.
+func (i *MACD) Update(price float64) float64 {
+
+	i.fast.Update(price)
+	i.slow.Update(price)
+
+	if i.slow.Ready() && i.fast.Ready() {
+		i.macd = i.fast.Value() - i.slow.Value()
+		i.signal.Update(i.macd)
+	}
+
+	return i.macd
+}
+
+// UpdateTick adds the tick price of the indicator source.
+func (i *MACD) UpdateTick(tick *gotrader.Tick) {
+	i.Update(TickPrice(tick, i.source))
+}
+
+// UpdateBar adds the bar close of the indicator source.
+func (i *MACD) UpdateBar(bar *gotrader.Bar) {
+	i.Update(BarPrices(bar, i.source).Close)
+}
+
+// Value returns the MACD line.
+func (i *MACD) Value() float64 {
+	return i.macd
+}
+
+// Signal returns the signal line.
+func (i *MACD) Signal() float64 {
+	return i.signal.Value()
+}
+
+// Histogram returns the difference between the MACD and signal lines.
+func (i *MACD) Histogram() float64 {
+	return i.macd - i.signal.Value()
+}
+
+// Ready returns true when the signal line is defined.
+func (i *MACD) Ready() bool {
+	return i.signal.Ready()
+}
+
+// Stochastic is the stochastic oscillator, %K is the position of the close in the
+// high/low range of the last kPeriod bars and %D its simple average over dPeriod values.
+type Stochastic struct {
+	source  Source
+	highest *extremum
+	lowest  *extremum
+	count   int
+	kPeriod int
+	k       float64
+	d       *SMA
+}
+
+// NewStochastic is the Stochastic constructor.
+func NewStochastic(kPeriod, dPeriod int, source Source) *Stochastic {
+
+	if kPeriod < 1 {
+		kPeriod = 1
+	}
+
+	return &Stochastic{
+		source:  source,
+		highest: newExtremum(kPeriod, true),
+		lowest:  newExtremum(kPeriod, false),
+		kPeriod: kPeriod,
+		d:       NewSMA(dPeriod, source),
+	}
+}
+
+// UpdateHLC adds the high, low and close of a period and returns the current %K.
+func (i *Stochastic) UpdateHLC(high, low, close float64) float64 {
+
+	i.highest.push(high)
+	i.lowest.push(low)
+	i.count++
+
+	if i.count < i.kPeriod {
+		return i.k
+	}
+
+	hh, ll := i.highest.value(), i.lowest.value()
+
+	if hh == ll {
+		i.k = 50
+	} else {
+		i.k = 100 * (close - ll) / (hh - ll)
+	}
+
+	i.d.Update(i.k)
+
+	return i.k
+}
+
+// UpdateTick adds the tick price of the indicator source as a period without range.
+func (i *Stochastic) UpdateTick(tick *gotrader.Tick) {
+	price := TickPrice(tick, i.source)
+	i.UpdateHLC(price, price, price)
+}
+
+// UpdateBar adds the bar prices of the indicator source.
+func (i *Stochastic) UpdateBar(bar *gotrader.Bar) {
+	prices := BarPrices(bar, i.source)
+	i.UpdateHLC(prices.High, prices.Low, prices.Close)
+}
+
+// K returns the %K line, between 0 and 100.
+func (i *Stochastic) K() float64 {
+	return i.k
+}
+
+// D returns the %D line, between 0 and 100.
+func (i *Stochastic) D() float64 {
+	return i.d.Value()
+}
+
+// Ready returns true when the %D line is defined.
+func (i *Stochastic) Ready() bool {
+	return i.d.Ready()
+}
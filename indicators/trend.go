@@ -0,0 +1,115 @@
+package indicators
+
+import (
+	"math"
+
+	"github.com/luismcruz/gotrader"
+)
+
+// ADX is the Wilder average directional index, with the +DI and -DI lines.
+type ADX struct {
+	source    Source
+	plusDM    *wilder
+	minusDM   *wilder
+	trueRange *wilder
+	adx       *wilder
+	prevHigh  float64
+	prevLow   float64
+	prevClose float64
+	hasPrev   bool
+	plusDI    float64
+	minusDI   float64
+}
+
+// NewADX is the ADX constructor, usually used with period 14.
+func NewADX(period int, source Source) *ADX {
+
+	if period < 1 {
+		period = 1
+	}
+
+	return &ADX{
+		source:    source,
+		plusDM:    &wilder{period: period},
+		minusDM:   &wilder{period: period},
+		trueRange: &wilder{period: period},
+		adx:       &wilder{period: period},
+	}
+}
+
+// UpdateHLC adds the high, low and close of a period and returns the current index.
+func (i *ADX) UpdateHLC(high, low, close float64) float64 {
+
+	if !i.hasPrev {
+		i.prevHigh, i.prevLow, i.prevClose = high, low, close
+		i.hasPrev = true
+		return 0
+	}
+
+	upMove := high - i.prevHigh
+	downMove := i.prevLow - low
+
+	plusDM, minusDM := 0.0, 0.0
+	if upMove > downMove && upMove > 0 {
+		plusDM = upMove
+	}
+	if downMove > upMove && downMove > 0 {
+		minusDM = downMove
+	}
+
+	trueRange := math.Max(high-low, math.Max(math.Abs(high-i.prevClose), math.Abs(low-i.prevClose)))
+
+	i.prevHigh, i.prevLow, i.prevClose = high, low, close
+
+	smoothedPlus := i.plusDM.update(plusDM)
+	smoothedMinus := i.minusDM.update(minusDM)
+	smoothedRange := i.trueRange.update(trueRange)
+
+	if !i.trueRange.ready() {
+		return 0
+	}
+
+	if smoothedRange != 0 {
+		i.plusDI = 100 * smoothedPlus / smoothedRange
+		i.minusDI = 100 * smoothedMinus / smoothedRange
+	}
+
+	dx := 0.0
+	if sum := i.plusDI + i.minusDI; sum != 0 {
+		dx = 100 * math.Abs(i.plusDI-i.minusDI) / sum
+	}
+
+	return i.adx.update(dx)
+}
+
+// UpdateTick adds the tick price of the indicator source.
+func (i *ADX) UpdateTick(tick *gotrader.Tick) {
+	price := TickPrice(tick, i.source)
+	i.UpdateHLC(price, price, price)
+}
+
+// UpdateBar adds the bar prices of the indicator source.
+func (i *ADX) UpdateBar(bar *gotrader.Bar) {
+	prices := BarPrices(bar, i.source)
+	i.UpdateHLC(prices.High, prices.Low, prices.Close)
+}
+
+// Value returns the current index, between 0 and 100.
+func (i *ADX) Value() float64 {
+	return i.adx.value
+}
+
+// PlusDI returns the positive directional indicator.
+func (i *ADX) PlusDI() float64 {
+	return i.plusDI
+}
+
+// MinusDI returns the negative directional indicator.
+func (i *ADX) MinusDI() float64 {
+	return i.minusDI
+}
+
+// Ready returns true when the index is defined, after 2*period bars.
+func (i *ADX) Ready() bool {
+	return i.adx.ready()
+}
@@ -0,0 +1,239 @@
+package indicators
+
+import (
+	"math"
+
+	"github.com/luismcruz/gotrader"
+)
+
+// BollingerBands are the simple moving average of the price, plus and minus k
+// (population) standard deviations.
+type BollingerBands struct {
+	source Source
+	k      float64
+	window *window
+}
+
+// NewBollingerBands is the BollingerBands constructor, usually used with period 20 and k 2.
+func NewBollingerBands(period int, k float64, source Source) *BollingerBands {
+	return &BollingerBands{
+		source: source,
+		k:      k,
+		window: newWindow(period),
+	}
+}
+
+// Update adds a price and returns the middle band.
+func (i *BollingerBands) Update(price float64) float64 {
+	i.window.push(price)
+	return i.Middle()
+}
+
+// UpdateTick adds the tick price of the indicator source.
+func (i *BollingerBands) UpdateTick(tick *gotrader.Tick) {
+	i.Update(TickPrice(tick, i.source))
+}
+
+// UpdateBar adds the bar close of the indicator source.
+func (i *BollingerBands) UpdateBar(bar *gotrader.Bar) {
+	i.Update(BarPrices(bar, i.source).Close)
+}
+
+// Middle returns the moving average.
+func (i *BollingerBands) Middle() float64 {
+	return i.window.mean()
+}
+
+// Upper returns the upper band.
+func (i *BollingerBands) Upper() float64 {
+	return i.Middle() + i.k*i.StdDev()
+}
+
+// Lower returns the lower band.
+func (i *BollingerBands) Lower() float64 {
+	return i.Middle() - i.k*i.StdDev()
+}
+
+// StdDev returns the standard deviation of the prices in the window.
+func (i *BollingerBands) StdDev() float64 {
+	return math.Sqrt(i.window.variance())
+}
+
+// Ready returns true when period prices have been received.
+func (i *BollingerBands) Ready() bool {
+	return i.window.full()
+}
+
+// ATR is the Wilder average true range.
+type ATR struct {
+	source    Source
+	average   *wilder
+	prevClose float64
+	hasClose  bool
+}
+
+// NewATR is the ATR constructor.
+func NewATR(period int, source Source) *ATR {
+
+	if period < 1 {
+		period = 1
+	}
+
+	return &ATR{source: source, average: &wilder{period: period}}
+}
+
+// UpdateHLC adds the high, low and close of a period and returns the current average true range.
+func (i *ATR) UpdateHLC(high, low, close float64) float64 {
+
+	trueRange := high - low
+
+	if i.hasClose {
+		trueRange = math.Max(trueRange, math.Max(math.Abs(high-i.prevClose), math.Abs(low-i.prevClose)))
+	}
+
+	i.prevClose = close
+	i.hasClose = true
+
+	return i.average.update(trueRange)
+}
+
+// UpdateTick adds the tick price of the indicator source, the true range is then the absolute price change.
+func (i *ATR) UpdateTick(tick *gotrader.Tick) {
+	price := TickPrice(tick, i.source)
+	i.UpdateHLC(price, price, price)
+}
+
+// UpdateBar adds the bar prices of the indicator source.
+func (i *ATR) UpdateBar(bar *gotrader.Bar) {
+	prices := BarPrices(bar, i.source)
+	i.UpdateHLC(prices.High, prices.Low, prices.Close)
+}
+
+// Value returns the current average true range.
+func (i *ATR) Value() float64 {
+	return i.average.value
+}
+
+// Ready returns true when period true ranges have been received.
+func (i *ATR) Ready() bool {
+	return i.average.ready()
+}
+
+// KeltnerChannels are the EMA of the close, plus and minus a multiple of the ATR.
+type KeltnerChannels struct {
+	source     Source
+	multiplier float64
+	ema        *EMA
+	atr        *ATR
+}
+
+// NewKeltnerChannels is the KeltnerChannels constructor, usually used with periods 20 and 10 and multiplier 2.
+func NewKeltnerChannels(emaPeriod, atrPeriod int, multiplier float64, source Source) *KeltnerChannels {
+	return &KeltnerChannels{
+		source:     source,
+		multiplier: multiplier,
+		ema:        NewEMA(emaPeriod, source),
+		atr:        NewATR(atrPeriod, source),
+	}
+}
+
+// UpdateHLC adds the high, low and close of a period and returns the middle line.
+func (i *KeltnerChannels) UpdateHLC(high, low, close float64) float64 {
+	i.atr.UpdateHLC(high, low, close)
+	return i.ema.Update(close)
+}
+
+// UpdateTick adds the tick price of the indicator source.
+func (i *KeltnerChannels) UpdateTick(tick *gotrader.Tick) {
+	price := TickPrice(tick, i.source)
+	i.UpdateHLC(price, price, price)
+}
+
+// UpdateBar adds the bar prices of the indicator source.
+func (i *KeltnerChannels) UpdateBar(bar *gotrader.Bar) {
+	prices := BarPrices(bar, i.source)
+	i.UpdateHLC(prices.High, prices.Low, prices.Close)
+}
+
+// Middle returns the EMA line.
+func (i *KeltnerChannels) Middle() float64 {
+	return i.ema.Value()
+}
+
+// Upper returns the upper channel.
+func (i *KeltnerChannels) Upper() float64 {
+	return i.ema.Value() + i.multiplier*i.atr.Value()
+}
+
+// Lower returns the lower channel.
+func (i *KeltnerChannels) Lower() float64 {
+	return i.ema.Value() - i.multiplier*i.atr.Value()
+}
+
+// Ready returns true when both the EMA and the ATR are ready.
+func (i *KeltnerChannels) Ready() bool {
+	return i.ema.Ready() && i.atr.Ready()
+}
+
+// DonchianChannels are the highest high and lowest low of the last period bars.
+type DonchianChannels struct {
+	source  Source
+	period  int
+	count   int
+	highest *extremum
+	lowest  *extremum
+}
+
+// NewDonchianChannels is the DonchianChannels constructor.
+func NewDonchianChannels(period int, source Source) *DonchianChannels {
+
+	if period < 1 {
+		period = 1
+	}
+
+	return &DonchianChannels{
+		source:  source,
+		period:  period,
+		highest: newExtremum(period, true),
+		lowest:  newExtremum(period, false),
+	}
+}
+
+// UpdateHL adds the high and low of a period.
+func (i *DonchianChannels) UpdateHL(high, low float64) {
+	i.highest.push(high)
+	i.lowest.push(low)
+	i.count++
+}
+
+// UpdateTick adds the tick price of the indicator source.
+func (i *DonchianChannels) UpdateTick(tick *gotrader.Tick) {
+	price := TickPrice(tick, i.source)
+	i.UpdateHL(price, price)
+}
+
+// UpdateBar adds the bar prices of the indicator source.
+func (i *DonchianChannels) UpdateBar(bar *gotrader.Bar) {
+	prices := BarPrices(bar, i.source)
+	i.UpdateHL(prices.High, prices.Low)
+}
+
+// Upper returns the highest high.
+func (i *DonchianChannels) Upper() float64 {
+	return i.highest.value()
+}
+
+// Lower returns the lowest low.
+func (i *DonchianChannels) Lower() float64 {
+	return i.lowest.value()
+}
+
+// Middle returns the average between the upper and lower channels.
+func (i *DonchianChannels) Middle() float64 {
+	return (i.Upper() + i.Lower()) / 2
+}
+
+// Ready returns true when period bars have been received.
+func (i *DonchianChannels) Ready() bool {
+	return i.count >= i.period
+}
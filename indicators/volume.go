@@ -0,0 +1,62 @@
+package indicators
+
+import (
+	"github.com/luismcruz/gotrader"
+)
+
+// VWAP is the volume weighted average price since the last reset.
+// Ticks count as one unit of volume and bars use their tick volume with the typical price.
+type VWAP struct {
+	source      Source
+	priceVolume float64
+	volume      float64
+}
+
+// NewVWAP is the VWAP constructor.
+func NewVWAP(source Source) *VWAP {
+	return &VWAP{source: source}
+}
+
+// Update adds a price with its volume and returns the current average.
+func (i *VWAP) Update(price, volume float64) float64 {
+	i.priceVolume += price * volume
+	i.volume += volume
+	return i.Value()
+}
+
+// UpdateTick adds the tick price of the indicator source with volume 1.
+func (i *VWAP) UpdateTick(tick *gotrader.Tick) {
+	i.Update(TickPrice(tick, i.source), 1)
+}
+
+// UpdateBar adds the bar typical price (high + low + close) / 3 of the indicator source, weighted by the bar tick volume.
+func (i *VWAP) UpdateBar(bar *gotrader.Bar) {
+	prices := BarPrices(bar, i.source)
+	i.Update((prices.High+prices.Low+prices.Close)/3, float64(bar.Volume))
+}
+
+// Reset starts a new averaging period, usually at the start of a session.
+func (i *VWAP) Reset() {
+	i.priceVolume = 0
+	i.volume = 0
+}
+
+// Value returns the current average.
+func (i *VWAP) Value() float64 {
+
+	if i.volume == 0 {
+		return 0
+	}
+
+	return i.priceVolume / i.volume
+}
+
+// Volume returns the volume accumulated since the last reset.
+func (i *VWAP) Volume() float64 {
+	return i.volume
+}
+
+// Ready returns true when some volume has been received.
+func (i *VWAP) Ready() bool {
+	return i.volume > 0
+}
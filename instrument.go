@@ -8,13 +8,16 @@ import (
 	"go.uber.org/atomic"
 )
 
+// StandardLot is the number of units of a standard lot.
+const StandardLot = 100000
+
 // Hedge represents the type of hedging defined by the broker.
 type Hedge int
 
 const (
-	FullHedge Hedge = iota
-	NoHedge
-	HalfHedge
+	FullHedge Hedge = iota // both sides can be open, the margin is calculated on the net units
+	NoHedge                // positions are netted, an order against the position closes or reduces its trades
+	HalfHedge              // both sides can be open, the margin is calculated on the larger side
 )
 
 type Instrument struct {
@@ -33,7 +36,12 @@ type Instrument struct {
 	chargedFees               float64
 	ask                       *atomic.Float64
 	bid                       *atomic.Float64
+	lastTick                  *Tick
 	pipLocation               int
+	displayPrecision          int
+	minimumTradeSize          float64
+	tradeUnitsPrecision       int
+	maximumOrderUnits         float64
 	ccyConversion             *instrumentConversion
 	hedgeType                 Hedge
 	logger                    Logger
@@ -55,21 +63,34 @@ func newInstrument(
 ) *Instrument {
 
 	return &Instrument{
-		name:            name,
-		baseCurrency:    baseCurrency,
-		quoteCurrency:   quoteCurrency,
-		leverage:        atomic.NewFloat64(leverage),
-		pipLocation:     pipLocation,
-		longPosition:    newPosition(Long),
-		shortPosition:   newPosition(Short),
-		tradesNumber:    atomic.NewInt32(0),
-		trades:          &hashmap.HashMap{},
-		tradesTimeOrder: newSortedTrades(),
-		ask:             atomic.NewFloat64(0.0),
-		bid:             atomic.NewFloat64(0.0),
+		name:             name,
+		baseCurrency:     baseCurrency,
+		quoteCurrency:    quoteCurrency,
+		leverage:         atomic.NewFloat64(leverage),
+		pipLocation:      pipLocation,
+		displayPrecision: 1 - pipLocation,
+		longPosition:     newPosition(Long),
+		shortPosition:    newPosition(Short),
+		tradesNumber:     atomic.NewInt32(0),
+		trades:           &hashmap.HashMap{},
+		tradesTimeOrder:  newSortedTrades(),
+		ask:              atomic.NewFloat64(0.0),
+		bid:              atomic.NewFloat64(0.0),
 	}
 }
 
+// setDetails sets the price and units precision and limits defined by the broker.
+func (i *Instrument) setDetails(details InstrumentDetails) {
+
+	if details.DisplayPrecision > 0 {
+		i.displayPrecision = details.DisplayPrecision
+	}
+
+	i.minimumTradeSize = details.MinimumTradeSize
+	i.tradeUnitsPrecision = details.TradeUnitsPrecision
+	i.maximumOrderUnits = details.MaximumOrderUnits
+}
+
 func (i *Instrument) openTrade(
 	id string,
 	side Side,
@@ -118,6 +139,18 @@ func (i *Instrument) closeTrade(id string) {
 
 }
 
+// reduceTrade removes units of a partially closed trade.
+func (i *Instrument) reduceTrade(id string, units int32) {
+
+	trade := i.Trade(id)
+	if trade == nil {
+		i.logger.Warn(i.name + ": trying to reduce unexisting trade")
+		return
+	}
+
+	i.position(trade.side).reduceTrade(trade, units)
+}
+
 func (i *Instrument) calculateUnrealized() {
 
 	i.shortPosition.calculateUnrealized()
@@ -148,9 +181,36 @@ func (i *Instrument) calculateMarginUsed() {
 	}
 }
 
+// reduces returns true if an order of the given side and units only reduces the net position of the instrument.
+// With hedging the order opens a new trade, so it never reduces the position.
+func (i *Instrument) reduces(side Side, units int32) bool {
+
+	if i.hedgeType != NoHedge {
+		return false
+	}
+
+	net := i.longPosition.Units() - i.shortPosition.Units()
+
+	if side == Long {
+		return net < 0 && units <= -net
+	}
+
+	return net > 0 && units <= net
+}
+
+func (i *Instrument) position(side Side) *Position {
+
+	if side == Long {
+		return i.longPosition
+	}
+
+	return i.shortPosition
+}
+
 func (i *Instrument) updatePrice(tick *Tick) {
 	i.ask.Store(tick.Ask)
 	i.bid.Store(tick.Bid)
+	i.lastTick = tick
 }
 
 /**************************
@@ -265,6 +325,21 @@ func (i *Instrument) Bid() float64 {
 	return i.bid.Load()
 }
 
+// LastTick returns the last tick received, including the order book depth if provided by the broker.
+func (i *Instrument) LastTick() *Tick {
+	return i.lastTick
+}
+
+// FillPrice estimates the average fill price of a market order with the depth of the last tick.
+func (i *Instrument) FillPrice(side Side, units int32) (price float64, filled int32) {
+
+	if i.lastTick == nil {
+		return 0, 0
+	}
+
+	return i.lastTick.FillPrice(side, units)
+}
+
 func (i *Instrument) Spread() float64 {
 	return i.Bid() - i.Ask()
 }
@@ -276,3 +351,93 @@ func (i *Instrument) Leverage() float64 {
 func (i *Instrument) PipLocation() int {
 	return i.pipLocation
 }
+
+// PipSize returns the price change of one pip.
+func (i *Instrument) PipSize() float64 {
+	return math.Pow10(i.pipLocation)
+}
+
+// PriceToPips converts a price distance to pips.
+func (i *Instrument) PriceToPips(distance float64) float64 {
+	return distance / i.PipSize()
+}
+
+// PipsToPrice converts pips to a price distance.
+func (i *Instrument) PipsToPrice(pips float64) float64 {
+	return pips * i.PipSize()
+}
+
+// DisplayPrecision returns the number of decimal places of the prices.
+func (i *Instrument) DisplayPrecision() int {
+	return i.displayPrecision
+}
+
+// RoundPrice rounds a price to the display precision, as accepted by the broker.
+func (i *Instrument) RoundPrice(price float64) float64 {
+	scale := math.Pow10(i.displayPrecision)
+	return math.Round(price*scale) / scale
+}
+
+// PipValue returns the value in the home currency of one pip for the given units,
+// with the current quote currency conversion rate.
+func (i *Instrument) PipValue(units int32) float64 {
+
+	if i.ccyConversion == nil {
+		return 0
+	}
+
+	return float64(units) * i.PipSize() * i.ccyConversion.QuoteConversionRate.Load()
+}
+
+// MinimumTradeSize returns the minimum units of a trade, zero if there is no minimum.
+func (i *Instrument) MinimumTradeSize() float64 {
+	return i.minimumTradeSize
+}
+
+// MaximumOrderUnits returns the maximum units of an order, zero if there is no maximum.
+func (i *Instrument) MaximumOrderUnits() float64 {
+	return i.maximumOrderUnits
+}
+
+// NormalizeUnits truncates the units to the broker's units precision and maximum order units.
+// Zero is returned if the units are below the minimum trade size.
+func (i *Instrument) NormalizeUnits(units float64) int32 {
+
+	scale := math.Pow10(i.tradeUnitsPrecision)
+	units = math.Trunc(units*scale) / scale // units are integers, so only non positive precisions have effect
+
+	if i.maximumOrderUnits > 0 && units > i.maximumOrderUnits {
+		units = i.maximumOrderUnits
+	}
+
+	if units > math.MaxInt32 {
+		units = math.MaxInt32
+	}
+
+	if units < 1 || units < i.minimumTradeSize {
+		return 0
+	}
+
+	return int32(units)
+}
+
+// LotsToUnits converts standard lots to normalized units (see NormalizeUnits).
+func (i *Instrument) LotsToUnits(lots float64) int32 {
+	return i.NormalizeUnits(lots * StandardLot)
+}
+
+// UnitsToLots converts units to standard lots.
+func (i *Instrument) UnitsToLots(units int32) float64 {
+	return float64(units) / StandardLot
+}
+
+// MarginRequired returns the margin in the home currency used by the given units,
+// with the current base currency conversion rate.
+func (i *Instrument) MarginRequired(units int32) float64 {
+
+	if i.ccyConversion == nil {
+		return 0
+	}
+
+	return marginRequired(float64(units), i.leverage.Load(), i.ccyConversion)
+}
@@ -0,0 +1,92 @@
+package gotrader
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestInstrumentPipsAndUnits(t *testing.T) {
+
+	inst := newInstrument("EUR_GBP", "EUR", "GBP", 20, -4, nil)
+	inst.setDetails(InstrumentDetails{MinimumTradeSize: 1000, MaximumOrderUnits: 100000000})
+	inst.ccyConversion = newInstrumentConversion("EUR_GBP", "EUR", "GBP")
+	inst.ccyConversion.QuoteConversionRate.Store(1.25) // GBP_USD for a USD account
+
+	if pips := inst.PriceToPips(0.00125); math.Abs(pips-12.5) > 1e-9 {
+		t.Errorf("price to pips: %v", pips)
+	}
+
+	if price := inst.RoundPrice(0.912345678); price != 0.91235 {
+		t.Errorf("rounded price: %v", price)
+	}
+
+	if value := inst.PipValue(10000); math.Abs(value-1.25) > 1e-9 {
+		t.Errorf("pip value: %v", value)
+	}
+
+	if units := inst.LotsToUnits(0.0123456); units != 1234 {
+		t.Errorf("lots to units: %v", units)
+	}
+
+	if units := inst.NormalizeUnits(999.9); units != 0 {
+		t.Errorf("units below the minimum trade size: %v", units)
+	}
+}
+
+func TestInstrumentReduces(t *testing.T) {
+
+	inst := newInstrument("EUR_USD", "EUR", "USD", 20, -4, nil)
+	inst.ccyConversion = newInstrumentConversion("EUR_USD", "EUR", "USD")
+	inst.hedgeType = NoHedge
+	inst.openTrade("1", Long, time.Now(), 1000, 1.1)
+	inst.openTrade("2", Short, time.Now(), 400, 1.1)
+
+	tests := []struct {
+		side    Side
+		units   int32
+		reduces bool
+	}{
+		{Short, 600, true},
+		{Short, 100, true},
+		{Short, 601, false}, // reverses the position
+		{Long, 100, false},
+	}
+
+	for _, test := range tests {
+		if reduces := inst.reduces(test.side, test.units); reduces != test.reduces {
+			t.Errorf("%v %d units: reduces %v, expected %v", test.side, test.units, reduces, test.reduces)
+		}
+	}
+
+	inst.hedgeType = HalfHedge // the order opens a trade on the other side
+
+	if inst.reduces(Short, 100) {
+		t.Errorf("order reduces the position with hedging")
+	}
+}
+
+func TestAccountReducesPartiallyClosedTrades(t *testing.T) {
+
+	account := newAccount("test")
+	account.balance.Store(1000)
+
+	inst := newInstrument("EUR_USD", "EUR", "USD", 20, -4, nil)
+	inst.ccyConversion = newInstrumentConversion("EUR_USD", "EUR", "USD")
+	account.instruments["EUR_USD"] = inst
+
+	inst.openTrade("1", Long, time.Now(), 1000, 1.1)
+
+	details := InstrumentDetails{Name: "EUR_USD"}
+	account.applyOrderFill(&OrderFill{TradeClose: true, TradeID: "1", Instrument: details, Side: Long, Units: 400, Profit: 4})
+
+	if trade := inst.Trade("1"); trade == nil || trade.Units() != 600 || inst.longPosition.Units() != 600 || account.Balance() != 1004 {
+		t.Fatalf("trade not reduced")
+	}
+
+	account.applyOrderFill(&OrderFill{TradeClose: true, TradeID: "1", Instrument: details, Side: Long, Units: 600, Profit: 6})
+
+	if inst.Trade("1") != nil || inst.longPosition.Units() != 0 || account.Balance() != 1010 {
+		t.Errorf("trade not closed")
+	}
+}
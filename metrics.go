@@ -0,0 +1,421 @@
+package gotrader
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// orderLatencyBounds are the upper bounds, in seconds, of the order latency buckets
+var orderLatencyBounds = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Rejection reasons of the orders rejected without a rule
+const (
+	notEnoughMarginReason = "NOT_ENOUGH_MARGIN"
+	brokerErrorReason     = "BROKER_ERROR"
+)
+
+// ClientMetrics are the counters of the requests and streams of a broker client (see MetricsClient).
+type ClientMetrics struct {
+	Requests         map[int]int64 // requests by response status code, 0 for requests without response
+	RequestLatency   Histogram
+	StreamReconnects map[string]int64 // reconnections by stream
+}
+
+// Histogram counts observations by bucket.
+type Histogram struct {
+	Bounds []float64 // upper bounds of the buckets
+	Counts []int64   // observations by bucket, the last one without upper bound
+	Sum    float64
+}
+
+type histogram struct {
+	bounds []float64
+	counts []int64
+	sum    float64
+	mutex  sync.Mutex
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]int64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(value float64) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	bucket := 0
+	for bucket < len(h.bounds) && value > h.bounds[bucket] {
+		bucket++
+	}
+
+	h.counts[bucket]++
+	h.sum += value
+}
+
+func (h *histogram) snapshot() Histogram {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return Histogram{Bounds: h.bounds, Counts: append([]int64{}, h.counts...), Sum: h.sum}
+}
+
+// engineMetrics are the counters of the live engine, updated by the client and engine goroutines.
+type engineMetrics struct {
+	ticksReceived *atomic.Int64
+	ticksDropped  *atomic.Int64 // replaced by newer ticks when the ticks channel is full
+	orderLatency  *histogram    // from the order until it is filled
+	fills         *fillLatency
+	rejections    map[string]int64
+	mutex         sync.Mutex
+}
+
+func newEngineMetrics() *engineMetrics {
+	return &engineMetrics{
+		ticksReceived: atomic.NewInt64(0),
+		ticksDropped:  atomic.NewInt64(0),
+		orderLatency:  newHistogram(orderLatencyBounds),
+		fills:         newFillLatency(),
+		rejections:    make(map[string]int64),
+	}
+}
+
+func (m *engineMetrics) rejected(reason string) {
+	m.mutex.Lock()
+	m.rejections[reason]++
+	m.mutex.Unlock()
+}
+
+func (m *engineMetrics) rejectionCounts() map[string]int64 {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	counts := make(map[string]int64, len(m.rejections))
+	for reason, count := range m.rejections {
+		counts[reason] = count
+	}
+
+	return counts
+}
+
+// Time after which a sent order is no longer matched with a fill, its fill was lost
+const maxFillWait = time.Minute
+
+// fillLatency matches the fills with the orders sent to the broker, by instrument in the order they were sent,
+// to measure the time from the order until it is filled. The fills of the same order (the trades it closed and
+// opened) are matched once.
+type fillLatency struct {
+	sent      map[string][]*OrderRequest
+	lastOrder map[string]string // ID of the last order matched, by instrument
+	mutex     sync.Mutex
+}
+
+func newFillLatency() *fillLatency {
+	return &fillLatency{sent: make(map[string][]*OrderRequest), lastOrder: make(map[string]string)}
+}
+
+func (f *fillLatency) add(order *OrderRequest) {
+	f.mutex.Lock()
+	f.sent[order.Instrument] = append(f.sent[order.Instrument], order)
+	f.mutex.Unlock()
+}
+
+// remove forgets an order whose request failed, so it won't be filled.
+func (f *fillLatency) remove(order *OrderRequest) {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	orders := f.sent[order.Instrument]
+	for i := range orders {
+		if orders[i] == order {
+			f.sent[order.Instrument] = append(orders[:i], orders[i+1:]...)
+			return
+		}
+	}
+}
+
+// match returns the time from the order of the fill until now, false if the fill has no sent order or it was
+// rejected by the broker (the order is forgotten).
+func (f *fillLatency) match(orderFill *OrderFill, now time.Time) (time.Duration, bool) {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	name := orderFill.Instrument.Name
+
+	if orderFill.OrderID != "" && orderFill.OrderID == f.lastOrder[name] {
+		return 0, false
+	}
+
+	orders := f.sent[name]
+	for len(orders) > 0 && now.Sub(orders[0].Time) > maxFillWait {
+		orders = orders[1:]
+	}
+
+	if len(orders) == 0 {
+		f.sent[name] = orders
+		return 0, false
+	}
+
+	order := orders[0]
+	f.sent[name] = orders[1:]
+	f.lastOrder[name] = orderFill.OrderID
+
+	if orderFill.Error != "" {
+		return 0, false
+	}
+
+	return now.Sub(order.Time), true
+}
+
+// accountMetrics are the gauges of the account, taken in the event loop.
+type accountMetrics struct {
+	balance     float64
+	equity      float64
+	marginUsed  float64
+	marginFree  float64
+	instruments []instrumentMetrics
+}
+
+type instrumentMetrics struct {
+	name       string
+	trades     int32
+	unrealized float64
+	marginUsed float64
+}
+
+// serveMetrics writes the metrics of the session in the Prometheus text format. The account gauges are only
+// written while the event loop is running.
+func (e *liveEngine) serveMetrics(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	m := &metricsWriter{w: bufio.NewWriter(w)}
+	defer m.w.Flush()
+
+	m.header("gotrader_ticks_received_total", "Ticks received from the broker.", "counter")
+	m.sample("gotrader_ticks_received_total", nil, float64(e.metrics.ticksReceived.Load()))
+
+	m.header("gotrader_ticks_dropped_total", "Ticks replaced by newer ones because the ticks channel was full.", "counter")
+	m.sample("gotrader_ticks_dropped_total", nil, float64(e.metrics.ticksDropped.Load()))
+
+	m.header("gotrader_channel_depth", "Events waiting in the engine channels.", "gauge")
+	m.sample("gotrader_channel_depth", []string{"channel", "ticks"}, float64(len(e.ticks)))
+	m.sample("gotrader_channel_depth", []string{"channel", "orders"}, float64(len(e.orders)))
+	m.sample("gotrader_channel_depth", []string{"channel", "swap_charges"}, float64(len(e.swapCharges)))
+	m.sample("gotrader_channel_depth", []string{"channel", "funds_transfers"}, float64(len(e.fundsTransfers)))
+
+	m.histogram("gotrader_order_latency_seconds", "Time from the order until the broker notifies its fill.",
+		e.metrics.orderLatency.snapshot())
+
+	m.header("gotrader_order_rejections_total", "Orders rejected by the engine rules or the broker, by reason.", "counter")
+	for _, reason := range sortedKeys(e.metrics.rejectionCounts()) {
+		m.sample("gotrader_order_rejections_total", []string{"reason", reason.name}, float64(reason.value))
+	}
+
+	select {
+	case <-e.running:
+	default: // The session didn't start yet
+		return
+	}
+
+	m.header("gotrader_ticks_rejected_total", "Ticks dropped by the tick filter, by reason.", "counter")
+	rejected := e.tickFilter.rejected()
+	for _, reason := range tickRejections {
+		m.sample("gotrader_ticks_rejected_total", []string{"reason", reason.String()}, float64(rejected[reason]))
+	}
+
+	if e.reconciler != nil {
+		m.header("gotrader_reconciliations_total", "Reconciliations of the account with the broker, by result.", "counter")
+		m.sample("gotrader_reconciliations_total", []string{"result", "run"}, float64(e.reconciler.runs.Load()))
+		m.sample("gotrader_reconciliations_total", []string{"result", "failed"}, float64(e.reconciler.failures.Load()))
+		m.sample("gotrader_reconciliations_total", []string{"result", "inconsistent"}, float64(e.reconciler.inconsistencies.Load()))
+		m.sample("gotrader_reconciliations_total", []string{"result", "repaired"}, float64(e.reconciler.repairs.Load()))
+	}
+
+	if metricsClient, ok := e.client.(MetricsClient); ok {
+
+		metrics := metricsClient.Metrics()
+
+		m.header("gotrader_broker_requests_total", "Requests to the broker, by response status code (0 without response).", "counter")
+		codes := make([]int, 0, len(metrics.Requests))
+		for code := range metrics.Requests {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			m.sample("gotrader_broker_requests_total", []string{"code", strconv.Itoa(code)}, float64(metrics.Requests[code]))
+		}
+
+		m.histogram("gotrader_broker_request_duration_seconds", "Latency of the requests to the broker.", metrics.RequestLatency)
+
+		m.header("gotrader_stream_reconnects_total", "Reconnections of the broker streams, by stream.", "counter")
+		for _, stream := range sortedKeys(metrics.StreamReconnects) {
+			m.sample("gotrader_stream_reconnects_total", []string{"stream", stream.name}, float64(stream.value))
+		}
+	}
+
+	account, ok := e.accountMetrics()
+	if !ok { // The event loop stopped or didn't respond
+		return
+	}
+
+	m.header("gotrader_account_balance", "Balance of the account, in the home currency.", "gauge")
+	m.sample("gotrader_account_balance", nil, account.balance)
+
+	m.header("gotrader_account_equity", "Equity of the account, in the home currency.", "gauge")
+	m.sample("gotrader_account_equity", nil, account.equity)
+
+	m.header("gotrader_account_margin_used", "Margin used by the open trades, in the home currency.", "gauge")
+	m.sample("gotrader_account_margin_used", nil, account.marginUsed)
+
+	m.header("gotrader_account_margin_free", "Margin available for new trades, in the home currency.", "gauge")
+	m.sample("gotrader_account_margin_free", nil, account.marginFree)
+
+	m.header("gotrader_open_trades", "Open trades, by instrument.", "gauge")
+	for _, inst := range account.instruments {
+		m.sample("gotrader_open_trades", []string{"instrument", inst.name}, float64(inst.trades))
+	}
+
+	m.header("gotrader_unrealized_profit", "Unrealized net profit of the open trades, by instrument.", "gauge")
+	for _, inst := range account.instruments {
+		m.sample("gotrader_unrealized_profit", []string{"instrument", inst.name}, inst.unrealized)
+	}
+
+	m.header("gotrader_margin_used", "Margin used by the open trades, by instrument.", "gauge")
+	for _, inst := range account.instruments {
+		m.sample("gotrader_margin_used", []string{"instrument", inst.name}, inst.marginUsed)
+	}
+}
+
+// accountMetrics takes the account gauges in the event loop, returns false if it doesn't respond in time.
+func (e *liveEngine) accountMetrics() (*accountMetrics, bool) {
+
+	snapshot := make(chan *accountMetrics, 1)
+
+	e.scheduler.schedule(time.Now(), 0, func() {
+
+		account := &accountMetrics{
+			balance:    e.account.Balance(),
+			equity:     e.account.Equity(),
+			marginUsed: e.account.MarginUsed(),
+			marginFree: e.account.MarginFree(),
+		}
+
+		for name, inst := range e.account.instruments {
+			account.instruments = append(account.instruments, instrumentMetrics{
+				name:       name,
+				trades:     inst.TradesNumber(),
+				unrealized: inst.UnrealizedNetProfit(),
+				marginUsed: inst.MarginUsed(),
+			})
+		}
+
+		sort.Slice(account.instruments, func(i, j int) bool {
+			return account.instruments[i].name < account.instruments[j].name
+		})
+
+		snapshot <- account
+	})
+
+	timeout := time.NewTimer(statusTimeout)
+	defer timeout.Stop()
+
+	select {
+	case account := <-snapshot:
+		return account, true
+	case <-timeout.C:
+		return nil, false
+	case <-e.ctx.Done():
+		return nil, false
+	}
+}
+
+type namedCount struct {
+	name  string
+	value int64
+}
+
+func sortedKeys(counts map[string]int64) []namedCount {
+
+	sorted := make([]namedCount, 0, len(counts))
+	for name, value := range counts {
+		sorted = append(sorted, namedCount{name, value})
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+
+	return sorted
+}
+
+// metricsWriter writes metrics in the Prometheus text format.
+type metricsWriter struct {
+	w *bufio.Writer
+}
+
+func (m *metricsWriter) header(name, help, kind string) {
+	m.w.WriteString("# HELP " + name + " " + help + "\n")
+	m.w.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+// sample writes a value with the labels, given as name and value pairs.
+func (m *metricsWriter) sample(name string, labels []string, value float64) {
+
+	m.w.WriteString(name)
+
+	if len(labels) > 0 {
+
+		pairs := make([]string, 0, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, labels[i]+"=\""+labelReplacer.Replace(labels[i+1])+"\"")
+		}
+
+		m.w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+
+	m.w.WriteString(" " + formatMetric(value) + "\n")
+}
+
+func (m *metricsWriter) histogram(name, help string, h Histogram) {
+
+	m.header(name, help, "histogram")
+
+	var count int64
+
+	for i, bound := range h.Bounds {
+		count += h.Counts[i]
+		m.sample(name+"_bucket", []string{"le", formatMetric(bound)}, float64(count))
+	}
+
+	count += h.Counts[len(h.Bounds)]
+	m.sample(name+"_bucket", []string{"le", "+Inf"}, float64(count))
+	m.sample(name+"_sum", nil, h.Sum)
+	m.sample(name+"_count", nil, float64(count))
+}
+
+var labelReplacer = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+func formatMetric(value float64) string {
+
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
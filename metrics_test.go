@@ -0,0 +1,101 @@
+package gotrader
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestMetricsHandler(t *testing.T) {
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	strategy := &lifecycleStrategy{filled: make(chan struct{})}
+	session := NewTradingSession(Instruments([]string{eurUsd.Name}), AccountID("account"), SetLogger(logger)).
+		SetClient(newFakeLiveClient()).SetStrategy(strategy).Live()
+	handler := session.MetricsHandler()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+
+	go func() { result <- session.Run(ctx) }()
+
+	select {
+	case <-strategy.filled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the order was not filled")
+	}
+
+	// The fill is applied by the event loop, poll until the trade is reported
+	var metrics string
+
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(metrics, "gotrader_open_trades{instrument=\"EUR_USD\"} 1\n"); {
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the trade in the metrics, got\n%s", metrics)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		metrics = recorder.Body.String()
+	}
+
+	cancel()
+
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"# TYPE gotrader_ticks_received_total counter\n",
+		"gotrader_channel_depth{channel=\"orders\"} ",
+		"gotrader_order_latency_seconds_bucket{le=\"0.05\"} 1\n", // the fake client fills the orders when sent
+		"gotrader_order_latency_seconds_bucket{le=\"+Inf\"} 1\n",
+		"gotrader_order_latency_seconds_count 1\n",
+		"gotrader_account_balance 10000\n",
+	} {
+		if !strings.Contains(metrics, expected) {
+			t.Errorf("expected %q in the metrics, got\n%s", expected, metrics)
+		}
+	}
+}
+
+func TestOrderLatencyAndBrokerRejections(t *testing.T) {
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	e := newLiveEngine(logger)
+	e.account = newAccount("test")
+	e.strategy = &lifecycleStrategy{filled: make(chan struct{})}
+
+	start := time.Now()
+	first := &OrderRequest{Instrument: eurUsd.Name, Side: Long, Units: 1000, Time: start.Add(-2 * time.Second)}
+	second := &OrderRequest{Instrument: eurUsd.Name, Side: Long, Units: 1000, Time: start.Add(-time.Second)}
+	e.metrics.fills.add(first)
+	e.metrics.fills.add(second)
+
+	if latency, matched := e.metrics.fills.match(&OrderFill{OrderID: "10", Instrument: eurUsd}, start); !matched || latency != 2*time.Second {
+		t.Errorf("expected the fill to match the first order, got %v", latency)
+	}
+
+	if _, matched := e.metrics.fills.match(&OrderFill{OrderID: "10", Instrument: eurUsd}, start); matched {
+		t.Error("expected the second fill of the same order not to be matched")
+	}
+
+	// Rejected by the broker stream, the second order is no longer waiting for a fill
+	e.processOrderFill(&OrderFill{Error: "INSUFFICIENT_MARGIN", Instrument: eurUsd})
+
+	if count := e.metrics.rejectionCounts()["INSUFFICIENT_MARGIN"]; count != 1 {
+		t.Errorf("expected the broker rejection to be counted, got %d", count)
+	}
+
+	if _, matched := e.metrics.fills.match(&OrderFill{OrderID: "12", Instrument: eurUsd}, start); matched {
+		t.Error("expected the rejected order not to be matched")
+	}
+}
@@ -78,6 +78,18 @@ func (p *Position) closeTrade(trade *Trade) {
 	p.marginUsed -= trade.marginUsed
 }
 
+// reduceTrade removes units of a partially closed trade, which keeps its place in the open order.
+func (p *Position) reduceTrade(trade *Trade, units int32) {
+	p.averagePrice = (p.averagePrice*float64(p.units.Load()) - trade.openPrice*float64(units)) /
+		float64(p.units.Load()-units)
+	p.units.Sub(units)
+	trade.calculateMarginUsed()
+	p.marginUsed -= trade.marginUsed
+	trade.units -= units
+	trade.calculateMarginUsed()
+	p.marginUsed += trade.marginUsed
+}
+
 func (p *Position) calculateUnrealized() {
 
 	unrealizedNet := 0.0
@@ -0,0 +1,181 @@
+package gotrader
+
+import (
+	"math"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// Balance differences below the tolerance are ignored
+const balanceTolerance = 0.01
+
+// Reconciliation reports the differences between the account state of the engine and of the broker.
+// Balance and margin differences are the broker value minus the engine value.
+type Reconciliation struct {
+	Time              time.Time
+	MissingTrades     []TradeDetails // open in the broker but not in the engine
+	GhostTrades       []*Trade       // open in the engine but not in the broker
+	UnitsMismatches   []TradeDetails // broker state of the trades open with different units
+	BalanceDifference float64
+	MarginDifference  float64 // informative, the engine calculates the margin with its own rates
+	Repaired          bool
+}
+
+// Consistent returns true if the trades and the balance of the engine match the broker.
+func (r *Reconciliation) Consistent() bool {
+	return len(r.MissingTrades) == 0 &&
+		len(r.GhostTrades) == 0 &&
+		len(r.UnitsMismatches) == 0 &&
+		math.Abs(r.BalanceDifference) < balanceTolerance
+}
+
+// reconciler compares the account of the engine with the broker state. A difference is only reported
+// when it is found by two consecutive reconciliations, so orders and closes in flight are not reported.
+// Trades are repaired with order fills, applied as the fills received from the broker.
+type reconciler struct {
+	account  *Account
+	interval time.Duration
+	repair   bool
+	fill     func(orderFill *OrderFill)
+	suspects map[string]bool // differences found by the last reconciliation
+	logger   Logger
+
+	runs            *atomic.Int64
+	failures        *atomic.Int64
+	inconsistencies *atomic.Int64
+	repairs         *atomic.Int64
+}
+
+func newReconciler(
+	account *Account,
+	interval time.Duration,
+	repair bool,
+	fill func(orderFill *OrderFill),
+	logger Logger,
+) *reconciler {
+
+	return &reconciler{
+		account:         account,
+		interval:        interval,
+		repair:          repair,
+		fill:            fill,
+		suspects:        make(map[string]bool),
+		logger:          logger,
+		runs:            atomic.NewInt64(0),
+		failures:        atomic.NewInt64(0),
+		inconsistencies: atomic.NewInt64(0),
+		repairs:         atomic.NewInt64(0),
+	}
+}
+
+// reconcile compares the broker state with the account, repairing the confirmed differences if enabled.
+func (r *reconciler) reconcile(status AccountStatus, brokerTrades []TradeDetails, now time.Time) *Reconciliation {
+
+	r.runs.Inc()
+
+	report := &Reconciliation{Time: now}
+	suspects := make(map[string]bool)
+
+	confirmed := func(key string) bool {
+		suspects[key] = true
+		return r.suspects[key]
+	}
+
+	open := make(map[string]bool, len(brokerTrades))
+
+	for _, details := range brokerTrades {
+
+		inst, exist := r.account.instruments[details.Instrument.Name]
+		if !exist { // only the trading instruments are tracked
+			continue
+		}
+
+		open[details.ID] = true
+		trade := inst.Trade(details.ID)
+
+		switch {
+		case trade == nil && confirmed("missing:"+details.ID):
+			report.MissingTrades = append(report.MissingTrades, details)
+		case trade != nil && trade.units != details.Units && confirmed("units:"+details.ID):
+			report.UnitsMismatches = append(report.UnitsMismatches, details)
+		}
+	}
+
+	for _, inst := range r.account.instruments {
+		for trade := range inst.Trades() {
+			if !open[trade.id] && confirmed("ghost:"+trade.id) {
+				report.GhostTrades = append(report.GhostTrades, trade)
+			}
+		}
+	}
+
+	if difference := status.Balance - r.account.Balance(); math.Abs(difference) >= balanceTolerance && confirmed("balance") {
+		report.BalanceDifference = difference
+	}
+
+	report.MarginDifference = status.MarginUsed - r.account.MarginUsed()
+
+	r.suspects = suspects
+
+	if report.Consistent() {
+		return report
+	}
+
+	r.inconsistencies.Inc()
+
+	if r.repair {
+		r.apply(report, status)
+		r.repairs.Inc()
+	}
+
+	return report
+}
+
+// apply repairs the account with the broker state of the report.
+func (r *reconciler) apply(report *Reconciliation, status AccountStatus) {
+
+	for _, trade := range report.GhostTrades {
+		r.fill(&OrderFill{
+			TradeClose: true,
+			TradeID:    trade.id,
+			Side:       trade.side,
+			Instrument: InstrumentDetails{Name: trade.instrumentName},
+			Units:      trade.units,
+			Time:       report.Time,
+		})
+	}
+
+	for _, details := range report.UnitsMismatches {
+		r.fill(&OrderFill{
+			TradeClose: true,
+			TradeID:    details.ID,
+			Side:       details.Side,
+			Instrument: details.Instrument,
+			Time:       report.Time,
+		})
+	}
+
+	for _, details := range append(report.MissingTrades, report.UnitsMismatches...) {
+		r.fill(&OrderFill{
+			TradeID:     details.ID,
+			Side:        details.Side,
+			Instrument:  details.Instrument,
+			Price:       details.OpenPrice,
+			Units:       details.Units,
+			ChargedFees: details.ChargedFees,
+			Time:        details.OpenTime,
+			Tag:         details.Tag,
+		})
+	}
+
+	if math.Abs(report.BalanceDifference) >= balanceTolerance {
+		r.account.balance.Store(status.Balance)
+	}
+
+	r.account.calculateUnrealized()
+	r.account.calculateMarginUsed()
+	r.account.calculateFreeMargin()
+
+	report.Repaired = true
+}
@@ -0,0 +1,46 @@
+package gotrader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconciler(t *testing.T) {
+
+	account := newAccount("test")
+	account.balance.Store(1000)
+
+	inst := newInstrument("EUR_USD", "EUR", "USD", 20, -4, nil)
+	inst.ccyConversion = newInstrumentConversion("EUR_USD", "EUR", "USD")
+	account.instruments["EUR_USD"] = inst
+
+	inst.openTrade("1", Long, time.Time{}, 1000, 1.1) // ghost
+	inst.openTrade("2", Long, time.Time{}, 1000, 1.1) // partially closed in the broker
+
+	details := InstrumentDetails{Name: "EUR_USD"}
+	status := AccountStatus{Balance: 1010}
+	trades := []TradeDetails{
+		{ID: "2", Instrument: details, Side: Long, Units: 500, OpenPrice: 1.1},
+		{ID: "3", Instrument: details, Side: Short, Units: 2000, OpenPrice: 1.2}, // missing
+	}
+
+	reconciler := newReconciler(account, time.Minute, true, func(orderFill *OrderFill) { account.applyOrderFill(orderFill) }, nil)
+
+	if report := reconciler.reconcile(status, trades, time.Now()); !report.Consistent() {
+		t.Fatalf("differences reported before being confirmed: %+v", report)
+	}
+
+	report := reconciler.reconcile(status, trades, time.Now())
+
+	if len(report.GhostTrades) != 1 || len(report.MissingTrades) != 1 || len(report.UnitsMismatches) != 1 || report.BalanceDifference != 10 || !report.Repaired {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	if inst.Trade("1") != nil || inst.Trade("2").Units() != 500 || inst.Trade("3") == nil || account.Balance() != 1010 {
+		t.Errorf("account not repaired")
+	}
+
+	if report := reconciler.reconcile(status, trades, time.Now()); !report.Consistent() {
+		t.Errorf("differences after the repair: %+v", report)
+	}
+}
@@ -0,0 +1,668 @@
+package gotrader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The record files are a sequence of records, each one is a type byte, the payload length
+// as an uvarint and the payload. Floats are stored with their IEEE 754 bits and times as
+// nanoseconds since epoch, so a replay feeds exactly the same values that were received.
+const (
+	recordMagic   = "GTREC"
+	recordVersion = 1
+
+	recordFlushInterval = time.Second
+
+	tickRecord          byte = 1
+	orderFillRecord     byte = 2
+	swapChargeRecord    byte = 3
+	fundsTransferRecord byte = 4
+	instrumentsRecord   byte = 5
+)
+
+// Record represents one of the events stored by the Recorder, only one of the fields is defined.
+type Record struct {
+	Tick          *Tick
+	OrderFill     *OrderFill
+	SwapCharge    *SwapCharge
+	FundsTransfer *FundsTransfer
+	Instruments   []InstrumentDetails
+}
+
+/**************************
+*
+*	Recorder
+*
+***************************/
+
+// Recorder appends the data received from the broker to files named prefix.000000, prefix.000001, ...
+// A new file is started when the current one reaches the maximum size (no rotation if zero).
+// Existing files are never overwritten, a new recorder continues the sequence.
+// Records are buffered and flushed to the file every second and on Close.
+type Recorder struct {
+	mutex       sync.Mutex
+	prefix      string
+	maxFileSize int64
+	sequence    int
+	file        *os.File
+	writer      *bufio.Writer
+	size        int64
+	instruments []InstrumentDetails
+	buffer      []byte
+	done        chan struct{}
+}
+
+// NewRecorder is the Recorder constructor.
+func NewRecorder(prefix string, maxFileSize int64) (*Recorder, error) {
+
+	files, err := RecordFiles(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{
+		prefix:      prefix,
+		maxFileSize: maxFileSize,
+		sequence:    len(files),
+		done:        make(chan struct{}),
+	}
+
+	if err := r.rotate(); err != nil {
+		return nil, err
+	}
+
+	go r.flusher()
+
+	return r, nil
+}
+
+// RecordFiles returns the files written by a Recorder with the given prefix, in writing order.
+func RecordFiles(prefix string) ([]string, error) {
+
+	files, err := filepath.Glob(prefix + ".[0-9][0-9][0-9][0-9][0-9][0-9]")
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// RecordInstruments stores the instruments details, they are repeated at the start of every file
+// so each file can be replayed on its own.
+func (r *Recorder) RecordInstruments(instruments []InstrumentDetails) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.instruments = instruments
+
+	return r.writeInstruments()
+}
+
+// RecordTick appends a tick.
+func (r *Recorder) RecordTick(tick *Tick) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	b := r.buffer[:0]
+	b = appendString(b, tick.Instrument)
+	b = appendFloat(b, tick.Bid)
+	b = appendFloat(b, tick.Ask)
+	b = appendTime(b, tick.Time)
+	b = appendFloat(b, tick.CloseoutBid)
+	b = appendFloat(b, tick.CloseoutAsk)
+	b = appendLevels(b, tick.Bids)
+	b = appendLevels(b, tick.Asks)
+	r.buffer = b
+
+	return r.write(tickRecord, b)
+}
+
+// RecordOrderFill appends an order fill.
+func (r *Recorder) RecordOrderFill(order *OrderFill) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	b := r.buffer[:0]
+	b = appendString(b, order.Error)
+	b = appendBool(b, order.TradeClose)
+	b = appendString(b, order.OrderID)
+	b = appendString(b, order.TradeID)
+	b = appendVarint(b, int64(order.Side))
+	b = appendString(b, order.Instrument.Name)
+	b = appendFloat(b, order.Price)
+	b = appendVarint(b, int64(order.Units))
+	b = appendFloat(b, order.Profit)
+	b = appendFloat(b, order.ChargedFees)
+	b = appendTime(b, order.Time)
+	b = appendString(b, order.Tag)
+	b = appendBool(b, order.Rejection != nil)
+	if order.Rejection != nil {
+		b = appendString(b, order.Rejection.Rule)
+		b = appendString(b, order.Rejection.Reason)
+	}
+	r.buffer = b
+
+	return r.write(orderFillRecord, b)
+}
+
+// RecordSwapCharge appends a swap charge.
+func (r *Recorder) RecordSwapCharge(swap *SwapCharge) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	b := r.buffer[:0]
+	b = appendTime(b, swap.Time)
+	b = appendUvarint(b, uint64(len(swap.Charges)))
+	for _, charge := range swap.Charges {
+		b = appendString(b, charge.ID)
+		b = appendFloat(b, charge.Ammount)
+		b = appendString(b, charge.Instrument.Name)
+	}
+	r.buffer = b
+
+	return r.write(swapChargeRecord, b)
+}
+
+// RecordFundsTransfer appends a funds transfer.
+func (r *Recorder) RecordFundsTransfer(funds *FundsTransfer) error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	b := r.buffer[:0]
+	b = appendFloat(b, funds.Ammount)
+	b = appendTime(b, funds.Time)
+	r.buffer = b
+
+	return r.write(fundsTransferRecord, b)
+}
+
+// Close flushes and closes the current file.
+func (r *Recorder) Close() error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	close(r.done)
+
+	err := r.writer.Flush()
+
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	r.file = nil
+
+	return err
+}
+
+func (r *Recorder) write(recordType byte, payload []byte) error {
+
+	if r.file == nil {
+		return errors.New("recorder is closed")
+	}
+
+	if r.maxFileSize > 0 && r.size >= r.maxFileSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+
+	var header [1 + binary.MaxVarintLen64]byte
+	header[0] = recordType
+	n := 1 + binary.PutUvarint(header[1:], uint64(len(payload)))
+
+	if _, err := r.writer.Write(header[:n]); err != nil {
+		return err
+	}
+
+	if _, err := r.writer.Write(payload); err != nil {
+		return err
+	}
+
+	r.size += int64(n + len(payload))
+
+	return nil
+}
+
+// flusher periodically flushes the buffered records, so a crash loses at most the last interval.
+// A failed flush is kept by the writer and returned by the next record.
+func (r *Recorder) flusher() {
+
+	ticker := time.NewTicker(recordFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.mutex.Lock()
+			if r.file != nil {
+				r.writer.Flush()
+			}
+			r.mutex.Unlock()
+		}
+	}
+}
+
+func (r *Recorder) rotate() error {
+
+	if r.file != nil {
+		if err := r.writer.Flush(); err != nil {
+			return err
+		}
+		if err := r.file.Close(); err != nil {
+			return err
+		}
+	}
+
+	name := fmt.Sprintf("%s.%06d", r.prefix, r.sequence)
+	r.sequence++
+
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	r.file = file
+	r.writer = bufio.NewWriter(file)
+	r.size = 0
+
+	if _, err := r.writer.WriteString(recordMagic); err != nil {
+		return err
+	}
+
+	if err := r.writer.WriteByte(recordVersion); err != nil {
+		return err
+	}
+
+	r.size = int64(len(recordMagic) + 1)
+
+	if r.instruments != nil {
+		return r.writeInstruments()
+	}
+
+	return r.writer.Flush()
+}
+
+func (r *Recorder) writeInstruments() error {
+
+	payload, err := json.Marshal(r.instruments)
+	if err != nil {
+		return err
+	}
+
+	return r.write(instrumentsRecord, payload)
+}
+
+/**************************
+*
+*	Reader
+*
+***************************/
+
+// RecordReader reads in order the records of a sequence of files written by a Recorder.
+type RecordReader struct {
+	files       []string
+	file        *os.File
+	reader      *bufio.Reader
+	instruments map[string]InstrumentDetails
+	payload     []byte
+}
+
+// NewRecordReader is the RecordReader constructor.
+func NewRecordReader(files ...string) *RecordReader {
+	return &RecordReader{
+		files:       files,
+		instruments: make(map[string]InstrumentDetails),
+	}
+}
+
+// Next returns the next record, or io.EOF when all the files have been read.
+func (r *RecordReader) Next() (*Record, error) {
+
+	for {
+
+		if r.reader == nil {
+			if err := r.open(); err != nil {
+				return nil, err
+			}
+		}
+
+		recordType, err := r.reader.ReadByte()
+		if err == io.EOF {
+			r.file.Close()
+			r.file = nil
+			r.reader = nil
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		length, err := binary.ReadUvarint(r.reader)
+		if err != nil {
+			return nil, err
+		}
+
+		if uint64(cap(r.payload)) < length {
+			r.payload = make([]byte, length)
+		}
+		payload := r.payload[:length]
+
+		if _, err := io.ReadFull(r.reader, payload); err != nil {
+			return nil, err
+		}
+
+		record, err := r.decode(recordType, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if record != nil {
+			return record, nil
+		}
+	}
+}
+
+// Close closes the file being read.
+func (r *RecordReader) Close() error {
+
+	if r.file == nil {
+		return nil
+	}
+
+	return r.file.Close()
+}
+
+func (r *RecordReader) open() error {
+
+	if len(r.files) == 0 {
+		return io.EOF
+	}
+
+	file, err := os.Open(r.files[0])
+	if err != nil {
+		return err
+	}
+
+	r.files = r.files[1:]
+	r.file = file
+	r.reader = bufio.NewReader(file)
+
+	header := make([]byte, len(recordMagic)+1)
+	if _, err := io.ReadFull(r.reader, header); err != nil {
+		return err
+	}
+
+	if string(header[:len(recordMagic)]) != recordMagic || header[len(recordMagic)] != recordVersion {
+		return errors.New(file.Name() + " is not a supported record file")
+	}
+
+	return nil
+}
+
+func (r *RecordReader) decode(recordType byte, payload []byte) (*Record, error) {
+
+	d := &decoder{data: payload}
+
+	switch recordType {
+	case tickRecord:
+
+		tick := &Tick{
+			Instrument:  d.string(),
+			Bid:         d.float(),
+			Ask:         d.float(),
+			Time:        d.time(),
+			CloseoutBid: d.float(),
+			CloseoutAsk: d.float(),
+		}
+		tick.Bids = d.levels()
+		tick.Asks = d.levels()
+
+		return &Record{Tick: tick}, d.err
+
+	case orderFillRecord:
+
+		order := &OrderFill{
+			Error:      d.string(),
+			TradeClose: d.bool(),
+			OrderID:    d.string(),
+			TradeID:    d.string(),
+			Side:       Side(d.varint()),
+			Instrument: r.instrument(d.string()),
+		}
+		order.Price = d.float()
+		order.Units = int32(d.varint())
+		order.Profit = d.float()
+		order.ChargedFees = d.float()
+		order.Time = d.time()
+
+		if len(d.data) > 0 { // the tag and the rejection aren't stored in older files
+			order.Tag = d.string()
+			if d.bool() {
+				order.Rejection = &OrderRejection{Rule: d.string(), Reason: d.string()}
+			}
+		}
+
+		return &Record{OrderFill: order}, d.err
+
+	case swapChargeRecord:
+
+		swap := &SwapCharge{Time: d.time()}
+		swap.Charges = make([]*TradeSwapCharge, d.uvarint())
+
+		for i := range swap.Charges {
+			swap.Charges[i] = &TradeSwapCharge{
+				ID:         d.string(),
+				Ammount:    d.float(),
+				Instrument: r.instrument(d.string()),
+			}
+		}
+
+		return &Record{SwapCharge: swap}, d.err
+
+	case fundsTransferRecord:
+
+		return &Record{FundsTransfer: &FundsTransfer{Ammount: d.float(), Time: d.time()}}, d.err
+
+	case instrumentsRecord:
+
+		var instruments []InstrumentDetails
+		if err := json.Unmarshal(payload, &instruments); err != nil {
+			return nil, err
+		}
+
+		for _, inst := range instruments {
+			r.instruments[inst.Name] = inst
+		}
+
+		return &Record{Instruments: instruments}, nil
+	}
+
+	return nil, nil // unknown records are skipped
+}
+
+func (r *RecordReader) instrument(name string) InstrumentDetails {
+
+	if inst, exist := r.instruments[name]; exist {
+		return inst
+	}
+
+	return InstrumentDetails{Name: name}
+}
+
+/**************************
+*
+*	Encoding Helpers
+*
+***************************/
+
+func appendString(b []byte, s string) []byte {
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], v)]...)
+}
+
+func appendFloat(b []byte, f float64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+	return append(b, buf[:]...)
+}
+
+func appendTime(b []byte, t time.Time) []byte {
+	return appendVarint(b, t.UnixNano())
+}
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+func appendLevels(b []byte, levels []PriceLevel) []byte {
+
+	b = appendUvarint(b, uint64(len(levels)))
+
+	for _, level := range levels {
+		b = appendFloat(b, level.Price)
+		b = appendVarint(b, level.Liquidity)
+	}
+
+	return b
+}
+
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = errors.New("corrupted record")
+	}
+	d.data = nil
+}
+
+func (d *decoder) uvarint() uint64 {
+
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+
+	d.data = d.data[n:]
+
+	return v
+}
+
+func (d *decoder) varint() int64 {
+
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+
+	d.data = d.data[n:]
+
+	return v
+}
+
+func (d *decoder) string() string {
+
+	length := d.uvarint()
+	if uint64(len(d.data)) < length {
+		d.fail()
+		return ""
+	}
+
+	s := string(d.data[:length])
+	d.data = d.data[length:]
+
+	return s
+}
+
+func (d *decoder) float() float64 {
+
+	if len(d.data) < 8 {
+		d.fail()
+		return 0
+	}
+
+	f := math.Float64frombits(binary.LittleEndian.Uint64(d.data))
+	d.data = d.data[8:]
+
+	return f
+}
+
+func (d *decoder) time() time.Time {
+	return time.Unix(0, d.varint()).UTC()
+}
+
+func (d *decoder) bool() bool {
+
+	if len(d.data) < 1 {
+		d.fail()
+		return false
+	}
+
+	v := d.data[0] == 1
+	d.data = d.data[1:]
+
+	return v
+}
+
+func (d *decoder) levels() []PriceLevel {
+
+	length := d.uvarint()
+	if length == 0 || d.err != nil {
+		return nil
+	}
+
+	if length > uint64(len(d.data)) { // each level takes at least 9 bytes
+		d.fail()
+		return nil
+	}
+
+	levels := make([]PriceLevel, length)
+
+	for i := range levels {
+		levels[i].Price = d.float()
+		levels[i].Liquidity = d.varint()
+	}
+
+	return levels
+}
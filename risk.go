@@ -0,0 +1,417 @@
+package gotrader
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Rules of the orders rejected by the risk manager, used as OrderFill errors
+const (
+	MaxUnitsRule          = "MAX_UNITS_EXCEEDED"
+	MaxOpenTradesRule     = "MAX_OPEN_TRADES_EXCEEDED"
+	MaxExposureRule       = "MAX_EXPOSURE_EXCEEDED"
+	MaxLeverageRule       = "MAX_LEVERAGE_EXCEEDED"
+	MinOrderIntervalRule  = "MIN_ORDER_INTERVAL"
+	BlockedInstrumentRule = "INSTRUMENT_BLOCKED"
+)
+
+// OrderRequest represents an order evaluated by the risk manager before it is sent to the broker.
+type OrderRequest struct {
+	Instrument string
+	Side       Side
+	Units      int32
+	Time       time.Time // engine time, simulated in backtest
+}
+
+// OrderRejection represents an order rejected by a risk rule, the engine or a failed broker request.
+type OrderRejection struct {
+	Rule   string
+	Reason string
+}
+
+func (r *OrderRejection) Error() string {
+	return r.Rule + ": " + r.Reason
+}
+
+// RiskRule is a pre-trade check of the risk manager, it returns nil if the order is allowed.
+type RiskRule interface {
+	Check(account *Account, order *OrderRequest) *OrderRejection
+}
+
+// orderObserver is implemented by the stateful rules, which are notified of the orders accepted by all rules
+type orderObserver interface {
+	accepted(order *OrderRequest)
+}
+
+// statefulRule is implemented by the rules with state, which is built for each engine so the sessions don't share it
+type statefulRule interface {
+	newState() RiskRule
+}
+
+// pendingRule is implemented by the rules on the open units and trades, which also count the orders not filled yet
+type pendingRule interface {
+	checkPending(account *Account, order *OrderRequest, pending []*OrderRequest) *OrderRejection
+}
+
+// riskManager evaluates the rules, in the order they were defined, before the orders are sent.
+type riskManager struct {
+	rules []RiskRule
+}
+
+func newRiskManager(rules []RiskRule) *riskManager {
+
+	engineRules := make([]RiskRule, len(rules))
+
+	for i, rule := range rules {
+		if stateful, ok := rule.(statefulRule); ok {
+			rule = stateful.newState()
+		}
+		engineRules[i] = rule
+	}
+
+	return &riskManager{rules: engineRules}
+}
+
+// check evaluates the order, pending are the orders sent to the broker or waiting to be sent, which aren't filled yet.
+func (m *riskManager) check(account *Account, order *OrderRequest, pending []*OrderRequest) *OrderRejection {
+
+	for _, rule := range m.rules {
+
+		var rejection *OrderRejection
+
+		if pendingRule, ok := rule.(pendingRule); ok {
+			rejection = pendingRule.checkPending(account, order, pending)
+		} else {
+			rejection = rule.Check(account, order)
+		}
+
+		if rejection != nil {
+			return rejection
+		}
+	}
+
+	for _, rule := range m.rules {
+		if observer, ok := rule.(orderObserver); ok {
+			observer.accepted(order)
+		}
+	}
+
+	return nil
+}
+
+// projectOrders returns the long and short units of the instrument once the orders are filled, and the number of
+// orders opening a trade. Without hedging an order reduces the opposite side before opening a trade with the rest.
+func projectOrders(inst *Instrument, orders []*OrderRequest) (long, short int64, opened int32) {
+
+	long, short = int64(inst.longPosition.Units()), int64(inst.shortPosition.Units())
+
+	for _, order := range orders {
+
+		if order.Instrument != inst.name {
+			continue
+		}
+
+		units := int64(order.Units)
+		own, opposite := &long, &short
+		if order.Side == Short {
+			own, opposite = &short, &long
+		}
+
+		if inst.hedgeType == NoHedge {
+			reduced := units
+			if *opposite < reduced {
+				reduced = *opposite
+			}
+			*opposite -= reduced
+			units -= reduced
+		}
+
+		if units > 0 {
+			*own += units
+			opened++
+		}
+	}
+
+	return long, short, opened
+}
+
+// withOrder returns the pending orders followed by the order, pending is not modified.
+func withOrder(pending []*OrderRequest, order *OrderRequest) []*OrderRequest {
+	return append(pending[:len(pending):len(pending)], order)
+}
+
+/**************************
+*
+*	Rules
+*
+***************************/
+
+type maxUnits struct {
+	instrument string // all the instruments if empty
+	side       *Side  // the net units if nil
+	units      int64
+}
+
+// MaxInstrumentUnits limits the absolute net units open on an instrument.
+func MaxInstrumentUnits(instrument string, units int64) RiskRule {
+	return &maxUnits{instrument: instrument, units: units}
+}
+
+// MaxSideUnits limits the units open on a side of an instrument.
+func MaxSideUnits(instrument string, side Side, units int64) RiskRule {
+	return &maxUnits{instrument: instrument, side: &side, units: units}
+}
+
+// MaxAccountUnits limits the sum of the absolute net units open on all instruments.
+func MaxAccountUnits(units int64) RiskRule {
+	return &maxUnits{units: units}
+}
+
+func (r *maxUnits) Check(account *Account, order *OrderRequest) *OrderRejection {
+	return r.checkPending(account, order, nil)
+}
+
+// checkPending rejects the orders increasing the units above the limit, the orders reducing them are always allowed.
+func (r *maxUnits) checkPending(account *Account, order *OrderRequest, pending []*OrderRequest) *OrderRejection {
+
+	if r.instrument != "" && r.instrument != order.Instrument {
+		return nil
+	}
+
+	before, after := int64(0), int64(0)
+
+	for name, inst := range account.instruments {
+		if r.instrument == "" || r.instrument == name {
+			before += r.open(projectOrders(inst, pending))
+			after += r.open(projectOrders(inst, withOrder(pending, order)))
+		}
+	}
+
+	if after > r.units && after > before {
+		return &OrderRejection{
+			Rule:   MaxUnitsRule,
+			Reason: strconv.FormatInt(after, 10) + " units above the limit of " + strconv.FormatInt(r.units, 10),
+		}
+	}
+
+	return nil
+}
+
+func (r *maxUnits) open(long, short int64, opened int32) int64 {
+
+	if r.side == nil {
+		if long > short {
+			return long - short
+		}
+		return short - long
+	}
+
+	if *r.side == Long {
+		return long
+	}
+
+	return short
+}
+
+type maxOpenTrades struct {
+	trades int32
+}
+
+// MaxOpenTrades limits the number of trades open in the account.
+// Orders that only reduce the position of an account without hedging don't open a trade, so they are always allowed.
+func MaxOpenTrades(trades int32) RiskRule {
+	return &maxOpenTrades{trades: trades}
+}
+
+func (r *maxOpenTrades) Check(account *Account, order *OrderRequest) *OrderRejection {
+	return r.checkPending(account, order, nil)
+}
+
+func (r *maxOpenTrades) checkPending(account *Account, order *OrderRequest, pending []*OrderRequest) *OrderRejection {
+
+	open := int32(0)
+	opens := false
+
+	for name, inst := range account.instruments {
+
+		_, _, opened := projectOrders(inst, pending)
+		open += inst.TradesNumber() + opened
+
+		if name == order.Instrument {
+			_, _, openedAfter := projectOrders(inst, withOrder(pending, order))
+			opens = openedAfter > opened
+		}
+	}
+
+	if opens && open >= r.trades {
+		return &OrderRejection{
+			Rule:   MaxOpenTradesRule,
+			Reason: strconv.Itoa(int(open)) + " trades already open or pending",
+		}
+	}
+
+	return nil
+}
+
+type maxExposure struct {
+	amount float64
+	gross  bool
+}
+
+// MaxNetExposure limits the absolute net exposure to each currency other than the home currency,
+// in the home currency (see CurrencyExposure).
+func MaxNetExposure(amount float64) RiskRule {
+	return &maxExposure{amount: amount}
+}
+
+// MaxGrossExposure limits the gross exposure to each currency other than the home currency,
+// in the home currency (see CurrencyExposure).
+func MaxGrossExposure(amount float64) RiskRule {
+	return &maxExposure{amount: amount, gross: true}
+}
+
+func (r *maxExposure) Check(account *Account, order *OrderRequest) *OrderRejection {
+
+	inst := account.instruments[order.Instrument]
+	if inst == nil {
+		return nil
+	}
+
+	exposures := accountExposure(account)
+	base, quote := exposureLegs(inst, order.Side, order.Units)
+
+	legs := map[string]float64{inst.baseCurrency: base, inst.quoteCurrency: quote}
+
+	for currency, leg := range legs {
+
+		if currency == account.homeCurrency {
+			continue
+		}
+
+		exposure := CurrencyExposure{}
+		if current, exist := exposures[currency]; exist {
+			exposure = *current
+		}
+
+		exposure.add(leg)
+
+		value := math.Abs(exposure.Net)
+		if r.gross {
+			value = exposure.Gross
+		}
+
+		if value > r.amount {
+			return &OrderRejection{
+				Rule:   MaxExposureRule,
+				Reason: currency + " exposure of " + strconv.FormatFloat(value, 'f', 2, 64) + " above the limit",
+			}
+		}
+	}
+
+	return nil
+}
+
+type maxLeverage struct {
+	leverage float64
+}
+
+// MaxLeverage limits the ratio between the notional value of the net units open on each instrument, in the home
+// currency, and the equity. Orders reducing the notional value are always allowed.
+func MaxLeverage(leverage float64) RiskRule {
+	return &maxLeverage{leverage: leverage}
+}
+
+func (r *maxLeverage) Check(account *Account, order *OrderRequest) *OrderRejection {
+	return r.checkPending(account, order, nil)
+}
+
+func (r *maxLeverage) checkPending(account *Account, order *OrderRequest, pending []*OrderRequest) *OrderRejection {
+
+	before, after := 0.0, 0.0
+
+	for _, inst := range account.instruments {
+
+		if inst.ccyConversion == nil {
+			continue
+		}
+
+		rate := inst.ccyConversion.BaseConversionRate.Load()
+
+		long, short, _ := projectOrders(inst, pending)
+		before += math.Abs(float64(long-short)) * rate
+
+		long, short, _ = projectOrders(inst, withOrder(pending, order))
+		after += math.Abs(float64(long-short)) * rate
+	}
+
+	if after > before && (account.Equity() <= 0 || after/account.Equity() > r.leverage) {
+		return &OrderRejection{
+			Rule:   MaxLeverageRule,
+			Reason: "notional value of " + strconv.FormatFloat(after, 'f', 2, 64) + " above the leverage limit",
+		}
+	}
+
+	return nil
+}
+
+type minOrderInterval struct {
+	interval  time.Duration
+	lastOrder time.Time
+	mutex     sync.Mutex
+}
+
+// MinOrderInterval limits the time between the orders accepted by the risk manager.
+func MinOrderInterval(interval time.Duration) RiskRule {
+	return &minOrderInterval{interval: interval}
+}
+
+func (r *minOrderInterval) Check(account *Account, order *OrderRequest) *OrderRejection {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.lastOrder.IsZero() && order.Time.Sub(r.lastOrder) < r.interval {
+		return &OrderRejection{
+			Rule:   MinOrderIntervalRule,
+			Reason: "last order sent at " + r.lastOrder.String(),
+		}
+	}
+
+	return nil
+}
+
+func (r *minOrderInterval) newState() RiskRule {
+	return &minOrderInterval{interval: r.interval}
+}
+
+func (r *minOrderInterval) accepted(order *OrderRequest) {
+	r.mutex.Lock()
+	r.lastOrder = order.Time
+	r.mutex.Unlock()
+}
+
+type blockedInstruments struct {
+	instruments map[string]bool
+}
+
+// BlockInstruments rejects all the orders of the given instruments.
+func BlockInstruments(instruments ...string) RiskRule {
+
+	blocked := make(map[string]bool, len(instruments))
+	for _, inst := range instruments {
+		blocked[inst] = true
+	}
+
+	return &blockedInstruments{instruments: blocked}
+}
+
+func (r *blockedInstruments) Check(account *Account, order *OrderRequest) *OrderRejection {
+
+	if r.instruments[order.Instrument] {
+		return &OrderRejection{Rule: BlockedInstrumentRule, Reason: order.Instrument + " is blocked"}
+	}
+
+	return nil
+}
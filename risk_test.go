@@ -0,0 +1,102 @@
+package gotrader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRiskManager(t *testing.T) {
+
+	account := newAccount("test")
+	account.homeCurrency = "USD"
+
+	inst := newInstrument("EUR_USD", "EUR", "USD", 20, -4, nil)
+	inst.ccyConversion = newInstrumentConversion("EUR_USD", "EUR", "USD")
+	account.instruments["EUR_USD"] = inst
+	inst.openTrade("1", Long, time.Time{}, 1000, 1.1)
+
+	manager := newRiskManager([]RiskRule{
+		BlockInstruments("GBP_USD"),
+		MaxSideUnits("EUR_USD", Long, 1500),
+		MinOrderInterval(time.Minute),
+	})
+
+	start := time.Date(2020, 7, 6, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		order *OrderRequest
+		rule  string
+	}{
+		{&OrderRequest{Instrument: "GBP_USD", Side: Long, Units: 100, Time: start}, BlockedInstrumentRule},
+		{&OrderRequest{Instrument: "EUR_USD", Side: Long, Units: 600, Time: start}, MaxUnitsRule},
+		{&OrderRequest{Instrument: "EUR_USD", Side: Short, Units: 600, Time: start}, ""},
+		{&OrderRequest{Instrument: "EUR_USD", Side: Long, Units: 500, Time: start.Add(time.Second)}, MinOrderIntervalRule},
+		{&OrderRequest{Instrument: "EUR_USD", Side: Long, Units: 500, Time: start.Add(time.Minute)}, ""},
+	}
+
+	for i, test := range tests {
+
+		rule := ""
+		if rejection := manager.check(account, test.order, nil); rejection != nil {
+			rule = rejection.Rule
+		}
+
+		if rule != test.rule {
+			t.Errorf("order %d: rejected by %q, expected %q", i, rule, test.rule)
+		}
+	}
+}
+
+func TestRiskRulesCheckThePositionAfterTheOrder(t *testing.T) {
+
+	account := newAccount("test")
+	account.balance.Store(1000)
+
+	inst := newInstrument("EUR_USD", "EUR", "USD", 20, -4, nil)
+	inst.ccyConversion = newInstrumentConversion("EUR_USD", "EUR", "USD")
+	inst.ccyConversion.BaseConversionRate.Store(1)
+	inst.hedgeType = NoHedge
+	account.instruments["EUR_USD"] = inst
+	inst.openTrade("1", Long, time.Time{}, 1000, 1.1)
+
+	pending := []*OrderRequest{{Instrument: "EUR_USD", Side: Long, Units: 500}}
+
+	tests := []struct {
+		rule     RiskRule
+		order    *OrderRequest
+		rejected bool
+	}{
+		{MaxInstrumentUnits("EUR_USD", 1200), &OrderRequest{Instrument: "EUR_USD", Side: Short, Units: 300}, false},
+		{MaxInstrumentUnits("EUR_USD", 1600), &OrderRequest{Instrument: "EUR_USD", Side: Long, Units: 200}, true}, // with the pending order
+		{MaxOpenTrades(1), &OrderRequest{Instrument: "EUR_USD", Side: Short, Units: 1500}, false},                 // closes the position
+		{MaxOpenTrades(2), &OrderRequest{Instrument: "EUR_USD", Side: Long, Units: 100}, true},
+		{MaxLeverage(1), &OrderRequest{Instrument: "EUR_USD", Side: Short, Units: 100}, false},
+		{MaxLeverage(1), &OrderRequest{Instrument: "EUR_USD", Side: Long, Units: 100}, true},
+	}
+
+	for i, test := range tests {
+		if rejection := newRiskManager([]RiskRule{test.rule}).check(account, test.order, pending); (rejection != nil) != test.rejected {
+			t.Errorf("order %d: rejected %v, expected %v", i, rejection, test.rejected)
+		}
+	}
+
+	inst.hedgeType = HalfHedge // the short order opens a trade
+
+	if rejection := newRiskManager([]RiskRule{MaxOpenTrades(2)}).check(account, tests[2].order, pending); rejection == nil {
+		t.Errorf("order opening a trade accepted above the limit")
+	}
+}
+
+func TestRiskRulesStateIsBuiltForEachEngine(t *testing.T) {
+
+	rules := []RiskRule{MinOrderInterval(time.Minute)}
+	order := &OrderRequest{Instrument: "EUR_USD", Side: Long, Units: 100, Time: time.Now()}
+
+	if rejection := newRiskManager(rules).check(newAccount("first"), order, nil); rejection != nil {
+		t.Fatalf("first order rejected: %v", rejection)
+	}
+
+	if rejection := newRiskManager(rules).check(newAccount("second"), order, nil); rejection != nil {
+		t.Errorf("order rejected by the state of another engine: %v", rejection)
+	}
+}
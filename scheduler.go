@@ -0,0 +1,174 @@
+package gotrader
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// ScheduledTask represents a callback scheduled in the engine with Schedule or Every.
+type ScheduledTask struct {
+	at        time.Time
+	interval  time.Duration
+	fn        func()
+	cancelled *atomic.Bool
+	index     int
+}
+
+// Cancel prevents the next executions of the task.
+func (t *ScheduledTask) Cancel() {
+	t.cancelled.Store(true)
+}
+
+// Cancelled returns true if the task has been cancelled.
+func (t *ScheduledTask) Cancelled() bool {
+	return t.cancelled.Load()
+}
+
+func newCancelledTask() *ScheduledTask {
+	return &ScheduledTask{cancelled: atomic.NewBool(true)}
+}
+
+type taskHeap []*ScheduledTask
+
+func (h taskHeap) Len() int           { return len(h) }
+func (h taskHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h taskHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *taskHeap) Push(x interface{}) {
+	task := x.(*ScheduledTask)
+	task.index = len(*h)
+	*h = append(*h, task)
+}
+
+func (h *taskHeap) Pop() interface{} {
+	old := *h
+	task := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return task
+}
+
+// scheduler keeps the tasks ordered by execution time. Tasks are executed by the engines in
+// their event loop: the live engine with a timer on the wall clock and the backtest engine
+// before each tick, with the simulated clock.
+type scheduler struct {
+	mutex      sync.Mutex
+	tasks      taskHeap
+	unanchored []*ScheduledTask // periodic tasks waiting for the first simulated time
+	wake       chan struct{}    // signals the live loop that the next execution time may have changed
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// schedule adds a task executed at the given time and, if interval is positive, every interval after it.
+// A zero time anchors a periodic task to the first time passed to anchor.
+func (s *scheduler) schedule(at time.Time, interval time.Duration, fn func()) *ScheduledTask {
+
+	task := &ScheduledTask{
+		at:        at,
+		interval:  interval,
+		fn:        fn,
+		cancelled: atomic.NewBool(false),
+	}
+
+	s.mutex.Lock()
+
+	if at.IsZero() {
+		s.unanchored = append(s.unanchored, task)
+	} else {
+		heap.Push(&s.tasks, task)
+	}
+
+	s.mutex.Unlock()
+
+	select { // non blocking, one pending signal is enough
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return task
+}
+
+// anchor schedules the periodic tasks created before the clock was defined.
+func (s *scheduler) anchor(now time.Time) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, task := range s.unanchored {
+		task.at = now.Add(task.interval)
+		heap.Push(&s.tasks, task)
+	}
+
+	s.unanchored = nil
+}
+
+// next returns the time of the next task.
+func (s *scheduler) next() (time.Time, bool) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for len(s.tasks) > 0 && s.tasks[0].cancelled.Load() {
+		heap.Pop(&s.tasks)
+	}
+
+	if len(s.tasks) == 0 {
+		return time.Time{}, false
+	}
+
+	return s.tasks[0].at, true
+}
+
+// pop returns the next task due at now, or nil if there is none. Periodic tasks are rescheduled,
+// skipping the missed executions if skipMissed is true (otherwise each one is executed).
+func (s *scheduler) pop(now time.Time, skipMissed bool) *ScheduledTask {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for len(s.tasks) > 0 {
+
+		task := s.tasks[0]
+
+		if task.cancelled.Load() {
+			heap.Pop(&s.tasks)
+			continue
+		}
+
+		if task.at.After(now) {
+			return nil
+		}
+
+		due := *task
+
+		if task.interval > 0 {
+
+			task.at = task.at.Add(task.interval)
+
+			for skipMissed && !task.at.After(now) {
+				task.at = task.at.Add(task.interval)
+			}
+
+			heap.Fix(&s.tasks, 0)
+
+		} else {
+			heap.Pop(&s.tasks)
+		}
+
+		return &due
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package gotrader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+
+	start := time.Date(2020, 7, 6, 10, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+	tests := []struct {
+		name       string
+		skipMissed bool
+		setup      func(s *scheduler, run func(name string) func())
+		anchor     time.Time
+		now        time.Time
+		expected   []string
+		next       time.Time
+	}{
+		{
+			name: "tasks run in time order",
+			setup: func(s *scheduler, run func(string) func()) {
+				s.schedule(at(3), 0, run("c"))
+				s.schedule(at(1), 0, run("a"))
+				s.schedule(at(2), 0, run("b"))
+				s.schedule(at(10), 0, run("late"))
+			},
+			now:      at(5),
+			expected: []string{"a", "b", "c"},
+			next:     at(10),
+		},
+		{
+			name:       "periodic task skips missed executions",
+			skipMissed: true,
+			setup: func(s *scheduler, run func(string) func()) {
+				s.schedule(at(1), 2*time.Second, run("p"))
+			},
+			now:      at(6),
+			expected: []string{"p"},
+			next:     at(7),
+		},
+		{
+			name: "periodic task runs every missed execution",
+			setup: func(s *scheduler, run func(string) func()) {
+				s.schedule(at(1), 2*time.Second, run("p"))
+			},
+			now:      at(6),
+			expected: []string{"p", "p", "p"},
+			next:     at(7),
+		},
+		{
+			name: "cancelled tasks are dropped",
+			setup: func(s *scheduler, run func(string) func()) {
+				s.schedule(at(1), 0, run("a"))
+				s.schedule(at(2), time.Second, run("b")).Cancel()
+				s.schedule(at(8), 0, run("c")).Cancel()
+			},
+			now:      at(5),
+			expected: []string{"a"},
+		},
+		{
+			name: "unanchored tasks start from the anchor",
+			setup: func(s *scheduler, run func(string) func()) {
+				s.schedule(time.Time{}, 0, run("once"))
+				s.schedule(time.Time{}, 2*time.Second, run("p"))
+			},
+			anchor:   at(1),
+			now:      at(3),
+			expected: []string{"once", "p"},
+			next:     at(5),
+		},
+		{
+			name: "unanchored tasks wait for the anchor",
+			setup: func(s *scheduler, run func(string) func()) {
+				s.schedule(time.Time{}, time.Second, run("p"))
+			},
+			now: at(3),
+		},
+	}
+
+	for _, test := range tests {
+
+		s := newScheduler()
+		var executed []string
+
+		test.setup(s, func(name string) func() {
+			return func() { executed = append(executed, name) }
+		})
+
+		if !test.anchor.IsZero() {
+			s.anchor(test.anchor)
+		}
+
+		for task := s.pop(test.now, test.skipMissed); task != nil; task = s.pop(test.now, test.skipMissed) {
+			task.fn()
+		}
+
+		if len(executed) != len(test.expected) {
+			t.Errorf("%s: executed %v, expected %v", test.name, executed, test.expected)
+			continue
+		}
+
+		for i := range executed {
+			if executed[i] != test.expected[i] {
+				t.Errorf("%s: executed %v, expected %v", test.name, executed, test.expected)
+				break
+			}
+		}
+
+		next, exist := s.next()
+		if exist != !test.next.IsZero() || !next.Equal(test.next) {
+			t.Errorf("%s: next execution at %v, expected %v", test.name, next, test.next)
+		}
+	}
+}
+
+func TestLiveScheduleWithoutTime(t *testing.T) {
+
+	e := &liveEngine{scheduler: newScheduler()}
+	e.Schedule(time.Time{}, func() {})
+
+	if next, exist := e.scheduler.next(); !exist || next.IsZero() || next.After(time.Now()) {
+		t.Errorf("task without time should be due immediately, next execution at %v", next)
+	}
+}
@@ -1,7 +1,13 @@
 package gotrader
 
 import (
+	"context"
 	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -84,6 +90,194 @@ func SetLogger(logger Logger) Option {
 	}
 }
 
+// Timeframes is the functional option to define the timeframes of the bars built for the strategy.
+// Bars are delivered to strategies that implement the BarStrategy interface.
+func Timeframes(timeframes []Timeframe) Option {
+	return func(p *sessionParameters) {
+		p.timeframes = timeframes
+	}
+}
+
+// DailyAlignment is the functional option to define the daily close used to align the bars,
+// by default bars are aligned to midnight UTC.
+func DailyAlignment(hour int, location *time.Location) Option {
+	return func(p *sessionParameters) {
+		p.alignHour = hour
+		p.alignLocation = location
+	}
+}
+
+// Recording is the functional option to record the ticks received by the live engine in files with
+// the given prefix, rotated when they reach maxFileSize bytes (see Recorder). Order fills, swap charges
+// and funds transfers are recorded as well if events is true.
+func Recording(prefix string, maxFileSize int64, events bool) Option {
+	return func(p *sessionParameters) {
+		p.recordPrefix = prefix
+		p.recordMaxFileSize = maxFileSize
+		p.recordEvents = events
+	}
+}
+
+// WarmUp is the functional option to replay to the strategy the last count bars of the given timeframe
+// before trading, when the broker client implements HistoryClient. Replayed bars and ticks have IsWarmUp
+// set and orders sent by the strategy during the warm up are ignored.
+func WarmUp(count int, timeframe Timeframe) Option {
+	return func(p *sessionParameters) {
+		p.warmUpCount = count
+		p.warmUpTimeframe = timeframe
+	}
+}
+
+// Holidays is the functional option to load the market holidays from a file, with a date (YYYY-MM-DD) per line
+// optionally followed by a description. A holiday is a trading day, which closes at 5pm New York on that date.
+func Holidays(file string) Option {
+	return func(p *sessionParameters) {
+		p.holidaysFile = file
+	}
+}
+
+// TradingWindows is the functional option to restrict the new entries on an instrument to the given daily windows.
+func TradingWindows(instrument string, windows ...TradingWindow) Option {
+	return func(p *sessionParameters) {
+		if p.tradingWindows == nil {
+			p.tradingWindows = make(map[string][]TradingWindow)
+		}
+		p.tradingWindows[instrument] = append(p.tradingWindows[instrument], windows...)
+	}
+}
+
+// RolloverBlackout is the functional option to suppress new entries closer than window to the daily 5pm New York rollover.
+func RolloverBlackout(window time.Duration) Option {
+	return func(p *sessionParameters) {
+		p.rolloverBlackout = window
+	}
+}
+
+// FlattenBeforeWeekend is the functional option to close all trades the given time before the weekly close,
+// new entries are suppressed from then until the market reopens.
+func FlattenBeforeWeekend(before time.Duration) Option {
+	return func(p *sessionParameters) {
+		p.flattenBefore = before
+	}
+}
+
+// FilterTicks is the functional option to drop the invalid ticks before they update the instruments: ticks with
+// zero prices, crossed books or a time before the previous tick of the instrument and, if jumpATRs is positive,
+// ticks with a mid price change above jumpATRs times its average over the last atrPeriod ticks.
+// Strategies that implement TickRejectionStrategy are notified of the dropped ticks.
+func FilterTicks(jumpATRs float64, atrPeriod int) Option {
+	return func(p *sessionParameters) {
+		p.filterTicks = true
+		p.jumpATRs = jumpATRs
+		p.atrPeriod = atrPeriod
+	}
+}
+
+// RiskRules is the functional option to add pre-trade risk rules, evaluated in order before each new order
+// is sent. Rejected orders are notified to the strategy as order fills with the rejection defined.
+func RiskRules(rules ...RiskRule) Option {
+	return func(p *sessionParameters) {
+		p.riskRules = append(p.riskRules, rules...)
+	}
+}
+
+// CircuitBreaker is the functional option to protect the account with a circuit breaker, which blocks new
+// entries when the equity breaches one of its limits until the start of the next day. Strategies that implement
+// CircuitBreakerStrategy are notified when it is tripped.
+func CircuitBreaker(opts ...BreakerOption) Option {
+	return func(p *sessionParameters) {
+		if p.breakerOptions == nil { // enabled even without limits, so it can be configured incrementally
+			p.breakerOptions = []BreakerOption{}
+		}
+		p.breakerOptions = append(p.breakerOptions, opts...)
+	}
+}
+
+// ThrottleOrders is the functional option to limit the rate of the orders sent to the broker, for the account
+// and for each instrument. The policy defines if the orders above the limits wait or are rejected. The orders
+// merged into a waiting order by CoalesceOrders are notified as rejections, like the orders rejected.
+func ThrottleOrders(policy ThrottlePolicy, account RateLimit, instrument RateLimit) Option {
+	return func(p *sessionParameters) {
+		p.throttle = true
+		p.throttlePolicy = policy
+		p.accountOrderLimit = account
+		p.instrumentOrderLimit = instrument
+	}
+}
+
+// Reconcile is the functional option to compare periodically the trades and balance of the live engine with the
+// broker state. Differences are notified to strategies that implement ReconciliationStrategy and, if repair is true,
+// the engine state is replaced by the broker state.
+func Reconcile(interval time.Duration, repair bool) Option {
+	return func(p *sessionParameters) {
+		p.reconcileInterval = interval
+		p.reconcileRepair = repair
+	}
+}
+
+// StaleDataTimeouts is the functional option to detect the stale prices in the live engine: the prices of an
+// instrument are stale if no tick is received for tickTimeout while the market is open, and all prices are stale if
+// the price stream sends no heartbeat for heartbeatTimeout, when the client implements HeartbeatClient. A zero
+// timeout disables its check. New entries on stale instruments are rejected, the prices are resubscribed and
+// strategies that implement StaleDataStrategy are notified.
+func StaleDataTimeouts(tickTimeout, heartbeatTimeout time.Duration) Option {
+	return func(p *sessionParameters) {
+		p.staleTickTimeout = tickTimeout
+		p.staleHeartbeatTimeout = heartbeatTimeout
+	}
+}
+
+// StatusServer is the functional option to serve the status of the live session as JSON on address, for liveness
+// and readiness probes: /healthz responds while the event loop is running, /readyz responds with 200 once all prices
+// were received while the stream is connected, /status responds with the stream, account, trades, orders and
+// recent errors and /metrics with the session metrics (see TradingSession.MetricsHandler). The server starts after
+// the warm up and stops with the session. The stream connectivity is detected by the stale data checks, which are
+// enabled with a 30 seconds heartbeat timeout (a 5 minutes tick timeout if the client doesn't send heartbeats)
+// unless StaleDataTimeouts is set.
+func StatusServer(address string) Option {
+	return func(p *sessionParameters) {
+		p.statusAddress = address
+	}
+}
+
+// SessionTag is the functional option to tag the trades opened by the session, so they can be identified in the
+// broker (see Trade.Tag). The client must implement TaggingClient.
+func SessionTag(tag string) Option {
+	return func(p *sessionParameters) {
+		p.sessionTag = tag
+	}
+}
+
+// Shutdown is the functional option to define what happens to the open trades when the live session stops.
+// The result is notified to strategies that implement ShutdownStrategy. The policy only applies to live
+// sessions, a backtest ends with its trades open as they are, so the results are not affected by closing costs.
+func Shutdown(policy ShutdownPolicy) Option {
+	return func(p *sessionParameters) {
+		p.shutdownPolicy = policy
+	}
+}
+
+// PersistState is the functional option to keep, in the store, the live engine state that the broker doesn't
+// provide (trade tags, circuit breaker state, strategy State and orders waiting for the throttle), so it survives
+// restarts of the session. The trades repaired by the reconciliation are saved as the filled trades. The orders
+// waiting for the throttle aren't sent after a restart, the strategy is notified of them as rejected orders.
+func PersistState(store StateStore) Option {
+	return func(p *sessionParameters) {
+		p.stateStore = store
+	}
+}
+
+// HandleSignals is the functional option to stop the session gracefully when the process receives one of the
+// signals, SIGINT and SIGTERM if none is given.
+func HandleSignals(signals ...os.Signal) Option {
+	return func(p *sessionParameters) {
+		if len(signals) == 0 {
+			signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+		}
+		p.signals = signals
+	}
+}
+
 type testParameters struct {
 	initialBalance float64
 	homeCurrency   string
@@ -96,6 +290,48 @@ type sessionParameters struct {
 	account        string
 	testParameters *testParameters
 	logger         Logger
+	timeframes     []Timeframe
+	alignHour      int
+	alignLocation  *time.Location
+
+	recordPrefix      string
+	recordMaxFileSize int64
+	recordEvents      bool
+
+	warmUpCount     int
+	warmUpTimeframe Timeframe
+
+	holidaysFile     string
+	tradingWindows   map[string][]TradingWindow
+	rolloverBlackout time.Duration
+	flattenBefore    time.Duration
+
+	filterTicks bool
+	jumpATRs    float64
+	atrPeriod   int
+
+	riskRules      []RiskRule
+	breakerOptions []BreakerOption
+
+	throttle             bool
+	throttlePolicy       ThrottlePolicy
+	accountOrderLimit    RateLimit
+	instrumentOrderLimit RateLimit
+
+	reconcileInterval time.Duration
+	reconcileRepair   bool
+
+	staleTickTimeout      time.Duration
+	staleHeartbeatTimeout time.Duration
+
+	statusAddress string
+
+	signals []os.Signal
+
+	sessionTag     string
+	shutdownPolicy ShutdownPolicy
+
+	stateStore StateStore
 }
 
 // TradingSession represents the entrypoint struct of the gotrader package, representing a trading session.
@@ -158,13 +394,58 @@ func (s *TradingSession) Backtest() *TradingSession {
 	return s
 }
 
-// Start trading session.
+// MetricsHandler returns a handler that serves the metrics of the live session in the Prometheus text format, to be
+// mounted on the application server (the StatusServer serves them on /metrics as well). It must be called after Live,
+// backtest sessions don't have metrics.
+func (s *TradingSession) MetricsHandler() http.Handler {
+
+	engine, ok := s.engine.(*liveEngine)
+	if !ok {
+		return http.NotFoundHandler()
+	}
+
+	return http.HandlerFunc(engine.serveMetrics)
+}
+
+// Start trading session, blocks until the session is stopped by the strategy or by a signal (see HandleSignals).
 func (s *TradingSession) Start() error {
+	return s.Run(context.Background())
+}
+
+// Run trading session, blocks until the session is stopped by the strategy, by a signal (see HandleSignals) or
+// by cancelling the context. When it returns, the subscriptions are stopped and the strategy OnStop was called.
+func (s *TradingSession) Run(ctx context.Context) error {
 
 	if s.engine == nil {
 		return errors.New("engine type is not defined")
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if len(s.parameters.signals) > 0 {
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, s.parameters.signals...)
+
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+
+			select {
+			case <-signals:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		defer func() {
+			signal.Stop(signals)
+			cancel()
+			<-stopped
+		}()
+	}
+
 	var err error
 
 	switch s.engineType {
@@ -173,13 +454,13 @@ func (s *TradingSession) Start() error {
 		engine.client = s.client
 		engine.strategy = s.strategy
 		engine.parameters = s.parameters
-		err = engine.start()
+		err = engine.start(ctx)
 	case 1:
 		engine := s.engine.(*btEngine)
 		engine.client = s.client
 		engine.strategy = s.strategy
 		engine.parameters = s.parameters
-		err = engine.start()
+		err = engine.start(ctx)
 	}
 
 	return err
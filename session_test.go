@@ -0,0 +1,272 @@
+package gotrader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var eurUsd = InstrumentDetails{Name: "EUR_USD", BaseCurrency: "EUR", QuoteCurrency: "USD", Leverage: 30, PipLocation: -4}
+
+// fakeLiveClient streams EUR_USD ticks until it is closed and fills the market orders and closes.
+type fakeLiveClient struct {
+	mutex     sync.Mutex
+	trades    []TradeDetails
+	onTick    TickHandler
+	onFill    OrderFillHandler
+	fills     chan *OrderFill
+	closed    chan struct{}
+	streaming sync.WaitGroup
+	closes    int
+	closeErr  error // returned by CloseTrade instead of filling the close
+}
+
+func newFakeLiveClient() *fakeLiveClient {
+	return &fakeLiveClient{fills: make(chan *OrderFill, 10), closed: make(chan struct{})}
+}
+
+func (c *fakeLiveClient) GetAccountStatus(accountID string) (AccountStatus, error) {
+	return AccountStatus{Currency: "USD", Balance: 10000, Leverage: 30}, nil
+}
+
+func (c *fakeLiveClient) GetAvailableInstruments(accountID string) ([]InstrumentDetails, error) {
+	return []InstrumentDetails{eurUsd}, nil
+}
+
+func (c *fakeLiveClient) OpenMarketOrder(accountID, instrument string, units int32, side string) error {
+	return c.OpenTaggedMarketOrder(accountID, instrument, units, side, "")
+}
+
+func (c *fakeLiveClient) OpenTaggedMarketOrder(accountID, instrument string, units int32, side, tag string) error {
+	c.fills <- &OrderFill{TradeID: "1", Side: Long, Instrument: eurUsd, Price: 1.1, Units: units, Time: time.Now(), Tag: tag}
+	return nil
+}
+
+func (c *fakeLiveClient) CloseTrade(accountID, id string) error {
+	if c.closeErr != nil {
+		return c.closeErr
+	}
+	c.fills <- &OrderFill{TradeClose: true, TradeID: id, Instrument: eurUsd, Price: 1.1, Time: time.Now()}
+	return nil
+}
+
+func (c *fakeLiveClient) GetOpenTrades(accountID string) ([]TradeDetails, error) {
+	return c.trades, nil
+}
+
+func (c *fakeLiveClient) SubscribePrices(accountID string, instruments []InstrumentDetails, callback TickHandler) error {
+	c.onTick = callback
+	return nil
+}
+
+func (c *fakeLiveClient) SubscribeOrderFillNotifications(accountID string, callback OrderFillHandler) error {
+
+	c.onFill = callback
+	c.streaming.Add(1)
+
+	go func() {
+		defer c.streaming.Done()
+
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.closed:
+				return
+			case fill := <-c.fills:
+				c.onFill(fill)
+			case now := <-ticker.C:
+				c.onTick(&Tick{Instrument: eurUsd.Name, Bid: 1.1, Ask: 1.1001, Time: now})
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (c *fakeLiveClient) SubscribeSwapChargeNotifications(accountID string, callback SwapChargeHandler) error {
+	return nil
+}
+
+func (c *fakeLiveClient) SubscribeFundsTransferNotifications(accountID string, callback FundsTransferHandler) error {
+	return nil
+}
+
+func (c *fakeLiveClient) Close() error {
+
+	c.mutex.Lock()
+	c.closes++
+	c.mutex.Unlock()
+
+	close(c.closed)
+	c.streaming.Wait()
+
+	return nil
+}
+
+type lifecycleStrategy struct {
+	engine   Engine
+	filled   chan struct{}
+	bought   bool
+	fills    []*OrderFill
+	stops    int
+	onStops  []int32 // open trades seen by OnStop
+	shutdown *ShutdownReport
+}
+
+func (s *lifecycleStrategy) Initialize()             {}
+func (s *lifecycleStrategy) SetEngine(engine Engine) { s.engine = engine }
+
+func (s *lifecycleStrategy) OnTick(tick *Tick) {
+	if !s.bought {
+		s.bought = true
+		s.engine.Buy(tick.Instrument, 1000)
+	}
+}
+
+func (s *lifecycleStrategy) OnOrderFill(orderFill *OrderFill) {
+	s.fills = append(s.fills, orderFill)
+	if !orderFill.TradeClose {
+		close(s.filled)
+	}
+}
+
+func (s *lifecycleStrategy) OnShutdown(report *ShutdownReport) {
+	s.shutdown = report
+}
+
+func (s *lifecycleStrategy) OnStop() {
+	s.stops++
+	s.onStops = append(s.onStops, s.engine.Account().Instrument(eurUsd.Name).TradesNumber())
+}
+
+// runUntilFilled runs a live session until the order of the strategy is filled, then cancels it.
+func runUntilFilled(t *testing.T, client *fakeLiveClient, strategy *lifecycleStrategy, opts ...Option) {
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	opts = append(opts, Instruments([]string{eurUsd.Name}), AccountID("account"), SetLogger(logger))
+	session := NewTradingSession(opts...).SetClient(client).SetStrategy(strategy).Live()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+
+	go func() { result <- session.Run(ctx) }()
+
+	select {
+	case <-strategy.filled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the order was not filled")
+	}
+
+	cancel()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+}
+
+func TestRunStopsWhenContextIsCancelled(t *testing.T) {
+
+	client := newFakeLiveClient()
+	strategy := &lifecycleStrategy{filled: make(chan struct{})}
+
+	runUntilFilled(t, client, strategy)
+
+	if client.closes != 1 {
+		t.Errorf("expected the client to be closed once, got %d", client.closes)
+	}
+
+	if strategy.stops != 1 || strategy.onStops[0] != 1 {
+		t.Errorf("expected OnStop to be called once with the filled trade open, got %d calls with %v trades", strategy.stops, strategy.onStops)
+	}
+}
+
+func TestShutdownClosesSessionTrades(t *testing.T) {
+
+	client := newFakeLiveClient()
+	client.trades = []TradeDetails{{ID: "0", Instrument: eurUsd, Side: Short, Units: 1000, OpenPrice: 1.1, Tag: "other"}}
+	strategy := &lifecycleStrategy{filled: make(chan struct{})}
+
+	runUntilFilled(t, client, strategy, SessionTag("session"), Shutdown(ShutdownPolicy{Action: CloseSessionTrades}))
+
+	if report := strategy.shutdown; report == nil || !report.Done() || len(report.Closed) != 1 || report.Closed[0] != "1" {
+		t.Fatalf("expected the session trade to be closed, got %+v", report)
+	}
+
+	if strategy.stops != 1 || strategy.onStops[0] != 1 {
+		t.Errorf("expected OnStop to see the trade of the other session open, got %v trades", strategy.onStops)
+	}
+}
+
+func TestShutdownReportsFailedCloses(t *testing.T) {
+
+	client := newFakeLiveClient()
+	client.closeErr = errors.New("trade does not exist")
+	strategy := &lifecycleStrategy{filled: make(chan struct{})}
+
+	runUntilFilled(t, client, strategy, Shutdown(ShutdownPolicy{Action: CloseAllTrades}))
+
+	if report := strategy.shutdown; report == nil || report.Done() || len(report.Closed) != 0 || report.Failed["1"] != "trade does not exist" {
+		t.Fatalf("expected the close to be reported as failed, got %+v", report)
+	}
+
+	if strategy.onStops[0] != 1 {
+		t.Errorf("expected OnStop to see the trade open, got %v trades", strategy.onStops)
+	}
+}
+
+// hangingClient never answers the close requests, and has no price for the protective stops.
+type hangingClient struct {
+	*fakeLiveClient
+	release chan struct{}
+	stops   int
+}
+
+func (c *hangingClient) CloseTrade(accountID, id string) error {
+	<-c.release // blocks like a request without a timeout
+	return nil
+}
+
+func (c *hangingClient) SetStopLoss(accountID, id string, price float64) error {
+	c.stops++
+	return nil
+}
+
+func TestShutdownDeadlineBoundsRequests(t *testing.T) {
+
+	account := newAccount("test")
+	inst := newInstrument("EUR_USD", "EUR", "USD", 20, -4, nil)
+	inst.ccyConversion = newInstrumentConversion("EUR_USD", "EUR", "USD")
+	account.instruments["EUR_USD"] = inst
+	inst.openTrade("1", Long, time.Now(), 1000, 1.1)
+	inst.openTrade("2", Long, time.Now(), 1000, 1.1)
+
+	client := &hangingClient{fakeLiveClient: newFakeLiveClient(), release: make(chan struct{})}
+	defer close(client.release)
+
+	await := func(done func() bool, deadline time.Time) {}
+
+	report := ShutdownPolicy{Action: CloseAllTrades, Deadline: 50 * time.Millisecond}.execute(account, client, "", await)
+
+	if len(report.Failed) != 1 || len(report.Skipped) != 1 || time.Since(report.Time) > time.Second {
+		t.Errorf("expected the request to fail at the deadline and the other trade to be skipped, got %+v", report)
+	}
+
+	report = ShutdownPolicy{Action: ProtectTrades, StopPips: 10}.execute(account, client, "", await)
+
+	if len(report.Failed) != 2 || client.stops != 0 {
+		t.Errorf("expected the trades without a price to fail, got %+v", report)
+	}
+}
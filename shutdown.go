@@ -0,0 +1,203 @@
+package gotrader
+
+import (
+	"errors"
+	"time"
+)
+
+// Default time to execute the shutdown policy
+const defaultShutdownDeadline = 30 * time.Second
+
+// ShutdownAction defines what happens to the open trades when a live session stops.
+type ShutdownAction int
+
+const (
+	KeepTrades         ShutdownAction = iota // trades are left open
+	CloseAllTrades                           // all trades of the trading instruments are closed
+	CloseSessionTrades                       // trades tagged with the session tag are closed
+	ProtectTrades                            // trades are left open with a protective stop loss
+)
+
+func (a ShutdownAction) String() string {
+	switch a {
+	case CloseAllTrades:
+		return "CLOSE_ALL_TRADES"
+	case CloseSessionTrades:
+		return "CLOSE_SESSION_TRADES"
+	case ProtectTrades:
+		return "PROTECT_TRADES"
+	default:
+		return "KEEP_TRADES"
+	}
+}
+
+// ShutdownPolicy defines the action applied to the open trades when a live session stops gracefully.
+// No new requests are sent after the deadline (30 seconds if not defined), and a request without a response
+// at the deadline is reported as failed. Protective stops are placed StopPips from the current price.
+type ShutdownPolicy struct {
+	Action   ShutdownAction
+	Deadline time.Duration
+	StopPips float64
+}
+
+// ShutdownReport reports the actions of the shutdown policy, by trade ID.
+type ShutdownReport struct {
+	Action    ShutdownAction
+	Closed    []string
+	Protected []string
+	Failed    map[string]string // trades whose request failed, with the error
+	Skipped   []string          // trades not handled before the deadline
+	Time      time.Time
+}
+
+// Done returns true if all the trades were handled.
+func (r *ShutdownReport) Done() bool {
+	return len(r.Failed) == 0 && len(r.Skipped) == 0
+}
+
+// execute applies the policy to the trades of the account, the requests are sent one by one until the deadline.
+// await must apply the notifications until done returns true or the deadline expires.
+func (p ShutdownPolicy) execute(
+	account *Account,
+	client BrokerClient,
+	tag string,
+	await func(done func() bool, deadline time.Time),
+) *ShutdownReport {
+
+	deadline := p.Deadline
+	if deadline <= 0 {
+		deadline = defaultShutdownDeadline
+	}
+
+	start := time.Now()
+	report := &ShutdownReport{Action: p.Action, Failed: make(map[string]string), Time: start}
+
+	for _, trade := range p.trades(account, tag) {
+
+		if time.Since(start) > deadline {
+			report.Skipped = append(report.Skipped, trade.id)
+			continue
+		}
+
+		var err error
+		id := trade.id // the request can outlive the iteration
+
+		if p.Action == ProtectTrades {
+
+			price, valid := p.stopPrice(account.instruments[trade.instrumentName], trade.side)
+			if !valid {
+				report.Failed[trade.id] = "no price to place the protective stop"
+				continue
+			}
+
+			err = p.request(start.Add(deadline), func() error {
+				return client.(ProtectionClient).SetStopLoss(account.id, id, price)
+			})
+
+		} else {
+			err = p.request(start.Add(deadline), func() error {
+				return client.CloseTrade(account.id, id)
+			})
+		}
+
+		switch {
+		case err != nil:
+			report.Failed[trade.id] = err.Error()
+		case p.Action == ProtectTrades:
+			report.Protected = append(report.Protected, trade.id)
+		default:
+			report.Closed = append(report.Closed, trade.id)
+		}
+	}
+
+	// Wait for the close notifications, so the account is up to date when the strategy stops
+	await(func() bool { return p.closed(account, report.Closed) }, start.Add(deadline))
+
+	return report
+}
+
+// trades returns the trades affected by the policy.
+func (p ShutdownPolicy) trades(account *Account, tag string) []*Trade {
+
+	var trades []*Trade
+
+	for _, inst := range account.instruments {
+		for trade := range inst.Trades() {
+			if p.Action != CloseSessionTrades || trade.tag == tag {
+				trades = append(trades, trade)
+			}
+		}
+	}
+
+	return trades
+}
+
+// request runs a broker request, the clients don't have timeouts so it stops waiting for the response at the deadline.
+func (p ShutdownPolicy) request(deadline time.Time, send func() error) error {
+
+	response := make(chan error, 1) // the request can return after the deadline, without blocking
+
+	go func() {
+		response <- send()
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case err := <-response:
+		return err
+	case <-timer.C:
+		return errors.New("no response before the shutdown deadline")
+	}
+}
+
+// stopPrice returns the price of the protective stop, false if the instrument has no price yet.
+func (p ShutdownPolicy) stopPrice(inst *Instrument, side Side) (float64, bool) {
+
+	if side == Long {
+		if inst.Bid() <= 0 {
+			return 0, false
+		}
+		return inst.RoundPrice(inst.Bid() - inst.PipsToPrice(p.StopPips)), true
+	}
+
+	if inst.Ask() <= 0 {
+		return 0, false
+	}
+
+	return inst.RoundPrice(inst.Ask() + inst.PipsToPrice(p.StopPips)), true
+}
+
+func (p ShutdownPolicy) closed(account *Account, ids []string) bool {
+
+	for _, inst := range account.instruments {
+		for _, id := range ids {
+			if inst.Trade(id) != nil {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// validate checks that the policy can be applied with the client and the session parameters.
+func (p ShutdownPolicy) validate(client BrokerClient, tag string) error {
+
+	switch p.Action {
+	case CloseSessionTrades:
+		if tag == "" {
+			return errors.New("closing the session trades requires a session tag")
+		}
+	case ProtectTrades:
+		if _, ok := client.(ProtectionClient); !ok {
+			return errors.New("client can't protect trades with stop losses")
+		}
+		if p.StopPips <= 0 {
+			return errors.New("protective stop distance must be positive")
+		}
+	}
+
+	return nil
+}
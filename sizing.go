@@ -0,0 +1,128 @@
+package gotrader
+
+import (
+	"math"
+)
+
+type riskMode int
+
+const (
+	fixedRisk riskMode = iota
+	equityRisk
+	freeMarginRisk
+)
+
+// Risk represents the amount of the account risked by a trade.
+type Risk struct {
+	mode  riskMode
+	value float64
+}
+
+// FixedRisk risks a fixed amount of the home currency.
+func FixedRisk(amount float64) Risk {
+	return Risk{mode: fixedRisk, value: amount}
+}
+
+// EquityRisk risks a fraction of the equity (e.g. 0.01 for 1%).
+func EquityRisk(fraction float64) Risk {
+	return Risk{mode: equityRisk, value: fraction}
+}
+
+// FreeMarginRisk risks a fraction of the free margin (e.g. 0.01 for 1%).
+func FreeMarginRisk(fraction float64) Risk {
+	return Risk{mode: freeMarginRisk, value: fraction}
+}
+
+// Sizer converts risk into units of an instrument. Units are always capped by the free margin
+// and normalized with the broker's trade size limits (see Instrument.NormalizeUnits).
+type Sizer struct {
+	account *Account
+}
+
+func newSizer(account *Account) *Sizer {
+	return &Sizer{account: account}
+}
+
+// RiskAmount returns the amount risked in the home currency.
+func (s *Sizer) RiskAmount(risk Risk) float64 {
+
+	switch risk.mode {
+	case equityRisk:
+		return risk.value * s.account.Equity()
+	case freeMarginRisk:
+		return risk.value * s.account.MarginFree()
+	}
+
+	return risk.value
+}
+
+// UnitsForStop returns the units that lose the risked amount when the price moves stopPips against the trade.
+func (s *Sizer) UnitsForStop(instrument string, risk Risk, stopPips float64) int32 {
+
+	inst := s.account.Instrument(instrument)
+	if inst == nil || stopPips <= 0 {
+		return 0
+	}
+
+	pipValue := inst.PipValue(1)
+	if pipValue <= 0 {
+		return 0
+	}
+
+	return s.cap(inst, s.RiskAmount(risk)/(stopPips*pipValue))
+}
+
+// UnitsForVolatility returns the units that lose the risked amount when the price moves atrMultiple
+// ATRs against the trade, so positions are smaller when the market is more volatile.
+func (s *Sizer) UnitsForVolatility(instrument string, risk Risk, atr float64, atrMultiple float64) int32 {
+
+	inst := s.account.Instrument(instrument)
+	if inst == nil {
+		return 0
+	}
+
+	return s.UnitsForStop(instrument, risk, inst.PriceToPips(atr*atrMultiple))
+}
+
+// FixedFractional returns the units with a notional value in the home currency of a fraction of
+// the equity (e.g. 2 for a notional of twice the equity).
+func (s *Sizer) FixedFractional(instrument string, fraction float64) int32 {
+
+	inst := s.account.Instrument(instrument)
+	if inst == nil || inst.ccyConversion == nil {
+		return 0
+	}
+
+	rate := inst.ccyConversion.BaseConversionRate.Load()
+	if rate <= 0 {
+		return 0
+	}
+
+	return s.cap(inst, fraction*s.account.Equity()/rate)
+}
+
+// MaxUnits returns the units that use all the free margin.
+func (s *Sizer) MaxUnits(instrument string) int32 {
+
+	inst := s.account.Instrument(instrument)
+	if inst == nil {
+		return 0
+	}
+
+	return s.cap(inst, math.MaxInt32)
+}
+
+func (s *Sizer) cap(inst *Instrument, units float64) int32 {
+
+	if margin := inst.MarginRequired(1); margin > 0 {
+		units = math.Min(units, s.account.MarginFree()/margin)
+	}
+
+	return inst.NormalizeUnits(units)
+}
+
+// marginRequired returns the margin in the home currency of the given units: their notional value,
+// converted with the base currency rate, divided by the leverage.
+func marginRequired(units float64, leverage float64, conversion *instrumentConversion) float64 {
+	return units / leverage * conversion.BaseConversionRate.Load()
+}
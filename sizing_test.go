@@ -0,0 +1,40 @@
+package gotrader
+
+import (
+	"testing"
+)
+
+func TestSizer(t *testing.T) {
+
+	account := newAccount("test")
+	account.equity = 10000
+	account.marginFree = 2000
+
+	inst := newInstrument("EUR_USD", "EUR", "USD", 20, -4, nil)
+	inst.setDetails(InstrumentDetails{MinimumTradeSize: 1, MaximumOrderUnits: 50000})
+	inst.ccyConversion = newInstrumentConversion("EUR_USD", "EUR", "USD")
+	inst.ccyConversion.BaseConversionRate.Store(1.25)
+	inst.ccyConversion.QuoteConversionRate.Store(1)
+	account.instruments["EUR_USD"] = inst
+
+	sizer := newSizer(account)
+
+	tests := []struct {
+		name     string
+		units    int32
+		expected int32
+	}{
+		{"fixed risk", sizer.UnitsForStop("EUR_USD", FixedRisk(10), 20), 5000},
+		{"equity risk", sizer.UnitsForStop("EUR_USD", EquityRisk(0.002), 40), 5000},
+		{"free margin risk", sizer.UnitsForStop("EUR_USD", FreeMarginRisk(0.01), 10), 20000},
+		{"volatility", sizer.UnitsForVolatility("EUR_USD", FixedRisk(10), 0.001, 2), 5000},
+		{"margin cap", sizer.UnitsForStop("EUR_USD", FixedRisk(100), 1), 32000},
+		{"fixed fractional", sizer.FixedFractional("EUR_USD", 1), 8000},
+	}
+
+	for _, test := range tests {
+		if test.units != test.expected {
+			t.Errorf("%s: %d units, expected %d", test.name, test.units, test.expected)
+		}
+	}
+}
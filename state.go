@@ -0,0 +1,313 @@
+package gotrader
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const (
+	stateSnapshotRecords = 1000        // log records after which a new snapshot is written
+	stateSaveInterval    = time.Minute // interval to save the state that changes with every tick
+)
+
+// Operations of the state log records
+const (
+	tradeOpenedOp    = "TRADE_OPENED"
+	tradeClosedOp    = "TRADE_CLOSED"
+	breakerUpdatedOp = "BREAKER_UPDATED"
+	valueSetOp       = "VALUE_SET"
+	valueDeletedOp   = "VALUE_DELETED"
+	ordersWaitingOp  = "ORDERS_WAITING"
+)
+
+// engineState is the engine state that the broker doesn't provide.
+type engineState struct {
+	Tags    map[string]string          `json:"tags"` // trade tags by trade ID
+	Breaker *breakerState              `json:"breaker,omitempty"`
+	Values  map[string]json.RawMessage `json:"values"`           // strategy state
+	Orders  []*OrderRequest            `json:"orders,omitempty"` // orders waiting for the throttle
+}
+
+type breakerState struct {
+	DayStart   time.Time `json:"dayStart"`
+	DayEquity  float64   `json:"dayEquity"`
+	PeakEquity float64   `json:"peakEquity"`
+	Breach     *Breach   `json:"breach,omitempty"`
+}
+
+// stateRecord is a change of the engine state, written to the log.
+type stateRecord struct {
+	Op      string          `json:"op"`
+	ID      string          `json:"id,omitempty"`
+	Tag     string          `json:"tag,omitempty"`
+	Breaker *breakerState   `json:"breaker,omitempty"`
+	Key     string          `json:"key,omitempty"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Orders  []*OrderRequest `json:"orders,omitempty"`
+}
+
+func (s *engineState) apply(record *stateRecord) {
+	switch record.Op {
+	case tradeOpenedOp:
+		s.Tags[record.ID] = record.Tag
+	case tradeClosedOp:
+		delete(s.Tags, record.ID)
+	case breakerUpdatedOp:
+		s.Breaker = record.Breaker
+	case valueSetOp:
+		s.Values[record.Key] = record.Value
+	case valueDeletedOp:
+		delete(s.Values, record.Key)
+	case ordersWaitingOp:
+		s.Orders = record.Orders
+	}
+}
+
+// stateKeeper keeps the engine state in a store, the changes are logged and compacted in snapshots.
+// Its methods can be called on a nil keeper, when the state isn't persisted.
+type stateKeeper struct {
+	store   StateStore
+	state   *engineState
+	records int // records logged after the last snapshot
+	mutex   sync.Mutex
+	logger  Logger
+}
+
+// loadState reads the state of the store, the snapshot and then the changes logged after it.
+func loadState(store StateStore, logger Logger) (*stateKeeper, error) {
+
+	snapshot, records, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &engineState{}
+
+	if snapshot != nil {
+		if err := json.Unmarshal(snapshot, state); err != nil {
+			return nil, err
+		}
+	}
+
+	if state.Tags == nil {
+		state.Tags = make(map[string]string)
+	}
+
+	if state.Values == nil {
+		state.Values = make(map[string]json.RawMessage)
+	}
+
+	for _, data := range records {
+
+		record := &stateRecord{}
+		if err := json.Unmarshal(data, record); err != nil {
+			return nil, err
+		}
+
+		state.apply(record)
+	}
+
+	return &stateKeeper{store: store, state: state, records: len(records), logger: logger}, nil
+}
+
+// restore merges the state with the account hydrated from the broker, the trades closed while the engine was
+// stopped are discarded. The merged state is saved as a new snapshot.
+func (k *stateKeeper) restore(account *Account, breaker *circuitBreaker) error {
+
+	if k == nil {
+		return nil
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	open := make(map[string]bool)
+
+	for _, inst := range account.instruments {
+		for trade := range inst.Trades() {
+
+			open[trade.id] = true
+
+			if tag, exist := k.state.Tags[trade.id]; exist && trade.tag == "" {
+				trade.tag = tag
+			}
+		}
+	}
+
+	for id := range k.state.Tags {
+		if !open[id] {
+			delete(k.state.Tags, id)
+		}
+	}
+
+	if breaker != nil && k.state.Breaker != nil {
+		breaker.restore(k.state.Breaker)
+	}
+
+	return k.snapshot()
+}
+
+func (k *stateKeeper) tradeOpened(trade *Trade) {
+	if k != nil && trade.tag != "" {
+		k.save(&stateRecord{Op: tradeOpenedOp, ID: trade.id, Tag: trade.tag})
+	}
+}
+
+func (k *stateKeeper) tradeClosed(id string) {
+
+	if k == nil {
+		return
+	}
+
+	k.mutex.Lock()
+	_, exist := k.state.Tags[id]
+	k.mutex.Unlock()
+
+	if exist {
+		k.save(&stateRecord{Op: tradeClosedOp, ID: id})
+	}
+}
+
+// breakerUpdated saves the circuit breaker state if it changed.
+func (k *stateKeeper) breakerUpdated(state *breakerState) {
+
+	if k == nil {
+		return
+	}
+
+	k.mutex.Lock()
+	current := k.state.Breaker
+	k.mutex.Unlock()
+
+	if current != nil && current.DayStart.Equal(state.DayStart) && current.DayEquity == state.DayEquity &&
+		current.PeakEquity == state.PeakEquity && (current.Breach != nil) == (state.Breach != nil) {
+		return
+	}
+
+	k.save(&stateRecord{Op: breakerUpdatedOp, Breaker: state})
+}
+
+func (k *stateKeeper) valueSet(key string, value json.RawMessage) error {
+
+	if k == nil {
+		return nil
+	}
+
+	return k.log(&stateRecord{Op: valueSetOp, Key: key, Value: value})
+}
+
+func (k *stateKeeper) valueDeleted(key string) error {
+
+	if k == nil {
+		return nil
+	}
+
+	return k.log(&stateRecord{Op: valueDeletedOp, Key: key})
+}
+
+// ordersWaiting saves the orders waiting for the throttle if they changed, they are reported as expired on restart.
+func (k *stateKeeper) ordersWaiting(orders []*OrderRequest) {
+
+	if k == nil {
+		return
+	}
+
+	k.mutex.Lock()
+	unchanged := len(orders) == 0 && len(k.state.Orders) == 0
+	k.mutex.Unlock()
+
+	if unchanged {
+		return
+	}
+
+	copies := make([]*OrderRequest, len(orders)) // the throttle keeps changing its orders
+	for i, order := range orders {
+		waiting := *order
+		copies[i] = &waiting
+	}
+
+	k.save(&stateRecord{Op: ordersWaitingOp, Orders: copies})
+}
+
+// waitingOrders returns the orders that were waiting for the throttle when the engine stopped.
+func (k *stateKeeper) waitingOrders() []*OrderRequest {
+
+	if k == nil {
+		return nil
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	return append([]*OrderRequest{}, k.state.Orders...)
+}
+
+// save logs a record of the engine state, errors are only logged since the engine state is still valid.
+func (k *stateKeeper) save(record *stateRecord) {
+	if err := k.log(record); err != nil {
+		k.logger.Error("saving state: " + err.Error())
+	}
+}
+
+// log applies and appends a record, returning the error if it can't be appended. The record is applied anyway,
+// so it is saved by the next snapshot.
+func (k *stateKeeper) log(record *stateRecord) error {
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.state.apply(record)
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if err := k.store.Append(data); err != nil {
+		return err
+	}
+
+	if k.records++; k.records >= stateSnapshotRecords { // the record is already saved in the log
+		if err := k.snapshot(); err != nil {
+			k.logger.Error("saving state snapshot: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (k *stateKeeper) snapshot() error {
+
+	data, err := json.Marshal(k.state)
+	if err != nil {
+		return err
+	}
+
+	if err := k.store.Snapshot(data); err != nil {
+		return err
+	}
+
+	k.records = 0
+
+	return nil
+}
+
+// close saves a final snapshot and closes the store.
+func (k *stateKeeper) close() {
+
+	if k == nil {
+		return
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if err := k.snapshot(); err != nil {
+		k.logger.Error("saving state snapshot: " + err.Error())
+	}
+
+	if err := k.store.Close(); err != nil {
+		k.logger.Error("closing state store: " + err.Error())
+	}
+}
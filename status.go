@@ -0,0 +1,345 @@
+package gotrader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	statusTimeout = 2 * time.Second // maximum wait for the event loop to take the status snapshot
+	recentErrors  = 20              // errors kept for the status
+)
+
+// SessionStatus is the state of a live session served by the status server.
+type SessionStatus struct {
+	Time    time.Time      `json:"time"`
+	Ready   bool           `json:"ready"` // all prices were received and the strategy is trading
+	Stream  StreamStatus   `json:"stream"`
+	Account AccountSummary `json:"account"`
+	Trades  []TradeStatus  `json:"trades"`
+	Orders  []OrderStatus  `json:"orders"` // orders waiting for the throttle and sent orders without response
+	Errors  []LoggedError  `json:"errors"` // last errors logged, oldest first
+}
+
+// StreamStatus is the connectivity of the price stream.
+type StreamStatus struct {
+	Connected        bool      `json:"connected"` // false while the heartbeat is lost or all the prices are stale
+	LastTick         time.Time `json:"lastTick"`
+	SinceLastTick    float64   `json:"sinceLastTick"` // seconds
+	LastHeartbeat    time.Time `json:"lastHeartbeat"`
+	StaleInstruments []string  `json:"staleInstruments"`
+}
+
+// AccountSummary is the balance, equity and margin of the account.
+type AccountSummary struct {
+	ID         string  `json:"id"`
+	Currency   string  `json:"currency"`
+	Balance    float64 `json:"balance"`
+	Equity     float64 `json:"equity"`
+	Unrealized float64 `json:"unrealized"`
+	MarginUsed float64 `json:"marginUsed"`
+	MarginFree float64 `json:"marginFree"`
+}
+
+// TradeStatus is an open trade of the account.
+type TradeStatus struct {
+	ID           string    `json:"id"`
+	Instrument   string    `json:"instrument"`
+	Side         string    `json:"side"`
+	Units        int32     `json:"units"`
+	OpenTime     time.Time `json:"openTime"`
+	OpenPrice    float64   `json:"openPrice"`
+	CurrentPrice float64   `json:"currentPrice"`
+	Unrealized   float64   `json:"unrealized"`
+	Tag          string    `json:"tag,omitempty"`
+}
+
+// OrderStatus is an order that wasn't filled yet.
+type OrderStatus struct {
+	Instrument string    `json:"instrument"`
+	Side       string    `json:"side"`
+	Units      int32     `json:"units"`
+	Time       time.Time `json:"time"`
+	Sent       bool      `json:"sent"` // false while waiting for the throttle
+}
+
+// LoggedError is an error logged by the engine.
+type LoggedError struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// errorLog is a Logger that keeps the last errors logged, for the status server.
+type errorLog struct {
+	Logger
+	errors []LoggedError // ring buffer
+	next   int
+	mutex  sync.Mutex
+}
+
+func newErrorLog(logger Logger) *errorLog {
+	return &errorLog{Logger: logger, errors: make([]LoggedError, 0, recentErrors)}
+}
+
+func (l *errorLog) Error(args ...interface{}) {
+	l.Logger.Error(args...)
+	l.record(fmt.Sprint(args...))
+}
+
+func (l *errorLog) Errorf(format string, args ...interface{}) {
+	l.Logger.Errorf(format, args...)
+	l.record(fmt.Sprintf(format, args...))
+}
+
+func (l *errorLog) record(message string) {
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	err := LoggedError{Time: time.Now(), Message: message}
+
+	if len(l.errors) < recentErrors {
+		l.errors = append(l.errors, err)
+	} else {
+		l.errors[l.next] = err
+	}
+
+	l.next = (l.next + 1) % recentErrors
+}
+
+// recent returns the errors kept, oldest first.
+func (l *errorLog) recent() []LoggedError {
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	recent := make([]LoggedError, 0, len(l.errors))
+
+	if len(l.errors) == recentErrors {
+		recent = append(recent, l.errors[l.next:]...)
+		recent = append(recent, l.errors[:l.next]...)
+	} else {
+		recent = append(recent, l.errors...)
+	}
+
+	return recent
+}
+
+// startStatusServer serves the status of the session until it stops:
+//
+//	/healthz returns 200 while the event loop responds, 503 otherwise
+//	/readyz returns the status, with 200 if the session is ready and the stream connected, 503 otherwise
+//	/status returns the status
+func (e *liveEngine) startStatusServer() error {
+
+	listener, err := net.Listen("tcp", e.parameters.statusAddress)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	server := &http.Server{Handler: mux}
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+
+		if _, ok := e.status(); !ok {
+			writeStatus(w, http.StatusServiceUnavailable, map[string]string{"status": "unresponsive"})
+			return
+		}
+
+		writeStatus(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+
+		status, ok := e.status()
+
+		switch {
+		case !ok:
+			writeStatus(w, http.StatusServiceUnavailable, map[string]string{"status": "unresponsive"})
+		case !status.Ready || !status.Stream.Connected:
+			writeStatus(w, http.StatusServiceUnavailable, status)
+		default:
+			writeStatus(w, http.StatusOK, status)
+		}
+	})
+
+	mux.HandleFunc("/metrics", e.serveMetrics)
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+
+		status, ok := e.status()
+		if !ok {
+			writeStatus(w, http.StatusServiceUnavailable, map[string]string{"status": "unresponsive"})
+			return
+		}
+
+		writeStatus(w, http.StatusOK, status)
+	})
+
+	e.async(func() {
+		if err := server.Serve(listener); err != http.ErrServerClosed {
+			e.logger.Error("status server: " + err.Error())
+		}
+	})
+
+	e.async(func() {
+
+		<-e.ctx.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+		defer cancel()
+
+		server.Shutdown(ctx)
+	})
+
+	return nil
+}
+
+func writeStatus(w http.ResponseWriter, code int, body interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	json.NewEncoder(w).Encode(body)
+}
+
+// status takes a snapshot of the session in the event loop, returns false if it doesn't respond in time.
+func (e *liveEngine) status() (*SessionStatus, bool) {
+
+	snapshot := make(chan *SessionStatus, 1)
+
+	e.scheduler.schedule(time.Now(), 0, func() {
+		snapshot <- e.snapshotStatus(time.Now())
+	})
+
+	timeout := time.NewTimer(statusTimeout)
+	defer timeout.Stop()
+
+	select {
+	case status := <-snapshot:
+		return status, true
+	case <-timeout.C:
+		return nil, false
+	case <-e.ctx.Done():
+		return nil, false
+	}
+}
+
+func (e *liveEngine) snapshotStatus(now time.Time) *SessionStatus {
+
+	status := &SessionStatus{
+		Time:  now,
+		Ready: e.ready,
+		Account: AccountSummary{
+			ID:         e.account.id,
+			Currency:   e.account.homeCurrency,
+			Balance:    e.account.Balance(),
+			Equity:     e.account.Equity(),
+			Unrealized: e.account.UnrealizedNetProfit(),
+			MarginUsed: e.account.MarginUsed(),
+			MarginFree: e.account.MarginFree(),
+		},
+		Trades: []TradeStatus{},
+		Orders: []OrderStatus{},
+		Errors: e.errors.recent(),
+	}
+
+	stale := e.watchdog.current()
+
+	status.Stream = StreamStatus{
+		Connected:        stale.connected(len(e.account.instruments)),
+		LastTick:         e.lastTick,
+		LastHeartbeat:    stale.LastHeartbeat,
+		StaleInstruments: append([]string{}, stale.Instruments...),
+	}
+
+	if !e.lastTick.IsZero() {
+		status.Stream.SinceLastTick = now.Sub(e.lastTick).Seconds()
+	}
+
+	for _, inst := range e.account.instruments {
+		for trade := range inst.Trades() {
+			status.Trades = append(status.Trades, TradeStatus{
+				ID:           trade.ID(),
+				Instrument:   trade.InstrumentName(),
+				Side:         trade.Side().String(),
+				Units:        trade.Units(),
+				OpenTime:     trade.OpenTime(),
+				OpenPrice:    trade.OpenPrice(),
+				CurrentPrice: trade.CurrentPrice(),
+				Unrealized:   trade.UnrealizedNetProfit(),
+				Tag:          trade.Tag(),
+			})
+		}
+	}
+
+	sort.Slice(status.Trades, func(i, j int) bool {
+		return status.Trades[i].OpenTime.Before(status.Trades[j].OpenTime)
+	})
+
+	for _, order := range e.throttle.waiting() {
+		status.Orders = append(status.Orders, orderStatus(order, false))
+	}
+
+	for _, order := range e.inFlight.orders() {
+		status.Orders = append(status.Orders, orderStatus(order, true))
+	}
+
+	return status
+}
+
+func orderStatus(order *OrderRequest, sent bool) OrderStatus {
+	return OrderStatus{
+		Instrument: order.Instrument,
+		Side:       order.Side.String(),
+		Units:      order.Units,
+		Time:       order.Time,
+		Sent:       sent,
+	}
+}
+
+// sentOrders keeps the orders sent to the broker until the request returns.
+type sentOrders struct {
+	requests map[*OrderRequest]bool
+	mutex    sync.Mutex
+}
+
+func newSentOrders() *sentOrders {
+	return &sentOrders{requests: make(map[*OrderRequest]bool)}
+}
+
+func (s *sentOrders) add(order *OrderRequest) {
+	s.mutex.Lock()
+	s.requests[order] = true
+	s.mutex.Unlock()
+}
+
+func (s *sentOrders) remove(order *OrderRequest) {
+	s.mutex.Lock()
+	delete(s.requests, order)
+	s.mutex.Unlock()
+}
+
+func (s *sentOrders) orders() []*OrderRequest {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	orders := make([]*OrderRequest, 0, len(s.requests))
+	for order := range s.requests {
+		orders = append(orders, order)
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].Time.Before(orders[j].Time)
+	})
+
+	return orders
+}
@@ -0,0 +1,78 @@
+package gotrader
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestStatusServer(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	strategy := &lifecycleStrategy{filled: make(chan struct{})}
+	session := NewTradingSession(Instruments([]string{eurUsd.Name}), AccountID("account"), SetLogger(logger),
+		StatusServer(address)).SetClient(newFakeLiveClient()).SetStrategy(strategy).Live()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+
+	go func() { result <- session.Run(ctx) }()
+
+	select {
+	case <-strategy.filled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the order was not filled")
+	}
+
+	// The fill is applied by the event loop, poll until the trade is reported
+	var status SessionStatus
+
+	for deadline := time.Now().Add(5 * time.Second); len(status.Trades) == 0; {
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a ready session with the trade open, got %+v", status)
+		}
+
+		res, err := http.Get("http://" + address + "/readyz")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		status = SessionStatus{}
+		json.NewDecoder(res.Body).Decode(&status)
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected the session to be ready, got %d", res.StatusCode)
+		}
+	}
+
+	if !status.Stream.Connected || status.Stream.LastTick.IsZero() || status.Account.Balance != 10000 ||
+		status.Trades[0].ID != "1" || status.Trades[0].Units != 1000 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	cancel()
+
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := http.Get("http://" + address + "/healthz"); err == nil {
+		t.Error("expected the status server to stop with the session")
+	}
+}
@@ -0,0 +1,185 @@
+package gotrader
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	snapshotFileName = "snapshot"
+	walFileName      = "wal"
+	walHeaderSize    = 16 // length, checksum and sequence number of a record
+)
+
+// StateStore persists the engine state as a snapshot and a write-ahead log of the changes after it.
+type StateStore interface {
+	Load() (snapshot []byte, records [][]byte, err error) // the snapshot is nil if there isn't one
+	Append(record []byte) error                           // durably appends a record to the log
+	Snapshot(snapshot []byte) error                       // replaces the snapshot, the log records before it are discarded
+	Close() error
+}
+
+// fileStore keeps the snapshot and the log in files of a directory. Log records are numbered, so the records
+// included in the snapshot are ignored if the process stops between the snapshot and the log truncation,
+// and are checksummed, so a record partially written by a crash is discarded.
+type fileStore struct {
+	dir   string
+	wal   *os.File
+	seq   uint64 // sequence number of the last record
+	mutex sync.Mutex
+}
+
+// NewFileStore returns a StateStore that keeps the state in files of dir, which is created if needed.
+func NewFileStore(dir string) (StateStore, error) {
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileStore{dir: dir, wal: wal}, nil
+}
+
+func (s *fileStore) Load() ([]byte, [][]byte, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshot, snapshotSeq, err := s.readSnapshot()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := ioutil.ReadFile(s.wal.Name())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		records [][]byte
+		offset  int
+	)
+
+	s.seq = snapshotSeq
+
+	for offset+walHeaderSize <= len(data) {
+
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		checksum := binary.BigEndian.Uint32(data[offset+4:])
+		end := offset + walHeaderSize + length
+
+		if end > len(data) || crc32.ChecksumIEEE(data[offset+8:end]) != checksum { // torn write
+			break
+		}
+
+		if seq := binary.BigEndian.Uint64(data[offset+8:]); seq > snapshotSeq {
+			records = append(records, data[offset+walHeaderSize:end])
+			s.seq = seq
+		}
+
+		offset = end
+	}
+
+	if offset < len(data) { // discard the incomplete record, so the next ones can be read
+		if err := s.wal.Truncate(int64(offset)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return snapshot, records, nil
+}
+
+func (s *fileStore) readSnapshot() ([]byte, uint64, error) {
+
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, snapshotFileName))
+
+	switch {
+	case os.IsNotExist(err):
+		return nil, 0, nil
+	case err != nil:
+		return nil, 0, err
+	case len(data) < 12 || crc32.ChecksumIEEE(data[4:]) != binary.BigEndian.Uint32(data):
+		return nil, 0, errors.New("corrupted state snapshot in " + s.dir)
+	}
+
+	return data[12:], binary.BigEndian.Uint64(data[4:]), nil
+}
+
+func (s *fileStore) Append(record []byte) error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.seq++
+
+	frame := make([]byte, walHeaderSize+len(record))
+	binary.BigEndian.PutUint32(frame, uint32(len(record)))
+	binary.BigEndian.PutUint64(frame[8:], s.seq)
+	copy(frame[walHeaderSize:], record)
+	binary.BigEndian.PutUint32(frame[4:], crc32.ChecksumIEEE(frame[8:]))
+
+	if _, err := s.wal.Write(frame); err != nil {
+		return err
+	}
+
+	return s.wal.Sync()
+}
+
+func (s *fileStore) Snapshot(snapshot []byte) error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data := make([]byte, 12+len(snapshot))
+	binary.BigEndian.PutUint64(data[4:], s.seq)
+	copy(data[12:], snapshot)
+	binary.BigEndian.PutUint32(data, crc32.ChecksumIEEE(data[4:]))
+
+	tmp, err := ioutil.TempFile(s.dir, snapshotFileName)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, snapshotFileName)); err != nil {
+		return err
+	}
+
+	if dir, err := os.Open(s.dir); err == nil { // persist the rename
+		dir.Sync()
+		dir.Close()
+	}
+
+	if err := s.wal.Truncate(0); err != nil {
+		return err
+	}
+
+	return s.wal.Sync()
+}
+
+func (s *fileStore) Close() error {
+	return s.wal.Close()
+}
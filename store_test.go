@@ -0,0 +1,260 @@
+package gotrader
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func tempStore(t *testing.T) (StateStore, string) {
+
+	dir, err := ioutil.TempDir("", "gotrader-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, dir
+}
+
+func loadRecords(t *testing.T, dir string) (string, string) {
+
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	snapshot, records, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logged []string
+	for _, record := range records {
+		logged = append(logged, string(record))
+	}
+
+	return string(snapshot), strings.Join(logged, ",")
+}
+
+func TestFileStoreRecovery(t *testing.T) {
+
+	store, dir := tempStore(t)
+	defer os.RemoveAll(dir)
+
+	for _, record := range []string{"a", "b"} {
+		if err := store.Append([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.Snapshot([]byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Append([]byte("c")); err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close()
+
+	if snapshot, records := loadRecords(t, dir); snapshot != "ab" || records != "c" {
+		t.Errorf("expected snapshot ab and record c, got %s and %s", snapshot, records)
+	}
+
+	// A record partially written by a crash is discarded
+	wal, _ := os.OpenFile(filepath.Join(dir, walFileName), os.O_APPEND|os.O_WRONLY, 0644)
+	wal.Write([]byte{0, 0, 0, 9, 1, 2})
+	wal.Close()
+
+	store, _ = NewFileStore(dir)
+	store.Load()
+	store.Append([]byte("d"))
+	store.Close()
+
+	if snapshot, records := loadRecords(t, dir); snapshot != "ab" || records != "c,d" {
+		t.Errorf("expected snapshot ab and records c,d, got %s and %s", snapshot, records)
+	}
+}
+
+func TestFileStoreIgnoresRecordsInSnapshot(t *testing.T) {
+
+	store, dir := tempStore(t)
+	defer os.RemoveAll(dir)
+
+	store.Append([]byte("a"))
+	wal, _ := ioutil.ReadFile(filepath.Join(dir, walFileName))
+
+	store.Snapshot([]byte("a"))
+	store.Close()
+
+	// The process stopped after the snapshot, before the log was truncated
+	ioutil.WriteFile(filepath.Join(dir, walFileName), wal, 0644)
+
+	if snapshot, records := loadRecords(t, dir); snapshot != "a" || records != "" {
+		t.Errorf("expected snapshot a without records, got %s and %s", snapshot, records)
+	}
+}
+
+func TestPersistedTagsSurviveRestart(t *testing.T) {
+
+	store, dir := tempStore(t)
+	defer os.RemoveAll(dir)
+
+	client := newFakeLiveClient()
+	runUntilFilled(t, client, &lifecycleStrategy{filled: make(chan struct{})}, SessionTag("session"), PersistState(store))
+
+	// The broker doesn't return the tag after the restart
+	store, _ = NewFileStore(dir)
+	client = newFakeLiveClient()
+	client.trades = []TradeDetails{{ID: "1", Instrument: eurUsd, Side: Long, Units: 1000, OpenPrice: 1.1}}
+	strategy := &lifecycleStrategy{filled: make(chan struct{}), bought: true}
+	client.fills <- &OrderFill{Error: "NOT_ENOUGH_MARGIN", Instrument: eurUsd} // stops the run
+
+	runUntilFilled(t, client, strategy, SessionTag("session"), PersistState(store))
+
+	if tag := strategy.engine.Account().Instrument(eurUsd.Name).Trade("1").Tag(); tag != "session" {
+		t.Errorf("expected the trade tag to be restored, got %q", tag)
+	}
+}
+
+func TestStrategyStateSurvivesRestart(t *testing.T) {
+
+	store, dir := tempStore(t)
+	defer os.RemoveAll(dir)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	keeper, err := loadState(store, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := newState(keeper)
+	state.SetInt("level", 3)
+	state.SetString("regime", "trend")
+	state.SetString("removed", "x")
+	state.Delete("removed")
+	store.Close() // stopped without a final snapshot
+
+	store, _ = NewFileStore(dir)
+	keeper, err = loadState(store, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keeper.close()
+
+	state = newState(keeper)
+
+	if level, ok := state.Int("level"); !ok || level != 3 {
+		t.Errorf("expected level 3, got %d", level)
+	}
+
+	if regime, ok := state.String("regime"); !ok || regime != "trend" {
+		t.Errorf("expected regime trend, got %q", regime)
+	}
+
+	if _, ok := state.Float("regime"); ok {
+		t.Error("expected a string not to be read as a float")
+	}
+
+	if state.Has("removed") {
+		t.Error("expected the deleted key to be removed")
+	}
+}
+
+func TestWaitingOrdersExpireOnRestart(t *testing.T) {
+
+	store, dir := tempStore(t)
+	defer os.RemoveAll(dir)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	keeper, err := loadState(store, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := &OrderRequest{Instrument: eurUsd.Name, Side: Long, Units: 1000}
+	keeper.ordersWaiting([]*OrderRequest{order})
+	order.Units = 2000 // changed by the throttle after it was saved
+	store.Close()
+
+	store, _ = NewFileStore(dir)
+	keeper, err = loadState(store, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if orders := keeper.waitingOrders(); len(orders) != 1 || orders[0].Instrument != eurUsd.Name || orders[0].Units != 1000 {
+		t.Fatalf("expected the waiting order to be restored, got %+v", orders)
+	}
+
+	keeper.close()
+
+	// The restarted session reports the order to the strategy instead of sending it
+	store, _ = NewFileStore(dir)
+	strategy := &lifecycleStrategy{filled: make(chan struct{}), bought: true}
+	runUntilFilled(t, newFakeLiveClient(), strategy, PersistState(store))
+
+	if len(strategy.fills) != 1 || strategy.fills[0].Rejection == nil || strategy.fills[0].Rejection.Rule != ExpiredOnRestartRule {
+		t.Fatalf("expected the waiting order to be rejected, got %+v", strategy.fills)
+	}
+
+	store, _ = NewFileStore(dir)
+	keeper, _ = loadState(store, logger)
+	defer keeper.close()
+
+	if orders := keeper.waitingOrders(); len(orders) != 0 {
+		t.Errorf("expected the reported orders not to be restored, got %+v", orders)
+	}
+}
+
+// failingStore fails to append the records.
+type failingStore struct{}
+
+func (failingStore) Load() ([]byte, [][]byte, error) { return nil, nil, nil }
+func (failingStore) Append(record []byte) error      { return errors.New("disk full") }
+func (failingStore) Snapshot(snapshot []byte) error  { return nil }
+func (failingStore) Close() error                    { return nil }
+
+func TestStateReturnsStoreErrors(t *testing.T) {
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	keeper, err := loadState(failingStore{}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := newState(keeper)
+
+	if err := state.SetInt("level", 3); err == nil || err.Error() != "disk full" {
+		t.Errorf("expected the store error, got %v", err)
+	}
+
+	if level, ok := state.Int("level"); !ok || level != 3 {
+		t.Errorf("expected the value to be kept for the session, got %d", level)
+	}
+
+	if err := state.Delete("level"); err == nil {
+		t.Error("expected the store error on delete")
+	}
+
+	if err := state.Delete("level"); err != nil {
+		t.Errorf("expected no error deleting a missing key, got %v", err)
+	}
+}
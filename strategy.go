@@ -8,3 +8,39 @@ type Strategy interface {
 	OnTick(tick *Tick)
 	OnStop()
 }
+
+// BarStrategy is an optional interface that a strategy can implement to receive
+// the bars of the timeframes defined in the session.
+type BarStrategy interface {
+	OnBar(bar *Bar)
+}
+
+// TickRejectionStrategy is an optional interface that a strategy can implement to be notified
+// of the ticks dropped by the tick filter.
+type TickRejectionStrategy interface {
+	OnTickRejected(tick *Tick, reason TickRejection)
+}
+
+// CircuitBreakerStrategy is an optional interface that a strategy can implement to be notified
+// when the circuit breaker is tripped.
+type CircuitBreakerStrategy interface {
+	OnCircuitBreak(breach *Breach)
+}
+
+// ShutdownStrategy is an optional interface that a strategy can implement to be notified of the actions of the
+// shutdown policy, before OnStop is called.
+type ShutdownStrategy interface {
+	OnShutdown(report *ShutdownReport)
+}
+
+// ReconciliationStrategy is an optional interface that a strategy can implement to be notified when
+// the reconciliation finds differences between the account and the broker.
+type ReconciliationStrategy interface {
+	OnReconciliation(report *Reconciliation)
+}
+
+// StaleDataStrategy is an optional interface that a strategy can implement to be notified when the prices become
+// stale and when they are updating again.
+type StaleDataStrategy interface {
+	OnStaleData(state *StaleData)
+}
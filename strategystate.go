@@ -0,0 +1,172 @@
+package gotrader
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is a key-value store for the strategy state, such as counters, regimes or grid levels. Values are
+// JSON encoded. In a live session with PersistState, each change is saved in the store before the setter
+// returns, so the values survive restarts. Otherwise, and in backtests, the values are kept in memory and
+// belong to the session.
+type State struct {
+	values map[string]json.RawMessage
+	keeper *stateKeeper
+	mutex  sync.RWMutex
+}
+
+func newState(keeper *stateKeeper) *State {
+
+	state := &State{values: make(map[string]json.RawMessage), keeper: keeper}
+
+	if keeper != nil {
+		keeper.mutex.Lock()
+		for key, value := range keeper.state.Values {
+			state.values[key] = value
+		}
+		keeper.mutex.Unlock()
+	}
+
+	return state
+}
+
+// Get decodes the value of the key into value, returns false if the key doesn't exist or can't be decoded.
+func (s *State) Get(key string, value interface{}) bool {
+
+	s.mutex.RLock()
+	data, exist := s.values[key]
+	s.mutex.RUnlock()
+
+	return exist && json.Unmarshal(data, value) == nil
+}
+
+// Set encodes and saves the value of the key. If the store fails, the error is returned and the value is only
+// kept for the session, it may not survive a restart.
+func (s *State) Set(key string, value interface{}) error {
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.values[key] = data
+
+	return s.keeper.valueSet(key, data)
+}
+
+// Delete removes the key. If the store fails, the error is returned and the key may be restored after a restart.
+func (s *State) Delete(key string) error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exist := s.values[key]; !exist {
+		return nil
+	}
+
+	delete(s.values, key)
+
+	return s.keeper.valueDeleted(key)
+}
+
+// Has returns true if the key exists.
+func (s *State) Has(key string) bool {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, exist := s.values[key]
+
+	return exist
+}
+
+// Keys returns the keys in ascending order.
+func (s *State) Keys() []string {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Int returns the integer value of the key, false if it doesn't exist or isn't an integer.
+func (s *State) Int(key string) (int64, bool) {
+
+	var value int64
+	ok := s.Get(key, &value)
+
+	return value, ok
+}
+
+// SetInt saves the integer value of the key.
+func (s *State) SetInt(key string, value int64) error {
+	return s.Set(key, value)
+}
+
+// Float returns the float value of the key, false if it doesn't exist or isn't a number.
+func (s *State) Float(key string) (float64, bool) {
+
+	var value float64
+	ok := s.Get(key, &value)
+
+	return value, ok
+}
+
+// SetFloat saves the float value of the key. NaN and infinite values can't be saved.
+func (s *State) SetFloat(key string, value float64) error {
+	return s.Set(key, value)
+}
+
+// String returns the string value of the key, false if it doesn't exist or isn't a string.
+func (s *State) String(key string) (string, bool) {
+
+	var value string
+	ok := s.Get(key, &value)
+
+	return value, ok
+}
+
+// SetString saves the string value of the key.
+func (s *State) SetString(key string, value string) error {
+	return s.Set(key, value)
+}
+
+// Bool returns the boolean value of the key, false if it doesn't exist or isn't a boolean.
+func (s *State) Bool(key string) (bool, bool) {
+
+	var value bool
+	ok := s.Get(key, &value)
+
+	return value, ok
+}
+
+// SetBool saves the boolean value of the key.
+func (s *State) SetBool(key string, value bool) error {
+	return s.Set(key, value)
+}
+
+// Time returns the time value of the key, false if it doesn't exist or isn't a time.
+func (s *State) Time(key string) (time.Time, bool) {
+
+	var value time.Time
+	ok := s.Get(key, &value)
+
+	return value, ok
+}
+
+// SetTime saves the time value of the key.
+func (s *State) SetTime(key string, value time.Time) error {
+	return s.Set(key, value)
+}
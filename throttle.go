@@ -0,0 +1,282 @@
+package gotrader
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Rules of the orders rejected by the order throttle
+const (
+	ThrottledRule         = "ORDER_THROTTLED"
+	ThrottleCoalescedRule = "THROTTLED_COALESCED" // merged with the waiting order of the same instrument, which is sent instead
+	ThrottleCancelledRule = "THROTTLED_CANCELLED" // cancelled with the waiting order of the same instrument, their units net to 0
+	ExpiredOnRestartRule  = "EXPIRED_ON_RESTART"  // waiting when the session stopped, reported when the persisted session restarts
+)
+
+// Maximum number of orders waiting for the throttle, orders above it are rejected
+const maxPendingOrders = 1000
+
+// ThrottlePolicy defines what happens to the orders above the rate limits.
+type ThrottlePolicy int
+
+const (
+	QueueOrders    ThrottlePolicy = iota // orders wait for the limits and are sent in order
+	CoalesceOrders                       // orders wait for the limits, merged with the waiting order of the same instrument (and side, with hedging)
+	RejectOrders                         // orders are rejected
+)
+
+// RateLimit defines a token bucket: Rate orders per second with bursts of up to Burst orders.
+// A zero rate disables the limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+
+	return &tokenBucket{limit: limit, tokens: float64(limit.Burst)}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate)
+	}
+
+	if now.After(b.last) {
+		b.last = now
+	}
+}
+
+// wait returns the time until a token is available.
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration(math.Ceil((1 - b.tokens) / b.limit.Rate * float64(time.Second)))
+}
+
+// throttledOrder is an order rejected by the throttle.
+type throttledOrder struct {
+	order     *OrderRequest
+	rejection *OrderRejection
+}
+
+// orderThrottle limits the orders sent to the broker with a token bucket for the account and one for each instrument.
+type orderThrottle struct {
+	policy          ThrottlePolicy
+	account         *tokenBucket
+	instrumentLimit RateLimit
+	instruments     map[string]*tokenBucket
+	pending         []*OrderRequest
+	releasing       bool // a release of the waiting orders is scheduled
+	mutex           sync.Mutex
+}
+
+func newOrderThrottle(policy ThrottlePolicy, accountLimit, instrumentLimit RateLimit) *orderThrottle {
+
+	throttle := &orderThrottle{
+		policy:          policy,
+		instrumentLimit: instrumentLimit,
+		instruments:     make(map[string]*tokenBucket),
+	}
+
+	if accountLimit.Rate > 0 {
+		throttle.account = newTokenBucket(accountLimit)
+	}
+
+	return throttle
+}
+
+// admit returns true if the order can be sent now, otherwise it is queued or rejected, depending on the policy.
+// The orders rejected are returned, a coalesced order can also cancel a waiting order. Netting is true if the
+// account has no hedging on the instrument, so orders of opposite sides are coalesced.
+func (t *orderThrottle) admit(order *OrderRequest, netting bool) (bool, []throttledOrder) {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if len(t.pending) == 0 && t.wait(order) == 0 {
+		t.take(order)
+		return true, nil
+	}
+
+	if t.policy == CoalesceOrders {
+		if rejected := t.coalesce(order, netting); rejected != nil {
+			return false, rejected
+		}
+	}
+
+	switch {
+	case t.policy == RejectOrders:
+		return false, []throttledOrder{{order, &OrderRejection{Rule: ThrottledRule, Reason: "order rate limit exceeded"}}}
+	case len(t.pending) >= maxPendingOrders:
+		return false, []throttledOrder{{order, &OrderRejection{Rule: ThrottledRule, Reason: "too many orders waiting for the rate limit"}}}
+	}
+
+	t.pending = append(t.pending, order)
+
+	return false, nil
+}
+
+// release returns the waiting orders that can be sent at now, and the time of the next release
+// (zero if no orders are waiting).
+func (t *orderThrottle) release(now time.Time) ([]*OrderRequest, time.Time) {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var (
+		released  []*OrderRequest
+		remaining []*OrderRequest
+		next      time.Duration = -1
+	)
+
+	for _, order := range t.pending {
+
+		order.Time = now
+
+		if wait := t.wait(order); wait > 0 {
+
+			if next < 0 || wait < next {
+				next = wait
+			}
+
+			remaining = append(remaining, order)
+			continue
+		}
+
+		t.take(order)
+		released = append(released, order)
+	}
+
+	t.pending = remaining
+	t.releasing = next >= 0
+
+	if next < 0 {
+		return released, time.Time{}
+	}
+
+	return released, now.Add(next)
+}
+
+// needsRelease returns true if there are waiting orders and a release isn't scheduled yet,
+// the caller must then schedule it.
+func (t *orderThrottle) needsRelease() bool {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.releasing || len(t.pending) == 0 {
+		return false
+	}
+
+	t.releasing = true
+
+	return true
+}
+
+// waiting returns the orders waiting for the rate limits.
+func (t *orderThrottle) waiting() []*OrderRequest {
+
+	if t == nil {
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return append([]*OrderRequest{}, t.pending...)
+}
+
+// coalesce merges the order with a waiting order of the same instrument, only of the same side unless netting.
+// Returns the orders rejected by the merge, nil if there isn't a waiting order to merge with.
+func (t *orderThrottle) coalesce(order *OrderRequest, netting bool) []throttledOrder {
+
+	for i, pending := range t.pending {
+
+		if pending.Instrument != order.Instrument || (!netting && pending.Side != order.Side) {
+			continue
+		}
+
+		units := int64(pending.Units)*int64(sideSign(pending.Side)) + int64(order.Units)*int64(sideSign(order.Side))
+
+		if units == 0 { // the orders cancel each other
+			t.pending = append(t.pending[:i], t.pending[i+1:]...)
+			rejection := &OrderRejection{Rule: ThrottleCancelledRule, Reason: "opposite orders of " + order.Instrument + " net to 0 units"}
+			return []throttledOrder{{pending, rejection}, {order, rejection}}
+		}
+
+		if units > 0 {
+			pending.Side, pending.Units = Long, int32(units)
+		} else {
+			pending.Side, pending.Units = Short, int32(-units)
+		}
+
+		return []throttledOrder{{order, &OrderRejection{
+			Rule:   ThrottleCoalescedRule,
+			Reason: "merged with the waiting order of " + order.Instrument + ", now " + strconv.FormatInt(int64(pending.Units), 10) + " units " + pending.Side.String(),
+		}}}
+	}
+
+	return nil
+}
+
+func (t *orderThrottle) wait(order *OrderRequest) time.Duration {
+
+	wait := time.Duration(0)
+
+	if t.account != nil {
+		wait = t.account.wait(order.Time)
+	}
+
+	if bucket := t.instrument(order.Instrument); bucket != nil {
+		if instrumentWait := bucket.wait(order.Time); instrumentWait > wait {
+			wait = instrumentWait
+		}
+	}
+
+	return wait
+}
+
+func (t *orderThrottle) take(order *OrderRequest) {
+
+	if t.account != nil {
+		t.account.tokens--
+	}
+
+	if bucket := t.instrument(order.Instrument); bucket != nil {
+		bucket.tokens--
+	}
+}
+
+func (t *orderThrottle) instrument(name string) *tokenBucket {
+
+	if t.instrumentLimit.Rate <= 0 {
+		return nil
+	}
+
+	bucket, exist := t.instruments[name]
+	if !exist {
+		bucket = newTokenBucket(t.instrumentLimit)
+		t.instruments[name] = bucket
+	}
+
+	return bucket
+}
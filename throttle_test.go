@@ -0,0 +1,62 @@
+package gotrader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderThrottle(t *testing.T) {
+
+	start := time.Date(2020, 7, 6, 10, 0, 0, 0, time.UTC)
+
+	order := func(instrument string, side Side, units int32) *OrderRequest {
+		return &OrderRequest{Instrument: instrument, Side: side, Units: units, Time: start}
+	}
+
+	// Reject: the account allows bursts of 2 orders and 1 order per second
+	throttle := newOrderThrottle(RejectOrders, RateLimit{Rate: 1, Burst: 2}, RateLimit{})
+
+	for i, expected := range []bool{true, true, false} {
+		if admitted, rejected := throttle.admit(order("EUR_USD", Long, 100), false); admitted != expected || (!admitted && rejected[0].rejection.Rule != ThrottledRule) {
+			t.Errorf("reject policy, order %d: admitted %v", i, admitted)
+		}
+	}
+
+	// Coalesce: EUR_USD allows 1 order every 10 seconds
+	throttle = newOrderThrottle(CoalesceOrders, RateLimit{}, RateLimit{Rate: 0.1, Burst: 1})
+
+	throttle.admit(order("EUR_USD", Long, 100), true)
+	throttle.admit(order("EUR_USD", Long, 100), true)
+
+	if _, rejected := throttle.admit(order("EUR_USD", Short, 300), true); len(rejected) != 1 || rejected[0].rejection.Rule != ThrottleCoalescedRule {
+		t.Errorf("coalesced order not rejected: %+v", rejected)
+	}
+
+	if !throttle.needsRelease() || throttle.needsRelease() {
+		t.Errorf("release should be scheduled once")
+	}
+
+	released, next := throttle.release(start.Add(time.Second))
+	if len(released) != 0 || !next.Equal(start.Add(10*time.Second)) {
+		t.Errorf("orders released before the rate limit, next release at %v", next)
+	}
+
+	released, next = throttle.release(start.Add(10 * time.Second))
+	if len(released) != 1 || released[0].Side != Short || released[0].Units != 200 || !next.IsZero() {
+		t.Errorf("unexpected coalesced orders %+v", released)
+	}
+
+	// With hedging only the orders of the same side are merged, opposite orders cancel each other without hedging
+	throttle = newOrderThrottle(CoalesceOrders, RateLimit{}, RateLimit{Rate: 0.1, Burst: 1})
+
+	throttle.admit(order("EUR_USD", Long, 100), false)
+	throttle.admit(order("EUR_USD", Long, 100), false)
+
+	if _, rejected := throttle.admit(order("EUR_USD", Short, 100), false); len(rejected) != 0 || len(throttle.waiting()) != 2 {
+		t.Errorf("opposite orders merged with hedging")
+	}
+
+	if _, rejected := throttle.admit(order("EUR_USD", Short, 100), true); len(rejected) != 2 || rejected[1].rejection.Rule != ThrottleCancelledRule || len(throttle.waiting()) != 1 {
+		t.Errorf("orders netting to 0 not cancelled: %+v", rejected)
+	}
+}
@@ -0,0 +1,156 @@
+package gotrader
+
+import (
+	"math"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// TickRejection represents the reason why a tick was dropped by the tick filter.
+type TickRejection int
+
+const (
+	ZeroPrice   TickRejection = iota // bid or ask not positive
+	CrossedBook                      // bid above ask
+	OutOfOrder                       // time before the previous tick of the instrument
+	PriceJump                        // mid price change above the configured number of ATRs
+)
+
+var tickRejections = []TickRejection{ZeroPrice, CrossedBook, OutOfOrder, PriceJump}
+
+func (r TickRejection) String() string {
+
+	names := [...]string{"ZERO_PRICE", "CROSSED_BOOK", "OUT_OF_ORDER", "PRICE_JUMP"}
+
+	return names[r]
+}
+
+// Number of consecutive jumped ticks, close to each other, that confirm a new price level
+const jumpConfirmations = 3
+
+type tickFilterState struct {
+	time          time.Time
+	mid           float64
+	atr           float64 // average absolute change of the mid price between ticks
+	count         int
+	candidate     float64 // mid price of the last jumped tick
+	confirmations int
+}
+
+// tickFilter drops the invalid ticks before they update the instruments. Price jumps are measured
+// in ATRs of the tick mid price, so a jump is only detected after atrPeriod ticks of the instrument.
+// A real move of the market (e.g. a gap when it reopens) is accepted after a few jumped ticks
+// confirm the new price level.
+type tickFilter struct {
+	jumpATRs  float64
+	atrPeriod int
+	states    map[string]*tickFilterState
+	counts    map[TickRejection]*atomic.Int64
+}
+
+func newTickFilter(jumpATRs float64, atrPeriod int) *tickFilter {
+
+	if atrPeriod < 1 {
+		atrPeriod = 1
+	}
+
+	counts := make(map[TickRejection]*atomic.Int64, len(tickRejections))
+	for _, reason := range tickRejections {
+		counts[reason] = atomic.NewInt64(0)
+	}
+
+	return &tickFilter{
+		jumpATRs:  jumpATRs,
+		atrPeriod: atrPeriod,
+		states:    make(map[string]*tickFilterState),
+		counts:    counts,
+	}
+}
+
+// check returns false and the reason if the tick must be dropped.
+func (f *tickFilter) check(tick *Tick) (TickRejection, bool) {
+
+	reason, accepted := f.validate(tick)
+	if !accepted {
+		f.counts[reason].Inc()
+	}
+
+	return reason, accepted
+}
+
+func (f *tickFilter) validate(tick *Tick) (TickRejection, bool) {
+
+	if tick.Bid <= 0 || tick.Ask <= 0 {
+		return ZeroPrice, false
+	}
+
+	if tick.Bid > tick.Ask {
+		return CrossedBook, false
+	}
+
+	state, exist := f.states[tick.Instrument]
+	if !exist {
+		f.states[tick.Instrument] = &tickFilterState{time: tick.Time, mid: (tick.Bid + tick.Ask) / 2}
+		return 0, true
+	}
+
+	if tick.Time.Before(state.time) {
+		return OutOfOrder, false
+	}
+
+	mid := (tick.Bid + tick.Ask) / 2
+	change := math.Abs(mid - state.mid)
+
+	// A flat start gives a zero ATR, jumps are only detected once the prices have moved
+	if f.jumpATRs > 0 && state.count >= f.atrPeriod && state.atr > 0 {
+
+		threshold := f.jumpATRs * state.atr
+
+		if change > threshold {
+
+			if state.confirmations > 0 && math.Abs(mid-state.candidate) <= threshold {
+				state.confirmations++
+			} else {
+				state.confirmations = 1
+			}
+
+			state.candidate = mid
+
+			if state.confirmations < jumpConfirmations {
+				return PriceJump, false
+			}
+
+			change = threshold // the new level is accepted without inflating the ATR
+		}
+	}
+
+	state.confirmations = 0
+	state.time = tick.Time
+	state.mid = mid
+
+	// ATR is seeded with the simple average of the first changes, as the Wilder smoothing
+	if state.count < f.atrPeriod {
+		state.count++
+		state.atr += (change - state.atr) / float64(state.count)
+	} else {
+		state.atr += (change - state.atr) / float64(f.atrPeriod)
+	}
+
+	return 0, true
+}
+
+func (f *tickFilter) rejected() map[TickRejection]int64 {
+
+	rejected := make(map[TickRejection]int64, len(tickRejections))
+
+	if f == nil {
+		return rejected
+	}
+
+	for reason, count := range f.counts {
+		rejected[reason] = count.Load()
+	}
+
+	return rejected
+}
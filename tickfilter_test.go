@@ -0,0 +1,79 @@
+package gotrader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTickFilter(t *testing.T) {
+
+	filter := newTickFilter(10, 5)
+	start := time.Date(2020, 7, 6, 10, 0, 0, 0, time.UTC)
+
+	tick := func(seconds int, bid, ask float64) *Tick {
+		return &Tick{Instrument: "EUR_USD", Bid: bid, Ask: ask, Time: start.Add(time.Duration(seconds) * time.Second)}
+	}
+
+	// Regular ticks define an ATR of 0.0001
+	for i := 0; i < 10; i++ {
+		if _, accepted := filter.check(tick(i, 1.1+float64(i%2)*0.0001, 1.1002+float64(i%2)*0.0001)); !accepted {
+			t.Fatalf("regular tick %d rejected", i)
+		}
+	}
+
+	tests := []struct {
+		tick     *Tick
+		reason   TickRejection
+		accepted bool
+	}{
+		{tick(10, 0, 1.1002), ZeroPrice, false},
+		{tick(10, 1.1003, 1.1002), CrossedBook, false},
+		{tick(5, 1.1, 1.1002), OutOfOrder, false},
+		{tick(10, 1.1, 1.1002), 0, true},
+		{tick(11, 1.2, 1.2002), PriceJump, false},
+		{tick(12, 1.1001, 1.1003), 0, true},
+		{tick(13, 1.2, 1.2002), PriceJump, false}, // new level confirmed by the third tick
+		{tick(14, 1.2001, 1.2003), PriceJump, false},
+		{tick(15, 1.2, 1.2002), 0, true},
+		{tick(16, 1.2001, 1.2003), 0, true},
+	}
+
+	for i, test := range tests {
+		if reason, accepted := filter.check(test.tick); accepted != test.accepted || (!accepted && reason != test.reason) {
+			t.Errorf("tick %d: got %v %v, expected %v %v", i, reason, accepted, test.reason, test.accepted)
+		}
+	}
+
+	rejected := filter.rejected()
+	if rejected[ZeroPrice] != 1 || rejected[CrossedBook] != 1 || rejected[OutOfOrder] != 1 || rejected[PriceJump] != 3 {
+		t.Errorf("unexpected rejection counts %v", rejected)
+	}
+}
+
+func TestTickFilterFlatStart(t *testing.T) {
+
+	filter := newTickFilter(10, 5)
+	start := time.Date(2020, 7, 6, 10, 0, 0, 0, time.UTC)
+
+	tick := func(seconds int, bid float64) *Tick {
+		return &Tick{Instrument: "EUR_USD", Bid: bid, Ask: bid + 0.0002, Time: start.Add(time.Duration(seconds) * time.Second)}
+	}
+
+	// Flat ticks define a zero ATR
+	for i := 0; i < 10; i++ {
+		if _, accepted := filter.check(tick(i, 1.1)); !accepted {
+			t.Fatalf("flat tick %d rejected", i)
+		}
+	}
+
+	// The first moves are accepted and define the ATR, then jumps are detected again
+	for i, bid := range []float64{1.1001, 1.1, 1.1001, 1.1, 1.1001} {
+		if reason, accepted := filter.check(tick(10+i, bid)); !accepted {
+			t.Fatalf("moving tick %d rejected: %v", i, reason)
+		}
+	}
+
+	if reason, accepted := filter.check(tick(20, 1.2)); accepted || reason != PriceJump {
+		t.Errorf("expected a price jump after the ATR is defined, got %v %v", reason, accepted)
+	}
+}
@@ -12,6 +12,7 @@ import (
 type Trade struct {
 	id                        string
 	instrumentName            string
+	tag                       string
 	side                      Side
 	units                     int32
 	openTime                  time.Time
@@ -64,7 +65,7 @@ func (t *Trade) calculateUnrealized() {
 }
 
 func (t *Trade) calculateMarginUsed() {
-	t.marginUsed = float64(t.units) / t.leverage.Load() * t.ccyConversion.BaseConversionRate.Load()
+	t.marginUsed = marginRequired(float64(t.units), t.leverage.Load(), t.ccyConversion)
 }
 
 func (t *Trade) updateChargedFee(fee float64) {
@@ -91,6 +92,11 @@ func (t *Trade) ID() string {
 	return t.id
 }
 
+// Tag returns the tag of the session that opened the trade, empty if it wasn't tagged.
+func (t *Trade) Tag() string {
+	return t.tag
+}
+
 // InstrumentName return the instrument name.
 func (t *Trade) InstrumentName() string {
 	return t.instrumentName
@@ -0,0 +1,178 @@
+package gotrader
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// warmUp replays to the strategy the last historical bars before the session starts trading,
+// so indicators are ready from the first live tick. Live ticks received in the meantime are
+// buffered and processed afterwards, so there is no gap between history and the live stream.
+// Buffered ticks already aggregated in the history are dropped.
+type warmUp struct {
+	count       int
+	timeframe   Timeframe
+	client      BrokerClient
+	parameters  *sessionParameters
+	bars        *barBuilder
+	strategy    Strategy
+	barStrategy BarStrategy
+	logger      Logger
+	active      *atomic.Bool
+	mutex       sync.Mutex
+	pending     []*Tick
+	covered     map[string]time.Time // end of the history received for each instrument
+}
+
+func newWarmUp(
+	client BrokerClient,
+	parameters *sessionParameters,
+	bars *barBuilder,
+	strategy Strategy,
+	logger Logger,
+) *warmUp {
+
+	w := &warmUp{
+		count:      parameters.warmUpCount,
+		timeframe:  parameters.warmUpTimeframe,
+		client:     client,
+		parameters: parameters,
+		bars:       bars,
+		strategy:   strategy,
+		logger:     logger,
+		active:     atomic.NewBool(true),
+		covered:    make(map[string]time.Time),
+	}
+
+	w.barStrategy, _ = strategy.(BarStrategy)
+
+	return w
+}
+
+// buffer keeps the tick while the warm up is running, returns false if the warm up has finished.
+func (w *warmUp) buffer(tick *Tick) bool {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.active.Load() {
+		return false
+	}
+
+	w.pending = append(w.pending, tick)
+
+	return true
+}
+
+// finish ends the warm up and returns the ticks buffered meanwhile that are not covered by the history.
+func (w *warmUp) finish() []*Tick {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.active.Store(false)
+	pending := make([]*Tick, 0, len(w.pending))
+
+	for _, tick := range w.pending {
+		if covered, exist := w.covered[tick.Instrument]; !exist || !tick.Time.Before(covered) {
+			pending = append(pending, tick)
+		}
+	}
+
+	w.pending = nil
+
+	return pending
+}
+
+// run delivers the complete bars before to, ordered by close time, through OnBar (bar strategies only)
+// and their closes through OnTick. The last incomplete bar seeds the bar builder, to be completed with live ticks.
+func (w *warmUp) run(to time.Time) {
+
+	history, ok := w.client.(HistoryClient)
+	if !ok {
+		w.logger.Warn("broker client does not provide historical data, strategy warm up skipped")
+		return
+	}
+
+	var bars []*Bar
+
+	for _, instrument := range w.parameters.instruments {
+
+		instrumentBars, err := history.GetBars(BarsRequest{
+			Instrument:    instrument,
+			Timeframe:     w.timeframe,
+			To:            to,
+			Count:         w.count + 1, // the last bar can be incomplete
+			AlignHour:     w.parameters.alignHour,
+			AlignLocation: w.parameters.alignLocation,
+		})
+
+		if err != nil {
+			w.logger.Warn(instrument + ": warm up data not available, " + err.Error())
+			continue
+		}
+
+		completed := make([]*Bar, 0, len(instrumentBars))
+
+		for _, bar := range instrumentBars {
+
+			// The incomplete bar aggregates the ticks up to the request time
+			end := bar.Time.Add(bar.Timeframe.Duration())
+			if end.After(to) {
+				end = to
+			}
+
+			w.mutex.Lock()
+			if end.After(w.covered[instrument]) {
+				w.covered[instrument] = end
+			}
+			w.mutex.Unlock()
+
+			if !bar.Complete {
+				w.bars.seed(bar)
+				continue
+			}
+
+			bar.IsWarmUp = true
+			completed = append(completed, bar)
+		}
+
+		if len(completed) > w.count {
+			completed = completed[len(completed)-w.count:]
+		}
+
+		bars = append(bars, completed...)
+	}
+
+	sort.SliceStable(bars, func(i, j int) bool {
+		return bars[i].Time.Before(bars[j].Time)
+	})
+
+	for _, bar := range bars {
+
+		if w.barStrategy != nil {
+			w.barStrategy.OnBar(bar)
+		}
+
+		w.strategy.OnTick(&Tick{
+			Instrument: bar.Instrument,
+			Bid:        bar.Bid.Close,
+			Ask:        bar.Ask.Close,
+			Time:       bar.Time.Add(bar.Timeframe.Duration()),
+			IsWarmUp:   true,
+		})
+	}
+
+	w.logger.Infof("strategy warmed up with %d bars", len(bars))
+}
+
+func (e *liveEngine) warmingUp() bool {
+	return e.warmUp != nil && e.warmUp.active.Load()
+}
+
+func (e *btEngine) warmingUp() bool {
+	return e.warmUp != nil && e.warmUp.active.Load()
+}
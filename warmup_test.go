@@ -0,0 +1,81 @@
+package gotrader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type historyTestClient struct {
+	BrokerClient
+	bars []*Bar
+}
+
+func (c *historyTestClient) GetBars(request BarsRequest) ([]*Bar, error) {
+	return c.bars, nil
+}
+
+type warmUpTestStrategy struct {
+	bars  []*Bar
+	ticks []*Tick
+}
+
+func (s *warmUpTestStrategy) Initialize()                  {}
+func (s *warmUpTestStrategy) SetEngine(engine Engine)      {}
+func (s *warmUpTestStrategy) OnTick(tick *Tick)            { s.ticks = append(s.ticks, tick) }
+func (s *warmUpTestStrategy) OnBar(bar *Bar)               { s.bars = append(s.bars, bar) }
+func (s *warmUpTestStrategy) OnStop()                      {}
+func (s *warmUpTestStrategy) OnOrderFill(order *OrderFill) {}
+
+func TestWarmUp(t *testing.T) {
+
+	start := time.Date(2020, 7, 6, 10, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+	historyBar := func(minute int, complete bool) *Bar {
+		ohlc := newOHLC(1.1)
+		return &Bar{Instrument: "EUR_USD", Timeframe: M1, Time: at(minute * 60), Bid: ohlc, Ask: ohlc, Mid: ohlc, Volume: 5, Complete: complete}
+	}
+
+	client := &historyTestClient{bars: []*Bar{historyBar(0, true), historyBar(1, true), historyBar(2, false)}}
+	strategy := &warmUpTestStrategy{}
+	bars := newBarBuilder([]Timeframe{M1}, 0, nil)
+	parameters := &sessionParameters{instruments: []string{"EUR_USD"}, warmUpCount: 2, warmUpTimeframe: M1}
+
+	w := newWarmUp(client, parameters, bars, strategy, logrus.New())
+
+	tick := func(seconds int) *Tick {
+		return &Tick{Instrument: "EUR_USD", Bid: 1.1, Ask: 1.1002, Time: at(seconds)}
+	}
+
+	for _, seconds := range []int{110, 130, 155, 160} {
+		if !w.buffer(tick(seconds)) {
+			t.Fatal("tick not buffered during the warm up")
+		}
+	}
+
+	w.run(at(150))
+	pending := w.finish()
+
+	if w.buffer(tick(170)) {
+		t.Error("tick buffered after the warm up finished")
+	}
+
+	if len(strategy.bars) != 2 || !strategy.bars[0].IsWarmUp || len(strategy.ticks) != 2 || !strategy.ticks[1].Time.Equal(at(120)) {
+		t.Fatalf("unexpected warm up delivery: %d bars, %d ticks", len(strategy.bars), len(strategy.ticks))
+	}
+
+	// Ticks up to the request time are already aggregated in the history
+	if len(pending) != 2 || !pending[0].Time.Equal(at(155)) || !pending[1].Time.Equal(at(160)) {
+		t.Fatalf("unexpected pending ticks %v", pending)
+	}
+
+	for _, tick := range pending {
+		bars.update(tick)
+	}
+
+	if volume := bars.instrumentBars["EUR_USD"][M1].Volume; volume != 7 {
+		t.Errorf("seeded bar volume %d, expected 7", volume)
+	}
+}
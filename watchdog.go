@@ -0,0 +1,211 @@
+package gotrader
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Rule of the orders rejected while the prices are stale
+const staleDataRule = "STALE_DATA"
+
+const (
+	staleCheckInterval       = time.Second
+	staleResubscribeInterval = 30 * time.Second // minimum interval between resubscriptions while the prices are stale
+)
+
+// Timeouts of the watchdog of the status server when StaleDataTimeouts isn't set, the tick timeout is only used
+// if the client doesn't send heartbeats
+const (
+	defaultStatusHeartbeatTimeout = 30 * time.Second
+	defaultStatusTickTimeout      = 5 * time.Minute
+)
+
+// StaleData reports the prices that stopped updating, it is notified when the stale state changes.
+type StaleData struct {
+	Time          time.Time
+	Instruments   []string  // trading instruments without ticks within the tick timeout, while the market is open
+	HeartbeatLost bool      // the price stream sent no heartbeat within the heartbeat timeout, all prices are stale
+	LastHeartbeat time.Time // zero if no heartbeat was received
+}
+
+// Stale returns false when the prices are updating again.
+func (d *StaleData) Stale() bool {
+	return d.HeartbeatLost || len(d.Instruments) > 0
+}
+
+// connected returns false if the price stream looks broken. Only a lost heartbeat or all the instruments stale
+// point to a broken stream, a single quiet instrument doesn't.
+func (d *StaleData) connected(instruments int) bool {
+	return !d.HeartbeatLost && (len(d.Instruments) == 0 || len(d.Instruments) < instruments)
+}
+
+// stale returns true if the prices of the instrument are stale.
+func (d *StaleData) stale(instrument string) bool {
+
+	if d.HeartbeatLost {
+		return true
+	}
+
+	for _, inst := range d.Instruments {
+		if inst == instrument {
+			return true
+		}
+	}
+
+	return false
+}
+
+// priceWatchdog detects the stale prices, from the time of the last tick of each instrument and of the last
+// heartbeat of the price stream. Ticks and heartbeats are received by the client goroutines, while the checks
+// run in the event loop.
+type priceWatchdog struct {
+	tickTimeout      time.Duration
+	heartbeatTimeout time.Duration
+	heartbeats       bool                 // heartbeats are only checked if the client sends them
+	lastTicks        map[string]time.Time // time of the last tick, or of the last check while the market was closed
+	lastHeartbeat    time.Time
+	started          time.Time // the timeouts count from the start until the first tick or heartbeat
+	state            *StaleData
+	lastResubscribe  time.Time
+	mutex            sync.Mutex
+}
+
+func newPriceWatchdog(tickTimeout, heartbeatTimeout time.Duration, heartbeats bool, now time.Time) *priceWatchdog {
+	return &priceWatchdog{
+		tickTimeout:      tickTimeout,
+		heartbeatTimeout: heartbeatTimeout,
+		heartbeats:       heartbeats && heartbeatTimeout > 0,
+		lastTicks:        make(map[string]time.Time),
+		started:          now,
+		state:            &StaleData{Time: now},
+	}
+}
+
+func (w *priceWatchdog) tick(instrument string, now time.Time) {
+
+	if w == nil {
+		return
+	}
+
+	w.mutex.Lock()
+	w.lastTicks[instrument] = now
+	w.mutex.Unlock()
+}
+
+func (w *priceWatchdog) heartbeat(now time.Time) {
+
+	if w == nil {
+		return
+	}
+
+	w.mutex.Lock()
+	w.lastHeartbeat = now
+	w.mutex.Unlock()
+}
+
+// check updates the stale state of the instruments, returns it and true if it changed. Ticks aren't expected while
+// the market is closed, so the tick timeouts start again when it opens.
+func (w *priceWatchdog) check(instruments []string, marketOpen bool, now time.Time) (*StaleData, bool) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	state := &StaleData{Time: now, LastHeartbeat: w.lastHeartbeat}
+
+	if w.tickTimeout > 0 {
+		for _, inst := range instruments {
+
+			if !marketOpen {
+				w.lastTicks[inst] = now
+				continue
+			}
+
+			last, exist := w.lastTicks[inst]
+			if !exist {
+				last = w.started
+			}
+
+			if now.Sub(last) > w.tickTimeout {
+				state.Instruments = append(state.Instruments, inst)
+			}
+		}
+
+		sort.Strings(state.Instruments)
+	}
+
+	if w.heartbeats {
+
+		last := w.lastHeartbeat
+		if last.IsZero() {
+			last = w.started
+		}
+
+		state.HeartbeatLost = now.Sub(last) > w.heartbeatTimeout
+	}
+
+	changed := state.HeartbeatLost != w.state.HeartbeatLost || len(state.Instruments) != len(w.state.Instruments)
+
+	for i := 0; !changed && i < len(state.Instruments); i++ {
+		changed = state.Instruments[i] != w.state.Instruments[i]
+	}
+
+	w.state = state
+
+	return state, changed
+}
+
+// current returns the last stale state, which is never stale if the prices aren't watched.
+func (w *priceWatchdog) current() *StaleData {
+
+	if w == nil {
+		return &StaleData{}
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.state
+}
+
+// reject returns the rejection of a new order while the prices of its instrument are stale, nil otherwise.
+// Orders only reducing the position are allowed, so the exposure can be cut while the prices are missing.
+func (w *priceWatchdog) reject(account *Account, order *OrderRequest) *OrderRejection {
+
+	if w == nil {
+		return nil
+	}
+
+	if inst, exist := account.instruments[order.Instrument]; exist && inst.reduces(order.Side, order.Units) {
+		return nil
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.state.stale(order.Instrument) {
+		return &OrderRejection{Rule: staleDataRule, Reason: "prices of " + order.Instrument + " are stale"}
+	}
+
+	return nil
+}
+
+// resubscribeDue returns true if the stream looks broken and the prices should be resubscribed, at most once per
+// resubscribe interval.
+func (w *priceWatchdog) resubscribeDue(state *StaleData, instruments int, now time.Time) bool {
+
+	if state.connected(instruments) {
+		return false
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if now.Sub(w.lastResubscribe) < staleResubscribeInterval {
+		return false
+	}
+
+	w.lastResubscribe = now
+
+	return true
+}
@@ -0,0 +1,79 @@
+package gotrader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPriceWatchdog(t *testing.T) {
+
+	start := time.Date(2020, 3, 4, 10, 0, 0, 0, time.UTC)
+	instruments := []string{"EUR_USD", "USD_JPY"}
+	order := &OrderRequest{Instrument: "EUR_USD", Side: Long, Units: 1000}
+
+	account := newAccount("test")
+	inst := newInstrument("EUR_USD", "EUR", "USD", 20, -4, nil)
+	inst.ccyConversion = newInstrumentConversion("EUR_USD", "EUR", "USD")
+	inst.hedgeType = NoHedge
+	account.instruments["EUR_USD"] = inst
+	inst.openTrade("1", Short, start, 1000, 1.1)
+
+	w := newPriceWatchdog(10*time.Second, 15*time.Second, true, start)
+	w.heartbeat(start.Add(5 * time.Second))
+	w.tick("EUR_USD", start.Add(5*time.Second))
+	w.tick("USD_JPY", start.Add(8*time.Second))
+
+	if state, changed := w.check(instruments, true, start.Add(12*time.Second)); changed || state.Stale() {
+		t.Errorf("expected fresh prices, got %+v", state)
+	}
+
+	state, changed := w.check(instruments, true, start.Add(16*time.Second))
+	if !changed || len(state.Instruments) != 1 || state.Instruments[0] != "EUR_USD" || state.HeartbeatLost {
+		t.Errorf("expected EUR_USD to be stale, got %+v", state)
+	}
+
+	if w.reject(account, order) != nil {
+		t.Error("expected the order closing the position to be allowed")
+	}
+
+	if w.reject(account, &OrderRequest{Instrument: "EUR_USD", Side: Short, Units: 1000}) == nil {
+		t.Error("expected the order on a stale instrument to be rejected")
+	}
+
+	if !state.connected(len(instruments)) || w.resubscribeDue(state, len(instruments), start.Add(16*time.Second)) {
+		t.Error("expected a single stale instrument not to disconnect the stream")
+	}
+
+	if _, changed := w.check(instruments, true, start.Add(17*time.Second)); changed {
+		t.Error("expected the stale state not to change")
+	}
+
+	state, _ = w.check(instruments, true, start.Add(21*time.Second))
+	if !state.HeartbeatLost || len(state.Instruments) != 2 {
+		t.Errorf("expected the heartbeat to be lost, got %+v", state)
+	}
+
+	if state.connected(len(instruments)) || !w.resubscribeDue(state, len(instruments), start.Add(21*time.Second)) {
+		t.Error("expected the prices to be resubscribed when the heartbeat is lost")
+	}
+
+	if w.resubscribeDue(state, len(instruments), start.Add(22*time.Second)) {
+		t.Error("expected the prices not to be resubscribed again within the interval")
+	}
+
+	// Ticks aren't expected while the market is closed, the timeouts start again when it opens
+	w.heartbeat(start.Add(22 * time.Second))
+
+	state, changed = w.check(instruments, false, start.Add(25*time.Second))
+	if !changed || state.Stale() {
+		t.Errorf("expected the prices to be updating again, got %+v", state)
+	}
+
+	if state, _ := w.check(instruments, true, start.Add(30*time.Second)); state.Stale() {
+		t.Errorf("expected fresh prices after the market opens, got %+v", state)
+	}
+
+	if w.reject(account, &OrderRequest{Instrument: "EUR_USD", Side: Short, Units: 1000}) != nil {
+		t.Error("expected the order to be allowed")
+	}
+}